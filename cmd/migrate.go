@@ -1,20 +1,31 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/spf13/cobra"
+	"github.com/thien/database-migration-tool/ent/schema"
+	"github.com/thien/database-migration-tool/internal/anonymizer"
 	"github.com/thien/database-migration-tool/internal/config"
 	"github.com/thien/database-migration-tool/internal/docker"
+	"github.com/thien/database-migration-tool/internal/generator"
 	"github.com/thien/database-migration-tool/internal/logger"
 	"github.com/thien/database-migration-tool/internal/migrator"
+	"github.com/thien/database-migration-tool/internal/pii"
+	"github.com/thien/database-migration-tool/internal/report"
+	"github.com/thien/database-migration-tool/internal/risk"
+	"github.com/thien/database-migration-tool/internal/schemadiff"
+	"github.com/thien/database-migration-tool/internal/vault"
 	"github.com/thien/database-migration-tool/internal/verifier"
 	"go.uber.org/zap"
 )
@@ -101,7 +112,7 @@ var pullCmd = &cobra.Command{
 
 		// Migrate schema
 		logger.Info("Step 1/3: Migrating schema")
-		schemaMigrator := migrator.NewSchemaMigrator(remoteDB, localDB, &cfg.Remote, &cfg.Local)
+		schemaMigrator := migrator.NewSchemaMigrator(remoteDB, localDB, &cfg.Remote, &cfg.Local, &cfg.Migration.Schema)
 
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 		if err := schemaMigrator.Migrate(ctx, dryRun); err != nil {
@@ -149,28 +160,33 @@ var pullCmd = &cobra.Command{
 var schemaCmd = &cobra.Command{
 	Use:   "schema",
 	Short: "Migrate schema only",
-	Long:  "Sync database schema from remote to local using AtlasGo",
+	Long:  "Sync database schema from remote to local with the built-in diff engine or AtlasGo",
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := setupContext()
 
-		// Ensure Docker container is running
-		if err := dockerClient.EnsureRunning(ctx); err != nil {
-			logger.Fatal("Failed to ensure Docker container is running", zap.Error(err))
-		}
+		// The built-in engine needs no Docker; only manage the container
+		// when it is set to auto-start
+		if cfg.Docker.AutoStart {
+			if err := dockerClient.EnsureRunning(ctx); err != nil {
+				logger.Fatal("Failed to ensure Docker container is running", zap.Error(err))
+			}
 
-		time.Sleep(2 * time.Second)
+			time.Sleep(2 * time.Second)
+		}
 
 		remoteDB, localDB := connectDatabases(ctx)
 		defer remoteDB.Close()
 		defer localDB.Close()
 
-		schemaMigrator := migrator.NewSchemaMigrator(remoteDB, localDB, &cfg.Remote, &cfg.Local)
+		schemaMigrator := migrator.NewSchemaMigrator(remoteDB, localDB, &cfg.Remote, &cfg.Local, &cfg.Migration.Schema)
+		schemaMigrator.SetConfirm(confirmer(cmd))
 
 		action, _ := cmd.Flags().GetString("action")
 
 		switch action {
 		case "diff":
-			diff, err := schemaMigrator.Diff(ctx)
+			down, _ := cmd.Flags().GetBool("down")
+			diff, err := schemaMigrator.Diff(ctx, down)
 			if err != nil {
 				logger.Fatal("Failed to generate diff", zap.Error(err))
 			}
@@ -187,8 +203,23 @@ var schemaCmd = &cobra.Command{
 				logger.Fatal("Failed to export schema", zap.Error(err))
 			}
 			logger.Info("Schema exported successfully", zap.String("file", outputFile))
+		case "inspect":
+			local, _ := cmd.Flags().GetBool("local")
+			schema, err := schemaMigrator.Inspect(ctx, !local)
+			if err != nil {
+				logger.Fatal("Failed to inspect schema", zap.Error(err))
+			}
+			if format, _ := cmd.Flags().GetString("format"); format == "json" {
+				data, err := json.MarshalIndent(schema, "", "  ")
+				if err != nil {
+					logger.Fatal("Failed to encode schema", zap.Error(err))
+				}
+				fmt.Println(string(data))
+			} else {
+				fmt.Print(schema.SQL())
+			}
 		default:
-			logger.Fatal("Invalid action. Use: diff, apply, or export")
+			logger.Fatal("Invalid action. Use: diff, apply, export, or inspect")
 		}
 	},
 }
@@ -200,6 +231,8 @@ var dataCmd = &cobra.Command{
 	Long:  "Transfer data from remote to local database",
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := setupContext()
+		allowUnsigned, _ := cmd.Flags().GetBool("allow-unsigned")
+		checkManifestKey(allowUnsigned)
 
 		if err := dockerClient.EnsureRunning(ctx); err != nil {
 			logger.Fatal("Failed to ensure Docker container is running", zap.Error(err))
@@ -211,12 +244,22 @@ var dataCmd = &cobra.Command{
 		defer remoteDB.Close()
 		defer localDB.Close()
 
-		dataMigrator := migrator.NewDataMigrator(remoteDB, localDB, &cfg.Migration)
+		dataMigrator := newDataMigrator(ctx, remoteDB, localDB)
 		results, err := dataMigrator.MigrateAll(ctx)
 		if err != nil {
 			logger.Fatal("Data migration failed", zap.Error(err))
 		}
 
+		if cfg.Migration.Anonymize && len(cfg.Migration.Anonymization.Risk.Tables) > 0 {
+			if !runRiskAnalysis(ctx, localDB, cfg.Migration.Anonymization.Risk.Enforce) && cfg.Migration.Anonymization.Risk.Enforce {
+				logger.Fatal("Tables below the k-anonymity threshold")
+			}
+		}
+
+		if cfg.Migration.Anonymize {
+			writeManifest(dataMigrator, results, allowUnsigned)
+		}
+
 		// Summary
 		successful := 0
 		totalRows := int64(0)
@@ -230,7 +273,8 @@ var dataCmd = &cobra.Command{
 		logger.Info("Data migration completed",
 			zap.Int("successful_tables", successful),
 			zap.Int("total_tables", len(results)),
-			zap.Int64("total_rows", totalRows))
+			zap.Int64("total_rows", totalRows),
+			zap.Int64("pii_replacements", migrator.TotalReplacements(results)))
 	},
 }
 
@@ -238,7 +282,7 @@ var dataCmd = &cobra.Command{
 var verifyCmd = &cobra.Command{
 	Use:   "verify",
 	Short: "Verify migration integrity",
-	Long:  "Compare remote and local databases to verify data consistency",
+	Long:  "Compare remote and local databases to verify data consistency. Exits with 0 when verification passes, 2 when differences exceed the tolerances of migration.verify, and 1 on errors.",
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := setupContext()
 
@@ -248,40 +292,309 @@ var verifyCmd = &cobra.Command{
 
 		v := verifier.NewVerifier(remoteDB, localDB)
 
-		// Verify schema
-		if err := v.VerifySchema(ctx); err != nil {
-			logger.Error("Schema verification failed", zap.Error(err))
+		// Check the signature of a compliance manifest
+		if manifestFile, _ := cmd.Flags().GetString("manifest"); manifestFile != "" {
+			manifest, err := anonymizer.ReadManifest(manifestFile)
+			if err != nil {
+				logger.Fatal("Failed to load manifest", zap.Error(err))
+			}
+			if err := manifest.Verify(cfg.Migration.Anonymization.ManifestKey()); err != nil {
+				logger.Fatal("Manifest verification failed", zap.Error(err))
+			}
+			logger.Info("Manifest signature is valid", zap.String("file", manifestFile))
 		}
 
 		// Get tables to verify
-		tables := cfg.Migration.Tables
-		if len(tables) == 0 {
-			// Get all tables if none specified
-			query := "SELECT tablename FROM pg_tables WHERE schemaname = 'public'"
-			rows, err := remoteDB.QueryContext(ctx, query)
+		tables := tablesToVerify(ctx, remoteDB)
+
+		// Check that no sensitive remote value reached the local database
+		if piiLeak, _ := cmd.Flags().GetBool("pii-leak"); piiLeak {
+			minLength, _ := cmd.Flags().GetInt("min-length")
+			anon := anonymizer.NewAnonymizer(&cfg.Migration.Anonymization)
+			loadRules(ctx, anon, remoteDB)
+
+			leaks, err := v.CheckPIILeaks(ctx, tables, anon.IsSensitive, minLength)
+			if err != nil {
+				logger.Fatal("PII leak check failed", zap.Error(err))
+			}
+			fmt.Println(v.GenerateLeakReport(leaks))
+			if len(leaks) > 0 {
+				logger.Fatal("Sensitive values found in local database", zap.Int("columns", len(leaks)))
+			}
+			return
+		}
+
+		// Look for orphans and duplicates the load let through
+		if integrity, _ := cmd.Flags().GetBool("integrity"); integrity {
+			samples, _ := cmd.Flags().GetInt("samples")
+			results, err := v.AuditIntegrity(ctx, tables, samples)
 			if err != nil {
-				logger.Fatal("Failed to get tables", zap.Error(err))
+				logger.Fatal("Integrity audit failed", zap.Error(err))
 			}
-			defer rows.Close()
+			fmt.Println(v.GenerateIntegrityReport(results))
 
-			for rows.Next() {
-				var table string
-				if err := rows.Scan(&table); err != nil {
-					logger.Fatal("Failed to scan table name", zap.Error(err))
+			code := verifier.ExitOK
+			for _, r := range results {
+				if r.Error != nil {
+					code = verifier.ExitError
+					break
+				}
+				if r.Violations > 0 {
+					code = verifier.ExitMismatch
 				}
-				tables = append(tables, table)
 			}
+			if code != verifier.ExitOK {
+				logger.Close()
+				os.Exit(code)
+			}
+			return
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		checkReportFormat(format)
+		output, _ := cmd.Flags().GetString("output")
+		checksum, _ := cmd.Flags().GetBool("checksum")
+		chunkSize, _ := cmd.Flags().GetInt("chunk-size")
+		workers, _ := cmd.Flags().GetInt("workers")
+		if workers <= 0 {
+			workers = cfg.Migration.Verify.Workers
+		}
+		v.SetWorkers(workers)
+
+		if estimate, _ := cmd.Flags().GetBool("estimate"); estimate {
+			// Planner estimates only, a quick sanity check
+			v.EnableEstimates(cfg.Migration.Verify.EstimateTolerance)
+		} else if profile, _ := cmd.Flags().GetBool("profile"); profile {
+			// Compare column statistics, cheaper than checksums on large tables
+			v.EnableProfiles(&cfg.Migration.Verify.Profile, rewrittenColumns(ctx, remoteDB))
+		} else if checksum {
+			// Compare contents, leaving out the columns the pull rewrites
+			v.EnableChecksums(chunkSize, rewrittenColumns(ctx, remoteDB))
+			if sample, _ := cmd.Flags().GetBool("sample"); sample {
+				sampling := cfg.Migration.Verify.Sampling
+				v.EnableSampling(sampling.Threshold, sampling.Chunks)
+			}
+		}
+
+		baseline, _ := cmd.Flags().GetBool("baseline")
+		if code := verifyAndReport(ctx, v, tables, format, output, baseline); code != verifier.ExitOK {
+			logger.Close()
+			os.Exit(code)
+		}
+	},
+}
+
+// diffCmd lists the rows that differ between the remote and local databases
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the rows of two databases",
+	Long:  "Compare the remote (source) and local (target) databases table by table on the primary key and list missing, extra and changed rows, as a table, JSON or a SQL patch that makes the target equal to the source",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := setupContext()
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "table" && format != "json" && format != "sql" {
+			logger.Fatal("Invalid format. Use: table, json, or sql", zap.String("format", format))
+		}
+
+		remoteDB, localDB := connectDatabases(ctx)
+		defer remoteDB.Close()
+		defer localDB.Close()
+
+		// --reverse makes the local database the source, e.g. before a push
+		v := verifier.NewVerifier(remoteDB, localDB)
+		sourceDB := remoteDB
+		if reverse, _ := cmd.Flags().GetBool("reverse"); reverse {
+			v = verifier.NewVerifier(localDB, remoteDB)
+			sourceDB = localDB
+		}
+
+		exclude := func(table, column string) bool { return false }
+		if all, _ := cmd.Flags().GetBool("all-columns"); !all {
+			exclude = rewrittenColumns(ctx, sourceDB)
+		}
+		chunkSize, _ := cmd.Flags().GetInt("chunk-size")
+		v.EnableChecksums(chunkSize, exclude)
+
+		limit, _ := cmd.Flags().GetInt("limit")
+		diffs := v.DiffAll(ctx, tablesToVerify(ctx, sourceDB), limit)
+
+		var output string
+		switch format {
+		case "table":
+			output = v.GenerateDiffReport(diffs)
+		case "json":
+			data, err := verifier.DiffJSON(diffs)
+			if err != nil {
+				logger.Fatal("Failed to encode diff", zap.Error(err))
+			}
+			output = string(data) + "\n"
+		case "sql":
+			output = verifier.DiffPatch(diffs)
+		}
+
+		outputFile, _ := cmd.Flags().GetString("output")
+		if outputFile == "" {
+			fmt.Print(output)
+			return
+		}
+		if err := os.WriteFile(outputFile, []byte(output), 0644); err != nil {
+			logger.Fatal("Failed to write diff", zap.Error(err))
+		}
+		logger.Info("Diff written", zap.String("file", outputFile))
+	},
+}
+
+// riskCmd reports the re-identification risk of the local database
+var riskCmd = &cobra.Command{
+	Use:   "risk",
+	Short: "Report re-identification risk (k-anonymity)",
+	Long:  "Compute equivalence classes over the configured quasi-identifiers of the local database and flag tables below the k-anonymity threshold",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := setupContext()
+
+		localDB := connectLocalDatabase(ctx)
+		defer localDB.Close()
+
+		enforce, _ := cmd.Flags().GetBool("enforce")
+		if !runRiskAnalysis(ctx, localDB, enforce) {
+			logger.Fatal("Tables below the k-anonymity threshold")
+		}
+	},
+}
+
+// rulesCmd prints the effective anonymization rules
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Print the effective anonymization rules",
+	Long:  "Merge the rules from the config file, column comments and security labels, Ent schema annotations and naming conventions, and print the strategy applied to every remote column with its source",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := setupContext()
+
+		remoteDB, localDB := connectDatabases(ctx)
+		defer remoteDB.Close()
+		defer localDB.Close()
+
+		anon := anonymizer.NewAnonymizer(&cfg.Migration.Anonymization)
+		loadRules(ctx, anon, remoteDB)
+		if err := anon.ValidateRules(); err != nil {
+			logger.Error("Invalid anonymization rules", zap.Error(err))
+		}
+
+		columns, err := anonymizer.ListColumns(ctx, remoteDB)
+		if err != nil {
+			logger.Fatal("Failed to list remote columns", zap.Error(err))
+		}
+
+		all, _ := cmd.Flags().GetBool("all")
+		fmt.Println(anon.GenerateRulesReport(columns, all))
+	},
+}
+
+// detokenizeCmd maps tokens back to the original values stored in the vault
+var detokenizeCmd = &cobra.Command{
+	Use:   "detokenize <token>...",
+	Short: "Look up the original values behind tokens",
+	Long:  "Decrypt the token vault with its passphrase and print the original value of each token. Every lookup is appended to the audit log.",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		vaultCfg := &cfg.Migration.Anonymization.Vault
+		reason, _ := cmd.Flags().GetString("reason")
+
+		audit := func(token, outcome string, entry *vault.Entry) {
+			event := vault.NewAuditEvent(token, outcome, reason)
+			if entry != nil {
+				event.Table = entry.Table
+				event.Column = entry.Column
+			}
+			if err := vault.AppendAudit(vaultCfg.AuditLog, event); err != nil {
+				logger.Fatal("Failed to audit lookup", zap.Error(err))
+			}
+		}
+
+		if _, err := os.Stat(vaultCfg.Path); err != nil {
+			logger.Fatal("Token vault not found", zap.String("path", vaultCfg.Path), zap.Error(err))
 		}
 
-		// Verify data
-		results, err := v.VerifyAll(ctx, tables)
+		v, err := vault.Open(vaultCfg.Path, vaultCfg.VaultPassphrase())
 		if err != nil {
-			logger.Warn("Verification encountered errors", zap.Error(err))
+			for _, token := range args {
+				audit(token, vault.OutcomeDenied, nil)
+			}
+			logger.Fatal("Failed to open token vault", zap.Error(err))
 		}
 
-		// Display report
-		report := v.GenerateReport(results)
-		fmt.Println(report)
+		missing := 0
+		for _, token := range args {
+			entry, ok := v.Lookup(token)
+			if !ok {
+				audit(token, vault.OutcomeNotFound, nil)
+				fmt.Printf("%s\t(not found)\n", token)
+				missing++
+				continue
+			}
+			audit(token, vault.OutcomeFound, &entry)
+			fmt.Printf("%s\t%s.%s\t%s\n", token, entry.Table, entry.Column, entry.Value)
+		}
+
+		if missing > 0 {
+			logger.Fatal("Some tokens were not found", zap.Int("missing", missing))
+		}
+	},
+}
+
+// generateCmd fills the local database with synthetic rows
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Fill the local database with synthetic data",
+	Long:  "Introspect the local schema (or the Ent schema) and insert reproducible synthetic rows that satisfy column types, NOT NULL, CHECK, unique and foreign key constraints",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := setupContext()
+
+		genCfg := cfg.Generate
+		if cmd.Flags().Changed("rows") {
+			genCfg.DefaultRows, _ = cmd.Flags().GetInt("rows")
+		}
+		if cmd.Flags().Changed("seed") {
+			genCfg.Seed, _ = cmd.Flags().GetInt64("seed")
+		}
+		if cmd.Flags().Changed("source") {
+			genCfg.Source, _ = cmd.Flags().GetString("source")
+		}
+		if cmd.Flags().Changed("truncate") {
+			genCfg.Truncate, _ = cmd.Flags().GetBool("truncate")
+		}
+		if cmd.Flags().Changed("tables") {
+			genCfg.Tables, _ = cmd.Flags().GetStringSlice("tables")
+		}
+
+		localDB := connectLocalDatabase(ctx)
+		defer localDB.Close()
+
+		var tables []*generator.Table
+		switch genCfg.Source {
+		case "ent":
+			tables = generator.LoadEntTables(schema.Schemas)
+		case "db":
+			var err error
+			tables, err = generator.LoadTables(ctx, localDB)
+			if err != nil {
+				logger.Fatal("Failed to introspect local schema", zap.Error(err))
+			}
+		default:
+			logger.Fatal("Unknown schema source", zap.String("source", genCfg.Source))
+		}
+
+		logger.Info("Generating synthetic data",
+			zap.String("source", genCfg.Source),
+			zap.Int64("seed", genCfg.Seed),
+			zap.Int("tables", len(tables)))
+
+		results, err := generator.NewGenerator(localDB, &genCfg).Generate(ctx, tables)
+		fmt.Println(generator.GenerateReport(results))
+		if err != nil {
+			logger.Fatal("Synthetic data generation failed", zap.Error(err))
+		}
 	},
 }
 
@@ -366,6 +679,21 @@ var migrateCreateCmd = &cobra.Command{
 			logger.Fatal("Failed to create migration", zap.Error(err))
 		}
 
+		// Flag fields added to the Ent schema without a PII classification
+		unclassified := pii.Unclassified(pii.Load(schema.Schemas))
+		for _, f := range unclassified {
+			logger.Warn("Field has no PII classification",
+				zap.String("schema", f.Schema),
+				zap.String("table", f.Table),
+				zap.String("column", f.Column))
+		}
+		if len(unclassified) > 0 {
+			fmt.Printf("\n⚠️  %d field(s) have no PII annotation. Add annotation.Strategy(...) or annotation.Clear() in ent/schema.\n", len(unclassified))
+			if strict, _ := cmd.Flags().GetBool("strict-pii"); strict {
+				logger.Fatal("Unclassified fields in Ent schema", zap.Int("fields", len(unclassified)))
+			}
+		}
+
 		logger.Info("✅ Migration created successfully!")
 		logger.Info("⚠️  IMPORTANT: Write the DOWN migration manually!")
 		fmt.Printf("\n📝 Edit the DOWN migration: migrations/*_%s.down.sql\n", migrationName)
@@ -470,7 +798,7 @@ var migrateStatusCmd = &cobra.Command{
 var pushCmd = &cobra.Command{
 	Use:   "push",
 	Short: "Push changes to remote database",
-	Long:  "Pushes schema migrations and data from local to remote database (like git push)",
+	Long:  "Pushes schema migrations and data from local to remote database (like git push). Pending migrations are checked against migration.schema.policy.remote.",
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := setupContext()
 
@@ -479,10 +807,27 @@ var pushCmd = &cobra.Command{
 
 		logger.Info("🚀 Pushing to remote database...")
 
-		// Apply schema migrations to remote
+		// Apply schema migrations to remote, once checked against the
+		// remote policy
 		if !dataOnly {
 			logger.Info("Step 1/2: Pushing schema migrations to remote")
 			versionMgr := migrator.NewVersionManager("./migrations")
+
+			remoteDB, localDB := connectDatabases(ctx)
+			plan, err := versionMgr.PendingPlan(ctx, remoteDB)
+			remoteDB.Close()
+			localDB.Close()
+			if err != nil {
+				logger.Fatal("Failed to read pending migrations", zap.Error(err))
+			}
+			evaluation := schemadiff.NewPolicy(&cfg.Migration.Schema.Policy.Remote).Evaluate("remote", plan)
+			if len(evaluation.Risks) > 0 {
+				fmt.Print(evaluation.Report())
+			}
+			if err := migrator.EnforcePolicy(evaluation, confirmer(cmd)); err != nil {
+				logger.Fatal("Failed to push schema", zap.Error(err))
+			}
+
 			applied, err := versionMgr.ApplyMigrations(ctx, &cfg.Remote)
 			if err != nil {
 				logger.Fatal("Failed to push schema", zap.Error(err))
@@ -498,7 +843,7 @@ var pushCmd = &cobra.Command{
 			defer localDB.Close()
 			defer remoteDB.Close()
 
-			dataMigrator := migrator.NewDataMigrator(localDB, remoteDB, &cfg.Migration)
+			dataMigrator := newDataMigrator(ctx, localDB, remoteDB)
 			results, err := dataMigrator.MigrateAll(ctx)
 			if err != nil {
 				logger.Fatal("Failed to push data", zap.Error(err))
@@ -532,6 +877,13 @@ var newPullCmd = &cobra.Command{
 
 		schemaOnly, _ := cmd.Flags().GetBool("schema-only")
 		dataOnly, _ := cmd.Flags().GetBool("data-only")
+		if format, _ := cmd.Flags().GetString("format"); format != "" {
+			checkReportFormat(format)
+		}
+		allowUnsigned, _ := cmd.Flags().GetBool("allow-unsigned")
+		if !schemaOnly {
+			checkManifestKey(allowUnsigned)
+		}
 
 		logger.Info("⬇️  Pulling from remote database...")
 
@@ -561,12 +913,22 @@ var newPullCmd = &cobra.Command{
 			defer remoteDB.Close()
 			defer localDB.Close()
 
-			dataMigrator := migrator.NewDataMigrator(remoteDB, localDB, &cfg.Migration)
+			dataMigrator := newDataMigrator(ctx, remoteDB, localDB)
 			results, err := dataMigrator.MigrateAll(ctx)
 			if err != nil {
 				logger.Fatal("Failed to pull data", zap.Error(err))
 			}
 
+			if cfg.Migration.Anonymize && len(cfg.Migration.Anonymization.Risk.Tables) > 0 {
+				if !runRiskAnalysis(ctx, localDB, cfg.Migration.Anonymization.Risk.Enforce) && cfg.Migration.Anonymization.Risk.Enforce {
+					logger.Fatal("Tables below the k-anonymity threshold")
+				}
+			}
+
+			if cfg.Migration.Anonymize {
+				writeManifest(dataMigrator, results, allowUnsigned)
+			}
+
 			successful := 0
 			totalRows := int64(0)
 			for _, r := range results {
@@ -578,7 +940,23 @@ var newPullCmd = &cobra.Command{
 
 			logger.Info("✅ Data pulled",
 				zap.Int("tables", successful),
-				zap.Int64("rows", totalRows))
+				zap.Int64("rows", totalRows),
+				zap.Int64("pii_replacements", migrator.TotalReplacements(results)))
+
+			// Verify the pull when a report is requested
+			if format, _ := cmd.Flags().GetString("format"); format != "" {
+				output, _ := cmd.Flags().GetString("output")
+				v := verifier.NewVerifier(remoteDB, localDB)
+				if checksum, _ := cmd.Flags().GetBool("checksum"); checksum {
+					v.EnableChecksums(0, rewrittenColumns(ctx, remoteDB))
+				}
+				v.SetWorkers(cfg.Migration.Verify.Workers)
+				baseline, _ := cmd.Flags().GetBool("baseline")
+				if code := verifyAndReport(ctx, v, tablesToVerify(ctx, remoteDB), format, output, baseline); code != verifier.ExitOK {
+					logger.Close()
+					os.Exit(code)
+				}
+			}
 		}
 
 		logger.Info("🎉 Pull completed successfully!")
@@ -593,6 +971,7 @@ func init() {
 	migrateSchemaCmd.AddCommand(migrateStatusCmd)
 
 	// Flags for migrate up/down/status
+	migrateCreateCmd.Flags().Bool("strict-pii", false, "Fail when an Ent schema field has no PII annotation")
 	migrateUpCmd.Flags().String("target", "local", "Target database: local or remote")
 	migrateDownCmd.Flags().String("target", "local", "Target database: local or remote")
 	migrateStatusCmd.Flags().String("target", "local", "Target database: local or remote")
@@ -602,25 +981,79 @@ func init() {
 	// Push command (local -> remote)
 	pushCmd.Flags().Bool("schema-only", false, "Push schema migrations only")
 	pushCmd.Flags().Bool("data-only", false, "Push data only")
+	pushCmd.Flags().Bool("yes", false, "Confirm the schema changes the remote policy wants confirmed")
 	rootCmd.AddCommand(pushCmd)
 
 	// Pull command (remote -> local) - Replace old pullCmd
 	newPullCmd.Flags().Bool("schema-only", false, "Pull schema migrations only")
 	newPullCmd.Flags().Bool("data-only", false, "Pull data only")
+	newPullCmd.Flags().String("format", "", "Verify the pulled data and report as text, json, junit, html, or markdown")
+	newPullCmd.Flags().String("output", "", "Write the verification report to a file instead of stdout")
+	newPullCmd.Flags().Bool("baseline", false, "With --format, fail when local row counts drift from the last good run")
+	newPullCmd.Flags().Bool("checksum", false, "With --format, compare table contents by primary key chunks, not only row counts")
+	newPullCmd.Flags().Bool("allow-unsigned", false, "Write the anonymization manifest unsigned when no signing key is set")
 	rootCmd.AddCommand(newPullCmd)
 
 	// Schema command flags (keep for backward compatibility)
-	schemaCmd.Flags().String("action", "apply", "Action to perform: diff, apply, or export")
+	schemaCmd.Flags().String("action", "apply", "Action to perform: diff, apply, export, or inspect")
 	schemaCmd.Flags().Bool("dry-run", false, "Show what would be done without applying changes")
 	schemaCmd.Flags().String("output", "schema.sql", "Output file for export action")
+	schemaCmd.Flags().Bool("local", false, "Inspect the local database instead of the remote")
+	schemaCmd.Flags().String("format", "sql", "Inspect output: sql or json")
+	schemaCmd.Flags().Bool("down", false, "Diff: print the statements undoing the diff")
+	schemaCmd.Flags().Bool("yes", false, "Confirm the schema changes the local policy wants confirmed")
 	rootCmd.AddCommand(schemaCmd)
 
 	// Data command
+	dataCmd.Flags().Bool("allow-unsigned", false, "Write the anonymization manifest unsigned when no signing key is set")
 	rootCmd.AddCommand(dataCmd)
 
 	// Verify command
+	verifyCmd.Flags().Bool("pii-leak", false, "Check that no sensitive remote value appears in the local database")
+	verifyCmd.Flags().Int("min-length", 4, "Ignore values shorter than this in the PII leak check")
+	verifyCmd.Flags().Bool("integrity", false, "Audit the local database for orphan rows of foreign keys and duplicates of unenforced unique keys")
+	verifyCmd.Flags().Int("samples", 5, "Violating keys listed per constraint in the integrity audit")
+	verifyCmd.Flags().Bool("checksum", false, "Compare table contents by primary key chunks, not only row counts")
+	verifyCmd.Flags().Int("chunk-size", 10000, "Rows per checksum chunk")
+	verifyCmd.Flags().Int("workers", 0, "Tables verified concurrently (default migration.verify.workers)")
+	verifyCmd.Flags().Bool("sample", false, "Checksum a random set of chunks of tables above migration.verify.sampling.threshold estimated rows")
+	verifyCmd.Flags().Bool("estimate", false, "Only compare the planner's row estimates, a quick sanity check")
+	verifyCmd.Flags().Bool("profile", false, "Compare per-column statistics instead of checksums and save them to migration.verify.profile.dir")
+	verifyCmd.Flags().Bool("baseline", false, "Fail when local row counts drift from the last good run beyond migration.verify.baseline (runs are recorded with migration.verify.history.enabled)")
+	verifyCmd.Flags().String("format", "text", "Report format: text, json, junit, html, or markdown")
+	verifyCmd.Flags().String("output", "", "Write the report to a file instead of stdout")
+	verifyCmd.Flags().String("manifest", "", "Verify the signature of an anonymization manifest")
 	rootCmd.AddCommand(verifyCmd)
 
+	// Diff command
+	diffCmd.Flags().String("format", "table", "Output format: table, json, or sql")
+	diffCmd.Flags().String("output", "", "Write the diff to a file instead of stdout")
+	diffCmd.Flags().Int("limit", 1000, "Maximum differing rows listed per table (0 for no limit)")
+	diffCmd.Flags().Int("chunk-size", 10000, "Rows per checksum chunk")
+	diffCmd.Flags().Bool("reverse", false, "Use the local database as the source and the remote as the target")
+	diffCmd.Flags().Bool("all-columns", false, "Also compare anonymized and transformed columns")
+	rootCmd.AddCommand(diffCmd)
+
+	// Risk command
+	riskCmd.Flags().Bool("enforce", false, "Generalize and suppress quasi-identifiers in place to reach the target k")
+	rootCmd.AddCommand(riskCmd)
+
+	// Rules command
+	rulesCmd.Flags().Bool("all", false, "Also list columns that are copied in clear")
+	rootCmd.AddCommand(rulesCmd)
+
+	// Detokenize command
+	detokenizeCmd.Flags().String("reason", "", "Reason for the lookup, recorded in the audit log")
+	rootCmd.AddCommand(detokenizeCmd)
+
+	// Generate command
+	generateCmd.Flags().Int("rows", 0, "Rows per table without an explicit count (overrides generate.default_rows)")
+	generateCmd.Flags().Int64("seed", 0, "Random seed, the same seed generates the same rows (overrides generate.seed)")
+	generateCmd.Flags().String("source", "", "Schema to generate for: db (introspect the local database) or ent")
+	generateCmd.Flags().Bool("truncate", false, "Empty the generated tables before filling them; refused when other tables reference them")
+	generateCmd.Flags().StringSlice("tables", nil, "Only generate these tables")
+	rootCmd.AddCommand(generateCmd)
+
 	// Docker command flags
 	dockerCmd.Flags().String("action", "status", "Action to perform: start, stop, restart, recreate, status, or logs")
 	rootCmd.AddCommand(dockerCmd)
@@ -644,6 +1077,311 @@ func setupContext() context.Context {
 	return ctx
 }
 
+// tablesToVerify returns the configured tables, or every remote table
+func tablesToVerify(ctx context.Context, remoteDB *sql.DB) []string {
+	tables := cfg.Migration.Tables
+	if len(tables) > 0 {
+		return tables
+	}
+
+	// Get all tables if none specified
+	query := "SELECT tablename FROM pg_tables WHERE schemaname = 'public'"
+	rows, err := remoteDB.QueryContext(ctx, query)
+	if err != nil {
+		logger.Fatal("Failed to get tables", zap.Error(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			logger.Fatal("Failed to scan table name", zap.Error(err))
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// checkReportFormat exits when a report format is not supported
+func checkReportFormat(format string) {
+	if format == "text" {
+		return
+	}
+	for _, f := range report.Formats {
+		if f == format {
+			return
+		}
+	}
+	logger.Fatal("Invalid report format. Use: text, json, junit, html, or markdown", zap.String("format", format))
+}
+
+// verifyAndReport compares the schemas and tables of both databases, writes
+// the report in the given format (to stdout when output is empty), records
+// the run in the history and returns the exit code decided by the
+// verification policy. With baseline, tables drifting from the last good run
+// fail too. The text format is the console report.
+func verifyAndReport(ctx context.Context, v *verifier.Verifier, tables []string, format, output string, baseline bool) int {
+	start := time.Now()
+	schemaDiffs, schemaErr := v.CompareSchemas(ctx, cfg.Migration.Verify.SchemaIgnore)
+	if schemaErr != nil {
+		logger.Error("Schema verification failed", zap.Error(schemaErr))
+	} else if errors := verifier.CountSeverity(schemaDiffs, verifier.SeverityError); errors > 0 {
+		logger.Error("Schema verification failed", zap.Int("errors", errors))
+	}
+	schemaTime := time.Since(start)
+
+	results, err := v.VerifyAll(ctx, tables)
+	if err != nil {
+		logger.Warn("Verification encountered errors", zap.Error(err))
+	}
+
+	policy := verifier.NewPolicy(&cfg.Migration.Verify)
+	policy.Apply(results)
+	code, reasons := policy.Evaluate(results, schemaDiffs, schemaErr)
+
+	rep := v.BuildReport(schemaDiffs, schemaErr, schemaTime, results)
+	rep.Source = fmt.Sprintf("%s/%s", cfg.Remote.Host, cfg.Remote.Database)
+	rep.Target = fmt.Sprintf("%s/%s", cfg.Local.Host, cfg.Local.Database)
+
+	// Compare with the last good run
+	history := verifier.NewHistory(&cfg.Migration.Verify.History)
+	var base *verifier.HistoryRun
+	var alerts []verifier.DriftAlert
+	if baseline {
+		if !cfg.Migration.Verify.History.Enabled {
+			logger.Warn("migration.verify.history.enabled is off, this run is not recorded as a baseline")
+		}
+		base, err = history.LastGood(rep.Source, rep.Target, cfg.Migration.Tables)
+		if err != nil {
+			logger.Error("Failed to load baseline", zap.Error(err))
+			code = verifier.ExitError
+		} else if base != nil {
+			alerts = verifier.CompareBaseline(base, results, cfg.Migration.Tables, &cfg.Migration.Verify.Baseline)
+			rep.Baseline = verifier.BaselineCheck(base, alerts)
+			rep.Summarize()
+		}
+		for _, a := range alerts {
+			reasons = append(reasons, fmt.Sprintf("%s: %s since %s (%d -> %d rows)",
+				a.Table, a.Kind, base.GeneratedAt.Format("2006-01-02 15:04"), a.Baseline, a.Current))
+		}
+		if len(alerts) > 0 && code == verifier.ExitOK {
+			code = verifier.ExitMismatch
+		}
+	}
+	rep.Summary.OK = code == verifier.ExitOK
+
+	for _, r := range results {
+		if r.Profile == nil {
+			continue
+		}
+		file, err := verifier.SaveProfiles(cfg.Migration.Verify.Profile.Dir, rep.Source, rep.Target, results)
+		if err != nil {
+			logger.Error("Failed to save profiles", zap.Error(err))
+		} else {
+			logger.Info("Profiles saved", zap.String("file", file))
+		}
+		break
+	}
+
+	// Record the run; only runs passing the policy without drifting from
+	// the baseline become baselines
+	if cfg.Migration.Verify.History.Enabled {
+		file, err := history.Save(verifier.NewHistoryRun(rep.Source, rep.Target, cfg.Migration.Tables, code == verifier.ExitOK, results))
+		if err != nil {
+			logger.Error("Failed to record verification history", zap.Error(err))
+		} else {
+			logger.Info("Verification recorded", zap.String("file", file))
+		}
+	}
+
+	var data []byte
+	if format == "text" {
+		var text string
+		if schemaErr == nil {
+			text += v.GenerateSchemaReport(schemaDiffs) + "\n"
+		}
+		text += v.GenerateReport(results) + "\n"
+		if baseline {
+			text += v.GenerateBaselineReport(base, alerts) + "\n"
+		}
+		data = []byte(text)
+	} else {
+		data, err = report.Render(rep, format)
+		if err != nil {
+			logger.Fatal("Failed to render report", zap.Error(err))
+		}
+	}
+
+	if output == "" {
+		os.Stdout.Write(data)
+	} else if err := os.WriteFile(output, data, 0644); err != nil {
+		logger.Error("Failed to write report", zap.Error(err))
+	} else {
+		logger.Info("Verification report written", zap.String("file", output), zap.String("format", format))
+	}
+
+	for _, reason := range reasons {
+		logger.Error("Verification failed", zap.String("reason", reason))
+	}
+	return code
+}
+
+// rewrittenColumns returns a predicate matching the columns whose values are
+// changed by a pull: anonymized columns and transformed columns
+func rewrittenColumns(ctx context.Context, remoteDB *sql.DB) func(table, column string) bool {
+	transformed := make(map[string]bool)
+	for _, t := range cfg.Migration.Transforms {
+		transformed[t.Table+"."+t.Column] = true
+	}
+
+	var anon *anonymizer.Anonymizer
+	if cfg.Migration.Anonymize {
+		anon = anonymizer.NewAnonymizer(&cfg.Migration.Anonymization)
+		loadRules(ctx, anon, remoteDB)
+	}
+
+	return func(table, column string) bool {
+		if transformed[table+"."+column] {
+			return true
+		}
+		return anon != nil && anon.IsSensitive(table, column)
+	}
+}
+
+// loadRules adds the rules from column comments, Ent annotations and naming
+// conventions of the source database to an anonymizer. Rules in the config
+// file win, then comments, Ent annotations and conventions in that order.
+func loadRules(ctx context.Context, anon *anonymizer.Anonymizer, sourceDB *sql.DB) {
+	anonCfg := &cfg.Migration.Anonymization
+
+	if anonCfg.Comments {
+		rules, err := anonymizer.CatalogRules(ctx, sourceDB, anonCfg.LabelProvider)
+		if err != nil {
+			logger.Fatal("Failed to read PII classification from column comments", zap.Error(err))
+		}
+		if n := anon.AddRules(rules, anonymizer.SourceComment); n > 0 {
+			logger.Debug("Loaded anonymization rules from column comments", zap.Int("rules", n))
+		}
+	}
+
+	if n := anon.AddRules(pii.Rules(pii.Load(schema.Schemas)), anonymizer.SourceEnt); n > 0 {
+		logger.Debug("Loaded anonymization rules from Ent schema", zap.Int("rules", n))
+	}
+
+	if len(anonCfg.Conventions) > 0 {
+		columns, err := anonymizer.ListColumns(ctx, sourceDB)
+		if err != nil {
+			logger.Fatal("Failed to list columns for naming conventions", zap.Error(err))
+		}
+		rules, err := anonymizer.ConventionRules(anonCfg.Conventions, columns)
+		if err != nil {
+			logger.Fatal("Invalid naming convention", zap.Error(err))
+		}
+		if n := anon.AddRules(rules, anonymizer.SourceConvention); n > 0 {
+			logger.Debug("Loaded anonymization rules from naming conventions", zap.Int("rules", n))
+		}
+	}
+}
+
+// newDataMigrator creates a data migrator whose anonymizer also applies the
+// rules declared outside the config file (see loadRules)
+func newDataMigrator(ctx context.Context, sourceDB, targetDB *sql.DB) *migrator.DataMigrator {
+	dataMigrator := migrator.NewDataMigrator(sourceDB, targetDB, &cfg.Migration)
+	if cfg.Migration.Anonymize {
+		loadRules(ctx, dataMigrator.Anonymizer(), sourceDB)
+	}
+	return dataMigrator
+}
+
+// checkManifestKey fails before an anonymized pull whose manifest could not
+// be signed, unless unsigned manifests are allowed
+func checkManifestKey(allowUnsigned bool) {
+	if !cfg.Migration.Anonymize || allowUnsigned || len(cfg.Migration.Anonymization.ManifestKey()) > 0 {
+		return
+	}
+	logger.Fatal("No key to sign the anonymization manifest: set migration.anonymization.signing_key or key, or pass --allow-unsigned")
+}
+
+// writeManifest writes the signed compliance manifest of an anonymized pull.
+// It fails when the manifest can't be signed, unless allowUnsigned is set.
+func writeManifest(dataMigrator *migrator.DataMigrator, results []migrator.MigrateResult, allowUnsigned bool) {
+	anonCfg := &cfg.Migration.Anonymization
+	manifest := dataMigrator.BuildManifest(results,
+		fmt.Sprintf("%s/%s", cfg.Remote.Host, cfg.Remote.Database),
+		fmt.Sprintf("%s/%s", cfg.Local.Host, cfg.Local.Database))
+
+	if err := manifest.Sign(anonCfg.ManifestKey()); err != nil {
+		if !allowUnsigned {
+			logger.Fatal("Failed to sign anonymization manifest", zap.Error(err))
+		}
+		logger.Warn("Writing unsigned anonymization manifest", zap.Error(err))
+	}
+	if err := manifest.Write(anonCfg.Manifest); err != nil {
+		logger.Error("Failed to write anonymization manifest", zap.Error(err))
+		return
+	}
+	logger.Info("Anonymization manifest written", zap.String("file", anonCfg.Manifest))
+}
+
+// runRiskAnalysis prints the k-anonymity report and reports whether all tables passed
+func runRiskAnalysis(ctx context.Context, localDB *sql.DB, enforce bool) bool {
+	riskCfg := &cfg.Migration.Anonymization.Risk
+	if len(riskCfg.Tables) == 0 {
+		logger.Warn("No quasi-identifiers configured (migration.anonymization.risk.tables)")
+		return true
+	}
+
+	analyzer := risk.NewAnalyzer(localDB, riskCfg)
+
+	var results []risk.TableRisk
+	if enforce {
+		results = analyzer.Enforce(ctx)
+	} else {
+		results = analyzer.Analyze(ctx)
+	}
+	fmt.Println(analyzer.GenerateReport(results))
+
+	for _, r := range results {
+		if !r.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func connectLocalDatabase(ctx context.Context) *sql.DB {
+	logger.Info("Connecting to local database", zap.String("host", cfg.Local.Host))
+	localDB, err := sql.Open("postgres", cfg.Local.ConnectionString())
+	if err != nil {
+		logger.Fatal("Failed to connect to local database", zap.Error(err))
+	}
+
+	if err := localDB.PingContext(ctx); err != nil {
+		logger.Fatal("Failed to ping local database", zap.Error(err))
+	}
+
+	return localDB
+}
+
+// confirmer returns how risky schema changes are confirmed: --yes confirms
+// them, otherwise the user is asked on the terminal; without a terminal
+// they are refused
+func confirmer(cmd *cobra.Command) func(string) bool {
+	yes, _ := cmd.Flags().GetBool("yes")
+	return func(prompt string) bool {
+		if yes {
+			return true
+		}
+		if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+		fmt.Printf("%s [y/N] ", prompt)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+}
+
 func connectDatabases(ctx context.Context) (*sql.DB, *sql.DB) {
 	logger.Info("Connecting to remote database", zap.String("host", cfg.Remote.Host))
 	remoteDB, err := sql.Open("postgres", cfg.Remote.ConnectionString())
@@ -7,6 +7,7 @@ import (
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
 	"entgo.io/ent/schema/index"
+	"github.com/thien/database-migration-tool/internal/pii/annotation"
 )
 
 // Order holds the schema definition for the Order entity.
@@ -19,15 +20,19 @@ func (Order) Fields() []ent.Field {
 	return []ent.Field{
 		field.Int("user_id"),
 		field.Float("total_amount").
-			Positive(),
+			Positive().
+			Annotations(annotation.Clear()),
 		field.String("status").
-			Default("pending"),
+			Default("pending").
+			Annotations(annotation.Clear()),
 		field.Time("created_at").
 			Default(time.Now).
-			Immutable(),
+			Immutable().
+			Annotations(annotation.Clear()),
 		field.Time("updated_at").
 			Default(time.Now).
-			UpdateDefault(time.Now),
+			UpdateDefault(time.Now).
+			Annotations(annotation.Clear()),
 	}
 }
 
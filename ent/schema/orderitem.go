@@ -7,6 +7,7 @@ import (
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
 	"entgo.io/ent/schema/index"
+	"github.com/thien/database-migration-tool/internal/pii/annotation"
 )
 
 // OrderItem holds the schema definition for the OrderItem entity.
@@ -20,12 +21,15 @@ func (OrderItem) Fields() []ent.Field {
 		field.Int("order_id"),
 		field.Int("product_id"),
 		field.Int("quantity").
-			Positive(),
+			Positive().
+			Annotations(annotation.Clear()),
 		field.Float("unit_price").
-			Positive(),
+			Positive().
+			Annotations(annotation.Clear()),
 		field.Time("created_at").
 			Default(time.Now).
-			Immutable(),
+			Immutable().
+			Annotations(annotation.Clear()),
 	}
 }
 
@@ -6,6 +6,7 @@ import (
 	"entgo.io/ent"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
+	"github.com/thien/database-migration-tool/internal/pii/annotation"
 )
 
 // Product holds the schema definition for the Product entity.
@@ -17,24 +18,32 @@ type Product struct {
 func (Product) Fields() []ent.Field {
 	return []ent.Field{
 		field.String("name").
-			NotEmpty(),
+			NotEmpty().
+			Annotations(annotation.Clear()),
 		field.Text("description").
-			Optional(),
+			Optional().
+			Annotations(annotation.Strategy("scrub")),
 		field.Float("price").
-			Positive(),
+			Positive().
+			Annotations(annotation.Clear()),
 		field.Int("stock_quantity").
 			Default(0).
-			NonNegative(),
+			NonNegative().
+			Annotations(annotation.Clear()),
 		field.String("category").
-			Optional(),
+			Optional().
+			Annotations(annotation.Clear()),
 		field.String("test_del_col").
-			Optional(),
+			Optional().
+			Annotations(annotation.Clear()),
 		field.Time("created_at").
 			Default(time.Now).
-			Immutable(),
+			Immutable().
+			Annotations(annotation.Clear()),
 		field.Time("updated_at").
 			Default(time.Now).
-			UpdateDefault(time.Now),
+			UpdateDefault(time.Now).
+			Annotations(annotation.Clear()),
 	}
 }
 
@@ -0,0 +1,12 @@
+package schema
+
+import "entgo.io/ent"
+
+// Schemas lists every schema so tools can read their field annotations
+// (e.g. PII classification) without loading the generated graph.
+var Schemas = []ent.Interface{
+	User{},
+	Order{},
+	OrderItem{},
+	Product{},
+}
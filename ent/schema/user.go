@@ -7,6 +7,7 @@ import (
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
 	"entgo.io/ent/schema/index"
+	"github.com/thien/database-migration-tool/internal/pii/annotation"
 )
 
 // User holds the schema definition for the User entity.
@@ -19,24 +20,33 @@ func (User) Fields() []ent.Field {
 	return []ent.Field{
 		field.String("email").
 			Unique().
-			NotEmpty(),
+			NotEmpty().
+			Annotations(annotation.Strategy("email")),
+		// Unique, so it needs a strategy that keeps distinct values distinct
 		field.String("username").
 			Unique().
-			NotEmpty(),
+			NotEmpty().
+			Annotations(annotation.Strategy("fpe")),
 		field.String("password_hash").
-			NotEmpty(),
+			NotEmpty().
+			Annotations(annotation.Strategy("password")),
 		field.String("first_name").
-			Optional(),
+			Optional().
+			Annotations(annotation.Strategy("name")),
 		field.String("last_name").
-			Optional(),
+			Optional().
+			Annotations(annotation.Strategy("name")),
 		field.String("phone").
-			Optional(),
+			Optional().
+			Annotations(annotation.Strategy("phone")),
 		field.Time("created_at").
 			Default(time.Now).
-			Immutable(),
+			Immutable().
+			Annotations(annotation.Clear()),
 		field.Time("updated_at").
 			Default(time.Now).
-			UpdateDefault(time.Now),
+			UpdateDefault(time.Now).
+			Annotations(annotation.Clear()),
 	}
 }
 
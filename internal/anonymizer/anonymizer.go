@@ -6,20 +6,212 @@ import (
 	"math/big"
 	"regexp"
 	"strings"
+	"sync"
 
+	"github.com/thien/database-migration-tool/internal/config"
+	"github.com/thien/database-migration-tool/internal/vault"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Strategy sources reported by ColumnStrategy, in order of precedence
+const (
+	SourceRule       = "rule" // migration.anonymization.rules
+	SourceComment    = "comment"
+	SourceEnt        = "ent"
+	SourceConvention = "convention"
+	SourceHeuristic  = "heuristic"
+	SourceNone       = "none"
+
+	// StrategyClear marks a column that is copied without anonymization
+	StrategyClear = "clear"
+)
+
 // Anonymizer handles data masking and anonymization
 type Anonymizer struct {
 	domains []string
+	key     []byte
+	rules   map[string]*config.AnonymizeRule // keyed by table.column
+	origins map[string]string                // where each rule came from, keyed by table.column
+
+	mu           sync.Mutex
+	rnd          *Faker                      // randomness for non-deterministic strategies
+	detectors    map[string][]*detector      // compiled scrub detectors per rule
+	replacements map[string]map[string]int64 // scrub counts per table
+	factors      map[string]float64          // scale factors per column
+	plugins      map[string]*plugin          // external transformers by name
+	binary       map[string]bool             // bytea columns, keyed by table.column
+	vaultCfg     config.VaultConfig
+	vault        *vault.Vault // opened on first use by the tokenize strategy
 }
 
 // NewAnonymizer creates a new anonymizer instance
-func NewAnonymizer() *Anonymizer {
-	return &Anonymizer{
+func NewAnonymizer(cfg *config.AnonymizationConfig) *Anonymizer {
+	a := &Anonymizer{
 		domains: []string{"example.com", "test.com", "sample.org"},
+		rules:   make(map[string]*config.AnonymizeRule),
+		origins: make(map[string]string),
+
+		rnd:          NewFaker(randomSeed()),
+		detectors:    make(map[string][]*detector),
+		replacements: make(map[string]map[string]int64),
+		factors:      make(map[string]float64),
+		plugins:      make(map[string]*plugin),
+		binary:       make(map[string]bool),
+	}
+
+	if cfg != nil {
+		a.key = []byte(cfg.Key)
+		a.vaultCfg = cfg.Vault
+		for i := range cfg.Rules {
+			rule := &cfg.Rules[i]
+			a.rules[ruleKey(rule.Table, rule.Column)] = rule
+			a.origins[ruleKey(rule.Table, rule.Column)] = SourceRule
+		}
+		for _, p := range cfg.Plugins {
+			a.plugins[p.Name] = newPlugin(p)
+		}
+	}
+
+	return a
+}
+
+// AddRules adds rules from another source (e.g. Ent annotations) for columns
+// that have no rule yet, so earlier sources win on conflicts. It returns the
+// number of rules added.
+func (a *Anonymizer) AddRules(rules []config.AnonymizeRule, source string) int {
+	added := 0
+	for i := range rules {
+		rule := &rules[i]
+		key := ruleKey(rule.Table, rule.Column)
+		if _, ok := a.rules[key]; ok {
+			continue
+		}
+		a.rules[key] = rule
+		a.origins[key] = source
+		added++
+	}
+	return added
+}
+
+// ValidateRules checks that every configured rule references a known strategy
+func (a *Anonymizer) ValidateRules() error {
+	for _, rule := range a.rules {
+		if err := a.validateRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRule checks a single rule, including the path rules of json strategies
+func (a *Anonymizer) validateRule(rule *config.AnonymizeRule) error {
+	if name := pluginName(rule.Strategy); name != "" {
+		if _, ok := a.plugins[name]; !ok {
+			return fmt.Errorf("unknown plugin %q for %s.%s", name, rule.Table, rule.Column)
+		}
+		return nil
+	}
+
+	s, ok := strategies[rule.Strategy]
+	if !ok {
+		return fmt.Errorf("unknown strategy %q for %s.%s", rule.Strategy, rule.Table, rule.Column)
+	}
+	if s.keyed && len(a.key) == 0 {
+		return fmt.Errorf("strategy %q for %s.%s requires migration.anonymization.key", rule.Strategy, rule.Table, rule.Column)
+	}
+	if s.vaulted && a.vaultCfg.VaultPassphrase() == "" {
+		return fmt.Errorf("strategy %q for %s.%s requires a vault passphrase (migration.anonymization.vault.passphrase or DBMIGRATE_VAULT_PASSPHRASE)", rule.Strategy, rule.Table, rule.Column)
+	}
+
+	if s.validate != nil {
+		if err := s.validate(rule); err != nil {
+			return err
+		}
+	}
+
+	if rule.Strategy == "json" {
+		if len(rule.Paths) == 0 {
+			return fmt.Errorf("json strategy for %s.%s requires at least one path", rule.Table, rule.Column)
+		}
+		for _, p := range rule.Paths {
+			if _, err := parseJSONPath(p.Path); err != nil {
+				return fmt.Errorf("invalid path %q for %s.%s: %w", p.Path, rule.Table, rule.Column, err)
+			}
+			if p.Strategy == "json" {
+				return fmt.Errorf("json strategy can't be nested in %s.%s", rule.Table, rule.Column)
+			}
+			if err := a.validateRule(pathRule(rule, p)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// AnonymizeColumn anonymizes a value using the rule configured for the column,
+// falling back to field name heuristics when there is none
+func (a *Anonymizer) AnonymizeColumn(table, column string, value interface{}) (interface{}, error) {
+	return a.anonymizeField(&Field{Table: table, Column: column, Value: value})
+}
+
+// anonymizeField looks up the rule for a field and applies it
+func (a *Anonymizer) anonymizeField(f *Field) (interface{}, error) {
+	if f.Value == nil {
+		return nil, nil
+	}
+
+	rule, ok := a.rules[ruleKey(f.Table, f.Column)]
+	if !ok {
+		return a.AnonymizeValue(f.Column, f.Value), nil
+	}
+
+	f.Rule = rule
+	return a.applyRule(f)
+}
+
+// applyRule runs the strategy named by the field's rule
+func (a *Anonymizer) applyRule(f *Field) (interface{}, error) {
+	if pluginName(f.Rule.Strategy) != "" {
+		return a.applyPlugin(f)
 	}
+
+	s, ok := strategies[f.Rule.Strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q for %s.%s", f.Rule.Strategy, f.Table, f.Column)
+	}
+
+	out, err := s.apply(a, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to anonymize %s.%s with %s: %w", f.Table, f.Column, f.Rule.Strategy, err)
+	}
+	return out, nil
+}
+
+// AnonymizeRow anonymizes every value of a row in place. Strategies see the
+// original values of the other columns (e.g. to shift dates per entity).
+func (a *Anonymizer) AnonymizeRow(table string, columns []string, values []interface{}) error {
+	return a.anonymizeRow(table, columns, values, nil)
+}
+
+// anonymizeRow anonymizes the columns of a row accepted by include (all
+// columns when include is nil)
+func (a *Anonymizer) anonymizeRow(table string, columns []string, values []interface{}, include func(column string) bool) error {
+	row := make(map[string]interface{}, len(columns))
+	for i, col := range columns {
+		row[col] = values[i]
+	}
+
+	for i, col := range columns {
+		if include != nil && !include(col) {
+			continue
+		}
+		v, err := a.anonymizeField(&Field{Table: table, Column: col, Value: values[i], Row: row})
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+	return nil
 }
 
 // AnonymizeEmail masks an email address
@@ -34,8 +226,9 @@ func (a *Anonymizer) AnonymizeEmail(email string) string {
 		return "anonymous@example.com"
 	}
 
-	// Use first character + random string
-	username := parts[0]
+	// Use first character + random string, in characters as the SQL plan
+	// of server mode does
+	username := []rune(parts[0])
 	if len(username) > 0 {
 		masked := string(username[0]) + strings.Repeat("*", min(len(username)-1, 5))
 		domain := a.domains[randomInt(len(a.domains))]
@@ -150,31 +343,76 @@ func (a *Anonymizer) AnonymizeValue(fieldName string, value interface{}) interfa
 		return value // Don't anonymize non-string values
 	}
 
+	switch heuristicStrategy(fieldName) {
+	case "email":
+		return a.AnonymizeEmail(strValue)
+	case "phone":
+		return a.AnonymizePhone(strValue)
+	case "password":
+		return a.AnonymizePassword()
+	case "name":
+		return a.AnonymizeName(strValue)
+	case "ssn":
+		return a.AnonymizeSSN(strValue)
+	case "credit_card":
+		return a.AnonymizeCreditCard(strValue)
+	case "address":
+		return a.AnonymizeAddress(strValue)
+	default:
+		return value
+	}
+}
+
+// heuristicStrategy guesses a strategy from a field name, "" when none matches
+func heuristicStrategy(fieldName string) string {
 	fieldLower := strings.ToLower(fieldName)
 
 	// Match common field patterns
 	switch {
 	case containsAny(fieldLower, []string{"email", "mail"}):
-		return a.AnonymizeEmail(strValue)
+		return "email"
 	case containsAny(fieldLower, []string{"phone", "mobile", "tel"}):
-		return a.AnonymizePhone(strValue)
+		return "phone"
 	case containsAny(fieldLower, []string{"password", "passwd", "pwd"}):
-		return a.AnonymizePassword()
+		return "password"
 	case containsAny(fieldLower, []string{"name", "firstname", "lastname", "fullname"}):
-		return a.AnonymizeName(strValue)
+		return "name"
 	case containsAny(fieldLower, []string{"ssn", "social"}):
-		return a.AnonymizeSSN(strValue)
+		return "ssn"
 	case containsAny(fieldLower, []string{"credit", "card", "cc"}):
-		return a.AnonymizeCreditCard(strValue)
+		return "credit_card"
 	case containsAny(fieldLower, []string{"address", "street", "addr"}):
-		return a.AnonymizeAddress(strValue)
+		return "address"
 	default:
-		return value
+		return ""
 	}
 }
 
+// ColumnStrategy reports which strategy is applied to a column and where it
+// comes from: a configured rule, the field name heuristics, or none ("clear")
+func (a *Anonymizer) ColumnStrategy(table, column string) (strategy, source string) {
+	if rule, ok := a.rules[ruleKey(table, column)]; ok {
+		return rule.Strategy, a.origins[ruleKey(table, column)]
+	}
+	if s := heuristicStrategy(column); s != "" {
+		return s, SourceHeuristic
+	}
+	return StrategyClear, SourceNone
+}
+
+// IsSensitive reports whether a column holds values that must not reach the
+// local database in clear
+func (a *Anonymizer) IsSensitive(table, column string) bool {
+	strategy, _ := a.ColumnStrategy(table, column)
+	return strategy != StrategyClear && strategy != "keep"
+}
+
 // Helper functions
 
+func ruleKey(table, column string) string {
+	return table + "." + column
+}
+
 func randomInt(max int) int {
 	if max <= 0 {
 		return 0
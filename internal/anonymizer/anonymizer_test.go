@@ -0,0 +1,29 @@
+package anonymizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeEmail(t *testing.T) {
+	a := NewAnonymizer(nil)
+	tests := []struct {
+		email string
+		want  string // masked username, or the whole result
+	}{
+		{"", ""},
+		{"john.doe@example.org", "j*****@"},
+		{"jo@example.org", "j*@"},
+		{"émile@example.org", "é****@"},
+		{"a@b@example.org", "anonymous@example.com"},
+		{"@example.org", "anonymous@example.com"},
+		{"nobody", "anonymous@example.com"},
+	}
+
+	for _, tt := range tests {
+		got := a.AnonymizeEmail(tt.email)
+		if !strings.HasPrefix(got, tt.want) || tt.want == "" && got != "" {
+			t.Errorf("AnonymizeEmail(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package anonymizer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+// piiTag matches a classification such as "pii:email" or
+// "pii:fpe preserve_suffix=4" inside a column comment or security label
+var piiTag = regexp.MustCompile(`pii:((?:plugin:)?[\w-]+)((?:\s+[\w-]+=[^\s;]+)*)`)
+
+// ParseClassification extracts the strategy and options from a pii: tag.
+// "pii:none" and "pii:clear" mark a column as reviewed and safe to copy.
+func ParseClassification(text string) (strategy string, options map[string]string, ok bool) {
+	m := piiTag.FindStringSubmatch(text)
+	if m == nil {
+		return "", nil, false
+	}
+
+	strategy = m[1]
+	if strategy == "none" || strategy == StrategyClear {
+		strategy = "keep"
+	}
+	for _, opt := range strings.Fields(m[2]) {
+		k, v, _ := strings.Cut(opt, "=")
+		if options == nil {
+			options = make(map[string]string)
+		}
+		options[k] = v
+	}
+	return strategy, options, true
+}
+
+// CatalogRules reads classifications from the column comments and security
+// labels of a database. A comment wins over a label on the same column. When
+// provider is set only that provider's labels are read.
+func CatalogRules(ctx context.Context, db *sql.DB, provider string) ([]config.AnonymizeRule, error) {
+	query := `
+		SELECT c.relname, a.attname, d.description, 0 AS priority
+		FROM pg_description d
+		JOIN pg_class c ON c.oid = d.objoid AND d.classoid = 'pg_class'::regclass
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum = d.objsubid
+		WHERE n.nspname = 'public' AND d.objsubid > 0
+		UNION ALL
+		SELECT c.relname, a.attname, l.label, 1 AS priority
+		FROM pg_seclabel l
+		JOIN pg_class c ON c.oid = l.objoid AND l.classoid = 'pg_class'::regclass
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum = l.objsubid
+		WHERE n.nspname = 'public' AND l.objsubid > 0 AND ($1 = '' OR l.provider = $1)
+		ORDER BY 1, 2, 4
+	`
+
+	rows, err := db.QueryContext(ctx, query, provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query column comments: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []config.AnonymizeRule
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var table, column, text string
+		var priority int
+		if err := rows.Scan(&table, &column, &text, &priority); err != nil {
+			return nil, fmt.Errorf("failed to scan column comment: %w", err)
+		}
+
+		strategy, options, ok := ParseClassification(text)
+		if !ok || seen[ruleKey(table, column)] {
+			continue
+		}
+		seen[ruleKey(table, column)] = true
+		rules = append(rules, config.AnonymizeRule{
+			Table:    table,
+			Column:   column,
+			Strategy: strategy,
+			Options:  options,
+		})
+	}
+
+	return rules, rows.Err()
+}
+
+// ConventionRules applies naming conventions to the given columns (keyed by
+// table). Patterns are matched against "table.column"; the first match wins.
+func ConventionRules(conventions []config.NamingConvention, columns map[string][]string) ([]config.AnonymizeRule, error) {
+	patterns := make([]*regexp.Regexp, len(conventions))
+	for i, c := range conventions {
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid naming convention %q: %w", c.Pattern, err)
+		}
+		patterns[i] = re
+	}
+
+	tables := make([]string, 0, len(columns))
+	for table := range columns {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var rules []config.AnonymizeRule
+	for _, table := range tables {
+		for _, column := range columns[table] {
+			for i, re := range patterns {
+				if re.MatchString(ruleKey(table, column)) {
+					rules = append(rules, config.AnonymizeRule{
+						Table:    table,
+						Column:   column,
+						Strategy: conventions[i].Strategy,
+						Options:  conventions[i].Options,
+					})
+					break
+				}
+			}
+		}
+	}
+	return rules, nil
+}
+
+// ListColumns returns the columns of every table in the public schema
+func ListColumns(ctx context.Context, db *sql.DB) (map[string][]string, error) {
+	query := `
+		SELECT table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema = 'public'
+		ORDER BY table_name, ordinal_position
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string][]string)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		columns[table] = append(columns[table], column)
+	}
+	return columns, rows.Err()
+}
+
+// GenerateRulesReport lists the effective strategy of every column and where
+// it comes from. Columns copied in clear are only listed when all is set.
+func (a *Anonymizer) GenerateRulesReport(columns map[string][]string, all bool) string {
+	var report string
+	report += "\n========================================\n"
+	report += "       EFFECTIVE ANONYMIZATION RULES     \n"
+	report += "========================================\n\n"
+
+	tables := make([]string, 0, len(columns))
+	for table := range columns {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	counts := make(map[string]int)
+	known := make(map[string]bool)
+	for _, table := range tables {
+		for _, column := range columns[table] {
+			known[ruleKey(table, column)] = true
+			strategy, source := a.ColumnStrategy(table, column)
+			counts[source]++
+			if strategy == StrategyClear && !all {
+				continue
+			}
+			report += fmt.Sprintf("%-40s %-14s [%s]%s\n", ruleKey(table, column), strategy, source, a.formatOptions(table, column))
+		}
+	}
+
+	// Rules that don't match any column are most likely typos
+	var stale []string
+	for key, rule := range a.rules {
+		if !known[key] {
+			stale = append(stale, fmt.Sprintf("%-40s %-14s [%s]", key, rule.Strategy, a.origins[key]))
+		}
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		report += "\nRules without a matching column:\n"
+		for _, s := range stale {
+			report += "  ✗ " + s + "\n"
+		}
+	}
+
+	report += "\n========================================\n"
+	for _, source := range []string{SourceRule, SourceComment, SourceEnt, SourceConvention, SourceHeuristic, SourceNone} {
+		report += fmt.Sprintf("%-12s %d\n", source+":", counts[source])
+	}
+	report += "========================================\n"
+
+	return report
+}
+
+// formatOptions renders the options of a column's rule as " key=value ..."
+func (a *Anonymizer) formatOptions(table, column string) string {
+	rule, ok := a.rules[ruleKey(table, column)]
+	if !ok || len(rule.Options) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(rule.Options))
+	for k := range rule.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var s string
+	for _, k := range keys {
+		s += fmt.Sprintf(" %s=%s", k, rule.Options[k])
+	}
+	return s
+}
@@ -0,0 +1,273 @@
+package anonymizer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	mathrand "math/rand"
+	"strings"
+	"time"
+)
+
+var (
+	fakeFirstNames = []string{
+		"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda",
+		"David", "Elizabeth", "William", "Barbara", "Richard", "Susan", "Joseph", "Jessica",
+		"Thomas", "Sarah", "Charles", "Karen", "Daniel", "Lisa", "Matthew", "Nancy",
+	}
+	fakeLastNames = []string{
+		"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis",
+		"Rodriguez", "Martinez", "Hernandez", "Lopez", "Gonzalez", "Wilson", "Anderson", "Thomas",
+		"Taylor", "Moore", "Jackson", "Martin", "Lee", "Perez", "Thompson", "White",
+	}
+	fakeStreets = []string{
+		"Main Street", "Oak Avenue", "Pine Road", "Maple Lane", "Cedar Court",
+		"Elm Street", "Park Avenue", "Lake Drive", "Hill Road", "River Lane",
+	}
+	fakeCities = []string{
+		"Springfield", "Riverside", "Fairview", "Madison", "Georgetown",
+		"Franklin", "Clinton", "Greenville", "Bristol", "Salem",
+	}
+	fakeWords = []string{
+		"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit",
+		"sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "magna",
+	}
+	fakeDomains = []string{"example.com", "example.org", "example.net"}
+)
+
+// Faker generates synthetic values. It is shared by the anonymizer (to replace
+// detected PII) and by synthetic data generation.
+type Faker struct {
+	rnd *mathrand.Rand
+}
+
+// NewFaker creates a faker with a fixed seed so its output is reproducible
+func NewFaker(seed int64) *Faker {
+	return &Faker{rnd: mathrand.New(mathrand.NewSource(seed))}
+}
+
+// fakerFor returns a faker for replacing a value. With an anonymization key the
+// faker is seeded from the value so the same input always maps to the same fake;
+// without one it is seeded randomly so the output can't be linked to the input.
+func (a *Anonymizer) fakerFor(value string) *Faker {
+	if len(a.key) == 0 {
+		return NewFaker(randomSeed())
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(value))
+	return NewFaker(int64(binary.BigEndian.Uint64(mac.Sum(nil)[:8])))
+}
+
+// randomSeed returns a seed from the system's secure random source
+func randomSeed() int64 {
+	var seed [8]byte
+	_, _ = rand.Read(seed[:])
+	return int64(binary.BigEndian.Uint64(seed[:]))
+}
+
+// Intn returns a random integer in [0, n)
+func (f *Faker) Intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return f.rnd.Intn(n)
+}
+
+// Float64 returns a random float in [0, 1)
+func (f *Faker) Float64() float64 {
+	return f.rnd.Float64()
+}
+
+// NormFloat64 returns a normally distributed float with mean 0 and stddev 1
+func (f *Faker) NormFloat64() float64 {
+	return f.rnd.NormFloat64()
+}
+
+func (f *Faker) pick(values []string) string {
+	return values[f.rnd.Intn(len(values))]
+}
+
+// FirstName returns a fake first name
+func (f *Faker) FirstName() string {
+	return f.pick(fakeFirstNames)
+}
+
+// LastName returns a fake last name
+func (f *Faker) LastName() string {
+	return f.pick(fakeLastNames)
+}
+
+// Name returns a fake full name
+func (f *Faker) Name() string {
+	return f.FirstName() + " " + f.LastName()
+}
+
+// Email returns a fake email address on a reserved example domain
+func (f *Faker) Email() string {
+	return fmt.Sprintf("%s.%s%d@%s",
+		strings.ToLower(f.FirstName()), strings.ToLower(f.LastName()), f.rnd.Intn(1000), f.pick(fakeDomains))
+}
+
+// Phone returns a fake phone number in the 555 range
+func (f *Faker) Phone() string {
+	return fmt.Sprintf("+1-555-%03d-%04d", f.rnd.Intn(1000), f.rnd.Intn(10000))
+}
+
+// Address returns a fake street address
+func (f *Faker) Address() string {
+	return fmt.Sprintf("%d %s, %s", f.rnd.Intn(9999)+1, f.pick(fakeStreets), f.pick(fakeCities))
+}
+
+// SSN returns a fake social security number in the never-issued 9xx area
+func (f *Faker) SSN() string {
+	return fmt.Sprintf("9%02d-%02d-%04d", f.rnd.Intn(100), f.rnd.Intn(99)+1, f.rnd.Intn(9999)+1)
+}
+
+// IPv4 returns an address from the TEST-NET-3 documentation range
+func (f *Faker) IPv4() string {
+	return fmt.Sprintf("203.0.113.%d", f.rnd.Intn(254)+1)
+}
+
+// Word returns a filler word
+func (f *Faker) Word() string {
+	return f.pick(fakeWords)
+}
+
+// Sentence returns a filler sentence with the given number of words
+func (f *Faker) Sentence(words int) string {
+	parts := make([]string, words)
+	for i := range parts {
+		parts[i] = f.Word()
+	}
+	s := strings.Join(parts, " ")
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
+
+// Like returns a random string with the same layout as s: digits are replaced
+// by digits, letters by letters of the same case, everything else is kept
+func (f *Faker) Like(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		switch classOf(r) {
+		case classDigit:
+			runes[i] = rune('0' + f.rnd.Intn(10))
+		case classLower:
+			runes[i] = rune('a' + f.rnd.Intn(26))
+		case classUpper:
+			runes[i] = rune('A' + f.rnd.Intn(26))
+		}
+	}
+	return string(runes)
+}
+
+// CreditCardLike returns a random Luhn-valid card number with the same layout as cc
+func (f *Faker) CreditCardLike(cc string) string {
+	runes := []rune(f.Like(cc))
+
+	var digitPos []int
+	for i, r := range runes {
+		if classOf(r) == classDigit {
+			digitPos = append(digitPos, i)
+		}
+	}
+	if len(digitPos) < 2 {
+		return string(runes)
+	}
+
+	digits := make([]int, len(digitPos))
+	for j, i := range digitPos {
+		digits[j] = int(runes[i] - '0')
+	}
+	last := len(digits) - 1
+	for d := 0; d <= 9; d++ {
+		digits[last] = d
+		if luhnValid(digits) {
+			break
+		}
+	}
+	runes[digitPos[last]] = rune('0' + digits[last])
+
+	return string(runes)
+}
+
+// IBANLike returns a random IBAN with the same country, layout and valid check digits
+func (f *Faker) IBANLike(iban string) string {
+	runes := []rune(iban)
+
+	var alnum []int
+	for i, r := range runes {
+		if classOf(r) != classOther {
+			alnum = append(alnum, i)
+		}
+	}
+	if len(alnum) < 5 {
+		return f.Like(iban)
+	}
+
+	bban := make([]rune, 0, len(alnum)-4)
+	for _, i := range alnum[4:] {
+		bban = append(bban, runes[i])
+	}
+	fake := []rune(f.Like(string(bban)))
+	for j, i := range alnum[4:] {
+		runes[i] = fake[j]
+	}
+
+	country := string([]rune{runes[alnum[0]], runes[alnum[1]]})
+	check := ibanCheckDigits(country, string(fake))
+	runes[alnum[2]] = rune(check[0])
+	runes[alnum[3]] = rune(check[1])
+
+	return string(runes)
+}
+
+// IntRange returns a random integer in [min, max]
+func (f *Faker) IntRange(min, max int64) int64 {
+	if max <= min {
+		return min
+	}
+	return min + f.rnd.Int63n(max-min+1)
+}
+
+// Bool returns a random boolean
+func (f *Faker) Bool() bool {
+	return f.rnd.Intn(2) == 1
+}
+
+// TimeBetween returns a random time in [from, to)
+func (f *Faker) TimeBetween(from, to time.Time) time.Time {
+	span := to.Sub(from)
+	if span <= 0 {
+		return from
+	}
+	return from.Add(time.Duration(f.rnd.Int63n(int64(span)))).Truncate(time.Second)
+}
+
+// UUID returns a random version 4 UUID
+func (f *Faker) UUID() string {
+	var b [16]byte
+	f.rnd.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Bytes returns n random bytes
+func (f *Faker) Bytes(n int) []byte {
+	b := make([]byte, n)
+	f.rnd.Read(b)
+	return b
+}
+
+// Pick returns a random element of values
+func (f *Faker) Pick(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return f.pick(values)
+}
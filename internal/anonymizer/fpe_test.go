@@ -0,0 +1,149 @@
+package anonymizer
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+func newKeyedAnonymizer() *Anonymizer {
+	return NewAnonymizer(&config.AnonymizationConfig{Key: "test-key"})
+}
+
+func TestLuhnValid(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"4111 1111 1111 1111", true},
+		{"5500-0000-0000-0004", true},
+		{"378282246310005", true},
+		{"4111111111111112", false},
+		{"79927398713", true},
+		{"79927398710", false},
+	}
+	for _, tt := range tests {
+		if got := luhnValid(digitsOf(tt.number)); got != tt.want {
+			t.Errorf("luhnValid(%s) = %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestIBANCheckDigits(t *testing.T) {
+	tests := []struct {
+		iban string
+	}{
+		{"GB82WEST12345698765432"},
+		{"DE89370400440532013000"},
+		{"FR1420041010050500013M02606"},
+		{"NL91ABNA0417164300"},
+	}
+	for _, tt := range tests {
+		if got := ibanCheckDigits(tt.iban[:2], tt.iban[4:]); got != tt.iban[2:4] {
+			t.Errorf("ibanCheckDigits(%s) = %s, want %s", tt.iban, got, tt.iban[2:4])
+		}
+	}
+}
+
+func TestFPEEncryptIsBijective(t *testing.T) {
+	c := &fpeCipher{key: []byte("test-key")}
+	for _, n := range []int{1, 2, 3} {
+		size := 1
+		for i := 0; i < n; i++ {
+			size *= 10
+		}
+		seen := make(map[string]bool, size)
+		for x := 0; x < size; x++ {
+			numerals := intToNumerals(bigInt(x), 10, n)
+			c.encrypt(numerals, 10, "tweak")
+			key := fmt.Sprint(numerals)
+			if seen[key] {
+				t.Fatalf("length %d: %d collides with an earlier value", n, x)
+			}
+			seen[key] = true
+		}
+	}
+}
+
+func TestFPECreditCard(t *testing.T) {
+	a := newKeyedAnonymizer()
+	tests := []struct {
+		card           string
+		prefix, suffix int
+	}{
+		{"4111 1111 1111 1111", 6, 4},
+		{"5500-0000-0000-0004", 0, 0},
+		{"378282246310005", 6, 0},
+	}
+	for _, tt := range tests {
+		got, err := a.FPECreditCard(tt.card, "users.card", tt.prefix, tt.suffix)
+		if err != nil {
+			t.Fatalf("FPECreditCard(%s) error = %v", tt.card, err)
+		}
+		if len(got) != len(tt.card) || strings.Map(keepSeparators, got) != strings.Map(keepSeparators, tt.card) {
+			t.Errorf("FPECreditCard(%s) = %s, layout changed", tt.card, got)
+		}
+		if !luhnValid(digitsOf(got)) {
+			t.Errorf("FPECreditCard(%s) = %s, not Luhn-valid", tt.card, got)
+		}
+		in, out := digitsOf(tt.card), digitsOf(got)
+		if fmt.Sprint(in[:tt.prefix]) != fmt.Sprint(out[:tt.prefix]) ||
+			fmt.Sprint(in[len(in)-tt.suffix:]) != fmt.Sprint(out[len(out)-tt.suffix:]) {
+			t.Errorf("FPECreditCard(%s) = %s, preserved digits changed", tt.card, got)
+		}
+		if again, _ := a.FPECreditCard(tt.card, "users.card", tt.prefix, tt.suffix); again != got {
+			t.Errorf("FPECreditCard(%s) is not deterministic: %s, %s", tt.card, got, again)
+		}
+	}
+}
+
+func TestFPEIBAN(t *testing.T) {
+	a := newKeyedAnonymizer()
+	for _, iban := range []string{"GB82WEST12345698765432", "DE89 3704 0044 0532 0130 00"} {
+		got, err := a.FPEIBAN(iban, "accounts.iban")
+		if err != nil {
+			t.Fatalf("FPEIBAN(%s) error = %v", iban, err)
+		}
+		if got == iban || len(got) != len(iban) || got[:2] != iban[:2] {
+			t.Errorf("FPEIBAN(%s) = %s, want another IBAN of the same country and layout", iban, got)
+		}
+		compact := strings.ReplaceAll(got, " ", "")
+		if check := ibanCheckDigits(compact[:2], compact[4:]); check != compact[2:4] {
+			t.Errorf("FPEIBAN(%s) = %s, check digits should be %s", iban, got, check)
+		}
+	}
+}
+
+func TestFPEString(t *testing.T) {
+	a := newKeyedAnonymizer()
+	got, err := a.FPEString("AB-1234-cd", "tweak", 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "AB-") || len(got) != len("AB-1234-cd") {
+		t.Errorf("FPEString() = %s, want the prefix and layout kept", got)
+	}
+	for i, r := range got {
+		if classOf(r) != classOf(rune("AB-1234-cd"[i])) {
+			t.Errorf("FPEString() = %s, character class changed at %d", got, i)
+		}
+	}
+
+	if _, err := NewAnonymizer(nil).FPEString("x1", "tweak", 0, 0); err == nil {
+		t.Error("FPEString() without a key succeeded")
+	}
+}
+
+func keepSeparators(r rune) rune {
+	if r >= '0' && r <= '9' {
+		return '#'
+	}
+	return r
+}
+
+func bigInt(x int) *big.Int {
+	return big.NewInt(int64(x))
+}
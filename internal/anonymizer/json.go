@@ -0,0 +1,326 @@
+package anonymizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+// applyJSON anonymizes the nodes of a JSON document matched by the rule's paths.
+// Values may be strings (json columns) or []byte (jsonb columns as returned by
+// lib/pq); the result has the same Go type as the input.
+func applyJSON(a *Anonymizer, f *Field) (interface{}, error) {
+	var raw []byte
+	switch v := f.Value.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return f.Value, nil
+	}
+
+	doc, err := parseJSONDocument(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON document: %w", err)
+	}
+
+	for _, p := range f.Rule.Paths {
+		path, err := parseJSONPath(p.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %w", p.Path, err)
+		}
+
+		rule := pathRule(f.Rule, p)
+		var applyErr error
+		doc.walk(path, func(n *jsonNode) {
+			if applyErr != nil {
+				return
+			}
+			applyErr = a.anonymizeJSONNode(f, rule, n)
+		})
+		if applyErr != nil {
+			return nil, fmt.Errorf("path %s: %w", p.Path, applyErr)
+		}
+	}
+
+	var buf bytes.Buffer
+	doc.encode(&buf)
+
+	if _, ok := f.Value.(string); ok {
+		return buf.String(), nil
+	}
+	return buf.Bytes(), nil
+}
+
+// anonymizeJSONNode runs a strategy on a matched node. Scalars are handed to the
+// strategy as their natural Go type; containers can only be nulled or kept.
+func (a *Anonymizer) anonymizeJSONNode(doc *Field, rule *config.AnonymizeRule, n *jsonNode) error {
+	if n.kind != jsonScalar {
+		switch rule.Strategy {
+		case "null":
+			*n = jsonNode{kind: jsonScalar}
+		case "keep":
+		default:
+			return fmt.Errorf("matches an object or array, which only the null and keep strategies apply to")
+		}
+		return nil
+	}
+
+	value := n.scalar
+	if num, ok := value.(json.Number); ok {
+		if i, err := num.Int64(); err == nil {
+			value = i
+		} else if f, err := num.Float64(); err == nil {
+			value = f
+		}
+	}
+
+	out, err := a.applyRule(&Field{Table: doc.Table, Column: rule.Column, Value: value, Rule: rule, Row: doc.Row})
+	if err != nil {
+		return err
+	}
+
+	// A number the strategy left as it was keeps its original text, e.g. 1.0,
+	// and precision beyond float64
+	if _, ok := n.scalar.(json.Number); ok && out == value {
+		return nil
+	}
+
+	switch v := out.(type) {
+	case nil, string, bool, json.Number:
+		n.scalar = v
+	case []byte:
+		n.scalar = string(v)
+	case int64:
+		n.scalar = json.Number(strconv.FormatInt(v, 10))
+	case float64:
+		n.scalar = json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		n.scalar = fmt.Sprint(v)
+	}
+	return nil
+}
+
+// pathRule builds the rule applied to nodes matched by a path rule
+func pathRule(parent *config.AnonymizeRule, p config.PathRule) *config.AnonymizeRule {
+	return &config.AnonymizeRule{
+		Table:    parent.Table,
+		Column:   parent.Column + p.Path,
+		Strategy: p.Strategy,
+		Options:  p.Options,
+	}
+}
+
+type jsonKind int
+
+const (
+	jsonScalar jsonKind = iota
+	jsonObject
+	jsonArray
+)
+
+// jsonNode is an order-preserving JSON document node. encoding/json maps lose
+// key order, which would rewrite every json column even when nothing matched.
+type jsonNode struct {
+	kind   jsonKind
+	keys   []string    // object keys in document order
+	values []*jsonNode // object values or array elements
+	scalar interface{} // string, json.Number, bool or nil
+}
+
+func parseJSONDocument(raw []byte) (*jsonNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	node, err := decodeJSONNode(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after document")
+	}
+	return node, nil
+}
+
+func decodeJSONNode(dec *json.Decoder) (*jsonNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			node := &jsonNode{kind: jsonObject}
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSONNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, keyTok.(string))
+				node.values = append(node.values, value)
+			}
+			_, err := dec.Token() // closing }
+			return node, err
+		case '[':
+			node := &jsonNode{kind: jsonArray}
+			for dec.More() {
+				value, err := decodeJSONNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.values = append(node.values, value)
+			}
+			_, err := dec.Token() // closing ]
+			return node, err
+		default:
+			return nil, fmt.Errorf("unexpected delimiter %s", t)
+		}
+	default:
+		return &jsonNode{kind: jsonScalar, scalar: t}, nil
+	}
+}
+
+// encode writes the node as compact JSON
+func (n *jsonNode) encode(buf *bytes.Buffer) {
+	switch n.kind {
+	case jsonObject:
+		buf.WriteByte('{')
+		for i, key := range n.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, key)
+			buf.WriteByte(':')
+			n.values[i].encode(buf)
+		}
+		buf.WriteByte('}')
+	case jsonArray:
+		buf.WriteByte('[')
+		for i, v := range n.values {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			v.encode(buf)
+		}
+		buf.WriteByte(']')
+	default:
+		switch v := n.scalar.(type) {
+		case nil:
+			buf.WriteString("null")
+		case string:
+			writeJSONString(buf, v)
+		case json.Number:
+			buf.WriteString(v.String())
+		case bool:
+			buf.WriteString(strconv.FormatBool(v))
+		}
+	}
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
+
+// jsonPathSegment is one step of a parsed JSON path
+type jsonPathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath parses the supported JSONPath subset: $, .key, ['key'], [n], [*] and .*
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path must start with $")
+	}
+
+	var segments []jsonPathSegment
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			if key == "" {
+				return nil, fmt.Errorf("empty key")
+			}
+			if key == "*" {
+				segments = append(segments, jsonPathSegment{wildcard: true})
+			} else {
+				segments = append(segments, jsonPathSegment{key: key})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated [")
+			}
+			inner := rest[1:end]
+			switch {
+			case inner == "*":
+				segments = append(segments, jsonPathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, jsonPathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil || idx < 0 {
+					return nil, fmt.Errorf("invalid index %q", inner)
+				}
+				segments = append(segments, jsonPathSegment{index: idx, isIndex: true})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected character %q", rest[0])
+		}
+	}
+
+	return segments, nil
+}
+
+// walk calls fn for every node matched by path
+func (n *jsonNode) walk(path []jsonPathSegment, fn func(*jsonNode)) {
+	if len(path) == 0 {
+		fn(n)
+		return
+	}
+
+	seg := path[0]
+	switch {
+	case seg.wildcard:
+		if n.kind == jsonObject || n.kind == jsonArray {
+			for _, child := range n.values {
+				child.walk(path[1:], fn)
+			}
+		}
+	case seg.isIndex:
+		if n.kind == jsonArray && seg.index < len(n.values) {
+			n.values[seg.index].walk(path[1:], fn)
+		}
+	default:
+		if n.kind == jsonObject {
+			for i, key := range n.keys {
+				if key == seg.key {
+					n.values[i].walk(path[1:], fn)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package anonymizer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []jsonPathSegment
+		wantErr bool
+	}{
+		{path: "$"},
+		{path: "$.customer.email", want: []jsonPathSegment{{key: "customer"}, {key: "email"}}},
+		{path: "$.addresses[*].street", want: []jsonPathSegment{{key: "addresses"}, {wildcard: true}, {key: "street"}}},
+		{path: "$.items[2]", want: []jsonPathSegment{{key: "items"}, {index: 2, isIndex: true}}},
+		{path: "$['first name'][\"x.y\"]", want: []jsonPathSegment{{key: "first name"}, {key: "x.y"}}},
+		{path: "$.*", want: []jsonPathSegment{{wildcard: true}}},
+		{path: "customer.email", wantErr: true},
+		{path: "$..email", wantErr: true},
+		{path: "$.items[2", wantErr: true},
+		{path: "$.items[-1]", wantErr: true},
+		{path: "$.items[x]", wantErr: true},
+		{path: "$email", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseJSONPath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseJSONPath(%s) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseJSONPath(%s) = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestApplyJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		paths []config.PathRule
+		want  string
+	}{
+		{
+			name:  "key order and untouched values are kept",
+			doc:   `{"z": 1, "a": {"email": "x@y.z", "n": 1.50}, "m": [true, null]}`,
+			paths: []config.PathRule{{Path: "$.a.email", Strategy: "null"}},
+			want:  `{"z":1,"a":{"email":null,"n":1.50},"m":[true,null]}`,
+		},
+		{
+			name:  "wildcards match every element",
+			doc:   `{"addresses": [{"street": "a"}, {"street": "b", "city": "c"}]}`,
+			paths: []config.PathRule{{Path: "$.addresses[*].street", Strategy: "null"}},
+			want:  `{"addresses":[{"street":null},{"street":null,"city":"c"}]}`,
+		},
+		{
+			name:  "indexes past the end match nothing",
+			doc:   `{"items": [1, 2]}`,
+			paths: []config.PathRule{{Path: "$.items[5]", Strategy: "null"}},
+			want:  `{"items":[1,2]}`,
+		},
+		{
+			name:  "containers can be nulled",
+			doc:   `{"card": {"number": "4111"}, "id": 7}`,
+			paths: []config.PathRule{{Path: "$.card", Strategy: "null"}},
+			want:  `{"card":null,"id":7}`,
+		},
+		{
+			name:  "containers can be kept",
+			doc:   `{"card": {"number": "4111"}}`,
+			paths: []config.PathRule{{Path: "$.card", Strategy: "keep"}},
+			want:  `{"card":{"number":"4111"}}`,
+		},
+		{
+			name:  "numbers left unchanged keep their text",
+			doc:   `{"a": 1.0, "b": 12345678901234567890123, "c": 1e2}`,
+			paths: []config.PathRule{{Path: "$.*", Strategy: "keep"}},
+			want:  `{"a":1.0,"b":12345678901234567890123,"c":1e2}`,
+		},
+		{
+			name: "numbers are handed over as numbers",
+			doc:  `{"age": 37, "score": 12.5}`,
+			paths: []config.PathRule{
+				{Path: "$.age", Strategy: "bucket", Options: map[string]string{"size": "10"}},
+				{Path: "$.score", Strategy: "bucket", Options: map[string]string{"size": "5"}},
+			},
+			want: `{"age":30,"score":10}`,
+		},
+	}
+
+	a := NewAnonymizer(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &config.AnonymizeRule{Table: "orders", Column: "details", Strategy: "json", Paths: tt.paths}
+
+			got, err := applyJSON(a, &Field{Table: "orders", Column: "details", Value: tt.doc, Rule: rule})
+			if err != nil {
+				t.Fatalf("applyJSON() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("applyJSON() = %v, want %v", got, tt.want)
+			}
+
+			// jsonb is read as []byte and must be written back as []byte
+			got, err = applyJSON(a, &Field{Table: "orders", Column: "details", Value: []byte(tt.doc), Rule: rule})
+			if err != nil {
+				t.Fatalf("applyJSON() error = %v", err)
+			}
+			if b, ok := got.([]byte); !ok || string(b) != tt.want {
+				t.Errorf("applyJSON() = %#v, want []byte %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyJSONErrors(t *testing.T) {
+	a := NewAnonymizer(nil)
+	tests := []struct {
+		name string
+		doc  string
+		path config.PathRule
+	}{
+		{"invalid document", `{"a": `, config.PathRule{Path: "$.a", Strategy: "null"}},
+		{"trailing data", `{"a": 1} {}`, config.PathRule{Path: "$.a", Strategy: "null"}},
+		{"invalid path", `{"a": 1}`, config.PathRule{Path: "a", Strategy: "null"}},
+		{"unknown strategy", `{"a": 1}`, config.PathRule{Path: "$.a", Strategy: "nope"}},
+		{"scalar strategy on a container", `{"card": {"number": "4111"}}`, config.PathRule{Path: "$.card", Strategy: "bucket"}},
+	}
+
+	for _, tt := range tests {
+		rule := &config.AnonymizeRule{Table: "orders", Column: "details", Strategy: "json", Paths: []config.PathRule{tt.path}}
+		if _, err := applyJSON(a, &Field{Value: tt.doc, Rule: rule}); err == nil {
+			t.Errorf("%s: applyJSON() succeeded", tt.name)
+		}
+	}
+
+	// Values that are not documents, e.g. NULL, pass through
+	rule := &config.AnonymizeRule{Strategy: "json", Paths: []config.PathRule{{Path: "$.a", Strategy: "null"}}}
+	if got, err := applyJSON(a, &Field{Value: nil, Rule: rule}); got != nil || err != nil {
+		t.Errorf("applyJSON(nil) = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,358 @@
+package anonymizer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+// applyNoise adds bounded, zero-mean random noise to a numeric value so
+// aggregates like the column mean stay close to the original
+func applyNoise(a *Anonymizer, f *Field) (interface{}, error) {
+	x, decimals, ok := numericValue(f.Value)
+	if !ok {
+		return f.Value, nil
+	}
+
+	scale, err := optionFloat(f.Rule, "scale", 1)
+	if err != nil {
+		return nil, err
+	}
+	bound, err := optionFloat(f.Rule, "bound", 3*scale)
+	if err != nil {
+		return nil, err
+	}
+
+	var noise float64
+	a.mu.Lock()
+	// Resample values outside the bound instead of clamping them, which would
+	// pile up values at the edges
+	for attempt := 0; attempt < 10; attempt++ {
+		switch f.Rule.Options["distribution"] {
+		case "gaussian":
+			noise = a.rnd.NormFloat64() * scale
+		default:
+			u := a.rnd.Float64() - 0.5
+			noise = -scale * math.Copysign(1, u) * math.Log(1-2*math.Abs(u))
+		}
+		if math.Abs(noise) <= bound {
+			break
+		}
+	}
+	a.mu.Unlock()
+	noise = math.Max(-bound, math.Min(bound, noise))
+
+	min, err := optionFloat(f.Rule, "min", math.Inf(-1))
+	if err != nil {
+		return nil, err
+	}
+	max, err := optionFloat(f.Rule, "max", math.Inf(1))
+	if err != nil {
+		return nil, err
+	}
+	result := math.Max(min, math.Min(max, x+noise))
+
+	return numericLike(f.Value, result, decimals), nil
+}
+
+// applyBucket rounds a number down to a multiple of size (or to the bucket
+// midpoint with mode=mid, nearest multiple with mode=nearest). Dates and
+// timestamps are truncated to the start of the configured unit.
+func applyBucket(a *Anonymizer, f *Field) (interface{}, error) {
+	if t, ok := f.Value.(time.Time); ok {
+		return truncateTime(t, f.Rule.Options["unit"]), nil
+	}
+
+	x, decimals, ok := numericValue(f.Value)
+	if !ok {
+		return f.Value, nil
+	}
+
+	size, err := optionFloat(f.Rule, "size", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	var result float64
+	switch f.Rule.Options["mode"] {
+	case "nearest":
+		result = math.Round(x/size) * size
+	case "mid":
+		result = math.Floor(x/size)*size + size/2
+	default:
+		result = math.Floor(x/size) * size
+	}
+
+	return numericLike(f.Value, result, decimals), nil
+}
+
+// applyScale multiplies a number by a factor that is fixed per column, so the
+// shape of the distribution is kept while the absolute values change
+func applyScale(a *Anonymizer, f *Field) (interface{}, error) {
+	x, decimals, ok := numericValue(f.Value)
+	if !ok {
+		return f.Value, nil
+	}
+
+	factor, err := a.scaleFactor(f.Rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return numericLike(f.Value, x*factor, decimals), nil
+}
+
+// scaleFactor returns the factor for a column: the factor option if set,
+// otherwise a value in [min_factor, max_factor] derived from the key (or chosen
+// once per run without a key)
+func (a *Anonymizer) scaleFactor(rule *config.AnonymizeRule) (float64, error) {
+	if _, ok := rule.Options["factor"]; ok {
+		return optionFloat(rule, "factor", 1)
+	}
+
+	minFactor, err := optionFloat(rule, "min_factor", 0.8)
+	if err != nil {
+		return 0, err
+	}
+	maxFactor, err := optionFloat(rule, "max_factor", 1.2)
+	if err != nil {
+		return 0, err
+	}
+
+	key := ruleKey(rule.Table, rule.Column)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if factor, ok := a.factors[key]; ok {
+		return factor, nil
+	}
+
+	var u float64
+	if len(a.key) > 0 {
+		u = a.keyedFraction("scale|" + key)
+	} else {
+		u = a.rnd.Float64()
+	}
+
+	factor := minFactor + u*(maxFactor-minFactor)
+	a.factors[key] = factor
+	return factor, nil
+}
+
+// applyDateShift moves a date by an offset derived from the row's entity
+// column. Every date that belongs to the same entity (across all tables) moves
+// by the same number of days, so intervals between events are preserved.
+func applyDateShift(a *Anonymizer, f *Field) (interface{}, error) {
+	maxDays, err := optionInt(f.Rule, "max_days", 30)
+	if err != nil {
+		return nil, err
+	}
+	if maxDays == 0 {
+		return f.Value, nil
+	}
+
+	entityColumn := f.Rule.Options["entity_column"]
+	entity, ok := f.Row[entityColumn]
+	if !ok {
+		return nil, fmt.Errorf("entity column %q is not part of the row", entityColumn)
+	}
+
+	var days int
+	if entity == nil {
+		a.mu.Lock()
+		days = a.rnd.Intn(2*maxDays) - maxDays
+		a.mu.Unlock()
+	} else {
+		u := a.keyedFraction("date_shift|" + entityString(entity))
+		days = int(u*float64(2*maxDays)) - maxDays
+	}
+	// Never keep the original date
+	if days >= 0 {
+		days++
+	}
+
+	switch v := f.Value.(type) {
+	case time.Time:
+		return v.AddDate(0, 0, days), nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.AddDate(0, 0, days).Format(layout), nil
+			}
+		}
+		return f.Value, nil
+	default:
+		return f.Value, nil
+	}
+}
+
+// keyedFraction maps a label to a deterministic fraction in [0, 1)
+func (a *Anonymizer) keyedFraction(label string) float64 {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(label))
+	sum := mac.Sum(nil)
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+func entityString(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+func truncateTime(t time.Time, unit string) time.Time {
+	switch unit {
+	case "year":
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	case "quarter":
+		month := time.Month((int(t.Month())-1)/3*3 + 1)
+		return time.Date(t.Year(), month, 1, 0, 0, 0, 0, t.Location())
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -int(day.Weekday()))
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// numericValue extracts a number from the types lib/pq returns for numeric
+// columns. decimals is the number of fractional digits of textual values
+// (NUMERIC is returned as []byte) and -1 for floats.
+func numericValue(v interface{}) (float64, int, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), 0, true
+	case int:
+		return float64(n), 0, true
+	case int32:
+		return float64(n), 0, true
+	case float64:
+		return n, -1, true
+	case float32:
+		return float64(n), -1, true
+	case []byte:
+		return parseDecimal(string(n))
+	case string:
+		return parseDecimal(n)
+	default:
+		return 0, 0, false
+	}
+}
+
+func parseDecimal(s string) (float64, int, bool) {
+	x, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	decimals := 0
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		decimals = len(s) - i - 1
+	}
+	return x, decimals, true
+}
+
+// numericLike converts x back to the Go type of the original value
+func numericLike(orig interface{}, x float64, decimals int) interface{} {
+	switch orig.(type) {
+	case int64:
+		return int64(math.Round(x))
+	case int:
+		return int(math.Round(x))
+	case int32:
+		return int32(math.Round(x))
+	case float32:
+		return float32(x)
+	case []byte:
+		return []byte(strconv.FormatFloat(x, 'f', decimals, 64))
+	case string:
+		return strconv.FormatFloat(x, 'f', decimals, 64)
+	default:
+		return x
+	}
+}
+
+// optionFloat reads a float option from a rule
+func optionFloat(rule *config.AnonymizeRule, name string, def float64) (float64, error) {
+	raw, ok := rule.Options[name]
+	if !ok || raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("option %s of %s.%s must be a number: %w", name, rule.Table, rule.Column, err)
+	}
+	return v, nil
+}
+
+func validateNoise(rule *config.AnonymizeRule) error {
+	switch rule.Options["distribution"] {
+	case "", "laplace", "gaussian":
+	default:
+		return fmt.Errorf("unknown distribution %q for %s.%s", rule.Options["distribution"], rule.Table, rule.Column)
+	}
+	scale, err := optionFloat(rule, "scale", 1)
+	if err != nil {
+		return err
+	}
+	if scale <= 0 {
+		return fmt.Errorf("option scale of %s.%s must be positive", rule.Table, rule.Column)
+	}
+	bound, err := optionFloat(rule, "bound", 3*scale)
+	if err != nil {
+		return err
+	}
+	if bound < 0 {
+		return fmt.Errorf("option bound of %s.%s must not be negative", rule.Table, rule.Column)
+	}
+	min, err := optionFloat(rule, "min", math.Inf(-1))
+	if err != nil {
+		return err
+	}
+	max, err := optionFloat(rule, "max", math.Inf(1))
+	if err != nil {
+		return err
+	}
+	if min > max {
+		return fmt.Errorf("option min of %s.%s must not be above max", rule.Table, rule.Column)
+	}
+	return nil
+}
+
+func validateBucket(rule *config.AnonymizeRule) error {
+	size, err := optionFloat(rule, "size", 10)
+	if err != nil {
+		return err
+	}
+	if size <= 0 {
+		return fmt.Errorf("option size of %s.%s must be positive", rule.Table, rule.Column)
+	}
+	return nil
+}
+
+func validateScale(rule *config.AnonymizeRule) error {
+	for _, name := range []string{"factor", "min_factor", "max_factor"} {
+		if _, err := optionFloat(rule, name, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateDateShift(rule *config.AnonymizeRule) error {
+	if rule.Options["entity_column"] == "" {
+		return fmt.Errorf("date_shift for %s.%s requires the entity_column option", rule.Table, rule.Column)
+	}
+	_, err := optionInt(rule, "max_days", 30)
+	return err
+}
@@ -0,0 +1,184 @@
+package anonymizer
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+func numericField(strategy string, value interface{}, options map[string]string) *Field {
+	return &Field{
+		Table:  "people",
+		Column: "salary",
+		Value:  value,
+		Rule:   &config.AnonymizeRule{Table: "people", Column: "salary", Strategy: strategy, Options: options},
+	}
+}
+
+func TestApplyBucket(t *testing.T) {
+	day := time.Date(2024, 5, 17, 13, 45, 0, 0, time.UTC) // a Friday
+	tests := []struct {
+		name    string
+		value   interface{}
+		options map[string]string
+		want    interface{}
+	}{
+		{"int floor", int64(37), nil, int64(30)},
+		{"negative floor", int64(-3), nil, int64(-10)},
+		{"nearest", int64(37), map[string]string{"mode": "nearest"}, int64(40)},
+		{"mid", int64(37), map[string]string{"mode": "mid"}, int64(35)},
+		{"numeric keeps its decimals", []byte("1234.56"), map[string]string{"size": "100"}, []byte("1200.00")},
+		{"float", 12.5, map[string]string{"size": "0.5"}, 12.5},
+		{"text is left alone", "n/a", nil, "n/a"},
+		{"month by default", day, nil, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"year", day, map[string]string{"unit": "year"}, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"quarter", day, map[string]string{"unit": "quarter"}, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"week starts on sunday", day, map[string]string{"unit": "week"}, time.Date(2024, 5, 12, 0, 0, 0, 0, time.UTC)},
+		{"day", day, map[string]string{"unit": "day"}, time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)},
+	}
+
+	a := NewAnonymizer(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyBucket(a, numericField("bucket", tt.value, tt.options))
+			if err != nil {
+				t.Fatalf("applyBucket() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyBucket() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyNoise(t *testing.T) {
+	a := NewAnonymizer(nil)
+	for _, distribution := range []string{"laplace", "gaussian"} {
+		options := map[string]string{"distribution": distribution, "scale": "5", "bound": "10", "min": "0"}
+		var sum float64
+		const n = 2000
+		for i := 0; i < n; i++ {
+			got, err := applyNoise(a, numericField("noise", 100.0, options))
+			if err != nil {
+				t.Fatalf("applyNoise() error = %v", err)
+			}
+			x := got.(float64)
+			if x < 90 || x > 110 {
+				t.Fatalf("%s: applyNoise(100) = %v, outside the bound", distribution, x)
+			}
+			sum += x
+		}
+		if mean := sum / n; math.Abs(mean-100) > 1 {
+			t.Errorf("%s: mean = %v, want close to 100", distribution, mean)
+		}
+	}
+
+	got, err := applyNoise(a, numericField("noise", int64(1), map[string]string{"scale": "50", "min": "0", "max": "2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := got.(int64); v < 0 || v > 2 {
+		t.Errorf("applyNoise() = %v, want within [0, 2]", v)
+	}
+}
+
+func TestApplyScale(t *testing.T) {
+	got, err := applyScale(NewAnonymizer(nil), numericField("scale", []byte("10.0"), map[string]string{"factor": "1.5"}))
+	if err != nil || string(got.([]byte)) != "15.0" {
+		t.Errorf("applyScale() = %s, %v, want 15.0", got, err)
+	}
+
+	// Without a factor every value of the column is scaled by the same
+	// factor, which is derived from the key
+	options := map[string]string{"min_factor": "0.5", "max_factor": "2"}
+	a := newKeyedAnonymizer()
+	first, _ := applyScale(a, numericField("scale", 100.0, options))
+	second, _ := applyScale(a, numericField("scale", 200.0, options))
+	if factor := first.(float64) / 100; factor < 0.5 || factor > 2 {
+		t.Errorf("factor = %v, want within [0.5, 2]", factor)
+	}
+	if second.(float64) != 2*first.(float64) {
+		t.Errorf("applyScale() = %v, %v, want the same factor", first, second)
+	}
+	other, _ := applyScale(newKeyedAnonymizer(), numericField("scale", 100.0, options))
+	if other != first {
+		t.Errorf("applyScale() = %v in a new run, want %v", other, first)
+	}
+}
+
+func TestApplyDateShift(t *testing.T) {
+	a := newKeyedAnonymizer()
+	options := map[string]string{"entity_column": "patient_id", "max_days": "10"}
+	shift := func(value interface{}, patient interface{}) interface{} {
+		f := numericField("date_shift", value, options)
+		f.Row = map[string]interface{}{"patient_id": patient}
+		got, err := applyDateShift(a, f)
+		if err != nil {
+			t.Fatalf("applyDateShift() error = %v", err)
+		}
+		return got
+	}
+
+	admitted := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	discharged := admitted.AddDate(0, 0, 4)
+	for patient := int64(1); patient <= 50; patient++ {
+		a1 := shift(admitted, patient).(time.Time)
+		d1 := shift(discharged, patient).(time.Time)
+		if d1.Sub(a1) != discharged.Sub(admitted) {
+			t.Fatalf("patient %d: interval changed from %v to %v", patient, discharged.Sub(admitted), d1.Sub(a1))
+		}
+		days := a1.Sub(admitted).Hours() / 24
+		if days == 0 || math.Abs(days) > 10 {
+			t.Fatalf("patient %d: shifted by %v days", patient, days)
+		}
+	}
+
+	// The same entity moves by the same number of days across tables and
+	// value types; bytes and strings of the same id are the same entity
+	if got := shift("2024-03-01", []byte("7")); got != shift(admitted, "7").(time.Time).Format("2006-01-02") {
+		t.Errorf("applyDateShift() = %v, want the shift of the timestamp", got)
+	}
+	if got := shift("not a date", int64(1)); got != "not a date" {
+		t.Errorf("applyDateShift() = %v, want unparsable text kept", got)
+	}
+
+	f := numericField("date_shift", admitted, options)
+	f.Row = map[string]interface{}{"id": 1}
+	if _, err := applyDateShift(a, f); err == nil {
+		t.Error("applyDateShift() without the entity column succeeded")
+	}
+}
+
+func TestValidateNumericOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate func(*config.AnonymizeRule) error
+		options  map[string]string
+		wantErr  bool
+	}{
+		{"noise defaults", validateNoise, nil, false},
+		{"noise distribution", validateNoise, map[string]string{"distribution": "uniform"}, true},
+		{"noise scale", validateNoise, map[string]string{"scale": "0"}, true},
+		{"noise bound", validateNoise, map[string]string{"bound": "wide"}, true},
+		{"noise negative bound", validateNoise, map[string]string{"bound": "-1"}, true},
+		{"noise min", validateNoise, map[string]string{"min": "low"}, true},
+		{"noise max", validateNoise, map[string]string{"max": "high"}, true},
+		{"noise min above max", validateNoise, map[string]string{"min": "10", "max": "0"}, true},
+		{"noise range", validateNoise, map[string]string{"min": "0", "max": "10", "bound": "0"}, false},
+		{"bucket size", validateBucket, map[string]string{"size": "-1"}, true},
+		{"scale factor", validateScale, map[string]string{"factor": "x"}, true},
+		{"date_shift entity", validateDateShift, nil, true},
+		{"date_shift days", validateDateShift, map[string]string{"entity_column": "id", "max_days": "a week"}, true},
+		{"date_shift", validateDateShift, map[string]string{"entity_column": "id"}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.validate(&config.AnonymizeRule{Table: "t", Column: "c", Options: tt.options})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
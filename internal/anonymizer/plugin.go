@@ -0,0 +1,448 @@
+package anonymizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thien/database-migration-tool/internal/config"
+	"github.com/thien/database-migration-tool/internal/logger"
+	"go.uber.org/zap"
+)
+
+// pluginPrefix marks a strategy handled by an external transformer, e.g. plugin:account_numbers
+const pluginPrefix = "plugin:"
+
+// PluginRequest is written to the plugin's stdin as one JSON line per batch
+type PluginRequest struct {
+	ID       int64             `json:"id"`
+	Table    string            `json:"table"`
+	Column   string            `json:"column"`
+	Options  map[string]string `json:"options,omitempty"`
+	Encoding string            `json:"encoding,omitempty"` // base64 for bytea values, which are returned encoded alike
+	Values   []interface{}     `json:"values"`
+}
+
+// PluginResponse is read from the plugin's stdout as one JSON line per batch.
+// Values must have the same length and order as the request values.
+type PluginResponse struct {
+	ID     int64         `json:"id"`
+	Values []interface{} `json:"values"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// plugin is a long-running transformer process
+type plugin struct {
+	cfg config.PluginConfig
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan []byte
+	done   chan struct{} // closed by stop to release the reader
+	read   chan struct{} // closed when the reader has read stdout to the end
+	nextID int64
+}
+
+func newPlugin(cfg config.PluginConfig) *plugin {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.OnError == "" {
+		cfg.OnError = "fail"
+	}
+	return &plugin{cfg: cfg}
+}
+
+// start launches the plugin process if it isn't running
+func (p *plugin) start() error {
+	if p.cmd != nil {
+		return nil
+	}
+
+	cmd := exec.Command(p.cfg.Command, p.cfg.Args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open plugin stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open plugin stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", p.cfg.Name, err)
+	}
+
+	// Read responses in the background so a hung plugin can be timed out.
+	// Once stopped, the rest of the output is discarded so that the plugin
+	// does not block writing it.
+	lines := make(chan []byte)
+	done := make(chan struct{})
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		defer close(lines)
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				select {
+				case lines <- line:
+				case <-done:
+					_, _ = io.Copy(io.Discard, reader)
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	logger.Debug("Started transformer plugin",
+		zap.String("plugin", p.cfg.Name),
+		zap.Int("pid", cmd.Process.Pid))
+
+	p.cmd = cmd
+	p.stdin = stdin
+	p.lines = lines
+	p.done = done
+	p.read = read
+	return nil
+}
+
+// stop terminates the plugin process; it is restarted on the next batch
+func (p *plugin) stop() {
+	if p.cmd == nil {
+		return
+	}
+	_ = p.stdin.Close()
+	close(p.done)
+
+	// Wait closes stdout, so it only runs once the reader is done with it
+	exited := make(chan struct{})
+	go func() {
+		<-p.read
+		_ = p.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		_ = p.cmd.Process.Kill()
+		<-exited
+	}
+
+	p.cmd = nil
+	p.stdin = nil
+	p.lines = nil
+	p.done = nil
+	p.read = nil
+}
+
+// transform sends one batch of values to the plugin and returns the
+// transformed values; binary values are sent base64-encoded. Failures are
+// handled according to on_error.
+func (p *plugin) transform(table, column string, options map[string]string, values []interface{}, binary bool) ([]interface{}, error) {
+	out, err := p.roundTrip(table, column, options, values, binary)
+	if err == nil {
+		return out, nil
+	}
+
+	if p.cfg.OnError == "null" {
+		logger.Warn("Transformer plugin failed, nulling batch",
+			zap.String("plugin", p.cfg.Name),
+			zap.String("table", table),
+			zap.String("column", column),
+			zap.Int("values", len(values)),
+			zap.Error(err))
+		return make([]interface{}, len(values)), nil
+	}
+	return nil, fmt.Errorf("plugin %s: %w", p.cfg.Name, err)
+}
+
+func (p *plugin) roundTrip(table, column string, options map[string]string, values []interface{}, binary bool) ([]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+
+	p.nextID++
+	req := PluginRequest{
+		ID:      p.nextID,
+		Table:   table,
+		Column:  column,
+		Options: options,
+		Values:  make([]interface{}, len(values)),
+	}
+	if binary {
+		req.Encoding = "base64"
+	}
+	for i, v := range values {
+		req.Values[i] = toPluginValue(v, binary)
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode batch: %w", err)
+	}
+	if _, err := p.stdin.Write(append(payload, '\n')); err != nil {
+		p.stop()
+		return nil, fmt.Errorf("failed to send batch: %w", err)
+	}
+
+	var line []byte
+	select {
+	case l, ok := <-p.lines:
+		if !ok {
+			p.stop()
+			return nil, fmt.Errorf("plugin exited")
+		}
+		line = l
+	case <-time.After(p.cfg.Timeout):
+		// The plugin's state is unknown after a timeout, so restart it
+		p.stop()
+		return nil, fmt.Errorf("batch timed out after %s", p.cfg.Timeout)
+	}
+
+	var resp PluginResponse
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if err := dec.Decode(&resp); err != nil {
+		p.stop()
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if resp.ID != req.ID {
+		p.stop()
+		return nil, fmt.Errorf("response id %d does not match request id %d", resp.ID, req.ID)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("plugin reported: %s", resp.Error)
+	}
+	if len(resp.Values) != len(values) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(values), len(resp.Values))
+	}
+
+	out := make([]interface{}, len(values))
+	for i, v := range resp.Values {
+		if out[i], err = fromPluginValue(values[i], v, binary); err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// toPluginValue converts a database value to its JSON representation.
+// Binary values are base64-encoded; other values read as bytes, e.g.
+// numeric or jsonb, are text.
+func toPluginValue(v interface{}, binary bool) interface{} {
+	switch t := v.(type) {
+	case []byte:
+		if binary {
+			return base64.StdEncoding.EncodeToString(t)
+		}
+		return string(t)
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
+
+// fromPluginValue converts a JSON value back to the Go type of the
+// original, decoding binary values from base64
+func fromPluginValue(orig, v interface{}, binary bool) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch o := orig.(type) {
+	case []byte:
+		if binary {
+			b, err := base64.StdEncoding.DecodeString(pluginString(v))
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64: %w", err)
+			}
+			return b, nil
+		}
+		return []byte(pluginString(v)), nil
+	case string:
+		return pluginString(v), nil
+	case time.Time:
+		if s, ok := v.(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t, nil
+			}
+		}
+		return o, nil
+	case int64:
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return i, nil
+			}
+		}
+	case float64:
+		if n, ok := v.(json.Number); ok {
+			if f, err := n.Float64(); err == nil {
+				return f, nil
+			}
+		}
+	}
+
+	if n, ok := v.(json.Number); ok {
+		return n.String(), nil
+	}
+	return v, nil
+}
+
+func pluginString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+// pluginName returns the plugin referenced by a strategy, "" if it isn't one
+func pluginName(strategy string) string {
+	if !strings.HasPrefix(strategy, pluginPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(strategy, pluginPrefix)
+}
+
+// applyPlugin transforms a single value through its plugin
+func (a *Anonymizer) applyPlugin(f *Field) (interface{}, error) {
+	p, ok := a.plugins[pluginName(f.Rule.Strategy)]
+	if !ok {
+		return nil, fmt.Errorf("unknown plugin %q", pluginName(f.Rule.Strategy))
+	}
+	out, err := p.transform(f.Table, f.Column, f.Rule.Options, []interface{}{f.Value}, a.isBinary(f.Table, f.Column))
+	if err != nil {
+		return nil, fmt.Errorf("failed to anonymize %s.%s: %w", f.Table, f.Column, err)
+	}
+	return out[0], nil
+}
+
+// SetColumnTypes records the data types of the columns of a table, so that
+// plugins are sent bytea values base64-encoded
+func (a *Anonymizer) SetColumnTypes(table string, types map[string]string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for column, dataType := range types {
+		a.binary[ruleKey(table, column)] = dataType == "bytea"
+	}
+}
+
+func (a *Anonymizer) isBinary(table, column string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.binary[ruleKey(table, column)]
+}
+
+// AnonymizeBatch anonymizes a batch of rows in place. Plugin strategies are
+// called once per column for the whole batch instead of once per value.
+func (a *Anonymizer) AnonymizeBatch(table string, columns []string, rows [][]interface{}) error {
+	return a.anonymizeBatch(table, columns, rows, nil)
+}
+
+// AnonymizeBatchColumns anonymizes only the given columns of a batch of rows
+func (a *Anonymizer) AnonymizeBatchColumns(table string, columns []string, rows [][]interface{}, only []string) error {
+	set := make(map[string]bool, len(only))
+	for _, col := range only {
+		set[col] = true
+	}
+	return a.anonymizeBatch(table, columns, rows, func(col string) bool { return set[col] })
+}
+
+func (a *Anonymizer) anonymizeBatch(table string, columns []string, rows [][]interface{}, include func(column string) bool) error {
+	var pluginColumns []int
+	isPlugin := make(map[string]bool)
+	for i, col := range columns {
+		if include != nil && !include(col) {
+			continue
+		}
+		if rule, ok := a.rules[ruleKey(table, col)]; ok && pluginName(rule.Strategy) != "" {
+			pluginColumns = append(pluginColumns, i)
+			isPlugin[col] = true
+		}
+	}
+
+	// Plugin columns are transformed below; give the other strategies the
+	// original row first
+	originals := make([][]interface{}, len(pluginColumns))
+	for j, i := range pluginColumns {
+		originals[j] = make([]interface{}, len(rows))
+		for r, row := range rows {
+			originals[j][r] = row[i]
+		}
+	}
+
+	for _, row := range rows {
+		err := a.anonymizeRow(table, columns, row, func(col string) bool {
+			return !isPlugin[col] && (include == nil || include(col))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for j, i := range pluginColumns {
+		rule := a.rules[ruleKey(table, columns[i])]
+		p, ok := a.plugins[pluginName(rule.Strategy)]
+		if !ok {
+			return fmt.Errorf("unknown plugin %q for %s.%s", pluginName(rule.Strategy), table, columns[i])
+		}
+
+		// NULLs are not sent to the plugin
+		var values []interface{}
+		var index []int
+		for r, v := range originals[j] {
+			if v != nil {
+				values = append(values, v)
+				index = append(index, r)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		out, err := p.transform(table, columns[i], rule.Options, values, a.isBinary(table, columns[i]))
+		if err != nil {
+			return fmt.Errorf("failed to anonymize %s.%s: %w", table, columns[i], err)
+		}
+		for k, r := range index {
+			rows[r][i] = out[k]
+		}
+	}
+
+	return nil
+}
+
+// Close stops all plugin processes
+func (a *Anonymizer) Close() {
+	for _, p := range a.plugins {
+		p.mu.Lock()
+		p.stop()
+		p.mu.Unlock()
+	}
+}
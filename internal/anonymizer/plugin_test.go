@@ -0,0 +1,78 @@
+package anonymizer
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+func TestPluginValueRoundTrip(t *testing.T) {
+	binary := []byte{0x00, 0xff, 0x10, '\n'}
+	sent := toPluginValue(binary, true)
+	if sent != "AP8QCg==" {
+		t.Fatalf("toPluginValue() = %v, want base64", sent)
+	}
+	got, err := fromPluginValue(binary, sent, true)
+	if err != nil || !bytes.Equal(got.([]byte), binary) {
+		t.Fatalf("fromPluginValue() = %v, %v, want %v", got, err, binary)
+	}
+	if _, err := fromPluginValue(binary, "not base64!", true); err == nil {
+		t.Error("fromPluginValue() accepted invalid base64")
+	}
+
+	// Text read as bytes, e.g. numeric, is sent as is
+	if got := toPluginValue([]byte("12.50"), false); got != "12.50" {
+		t.Errorf("toPluginValue() = %v, want 12.50", got)
+	}
+}
+
+func TestPluginEcho(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+
+	// cat answers every request with itself, the values unchanged
+	p := newPlugin(config.PluginConfig{Name: "echo", Command: "cat", Timeout: 5 * time.Second})
+	defer p.stop()
+
+	values := []interface{}{[]byte{0x01, 0x02}, []byte{0xfe}}
+	for i := 0; i < 2; i++ {
+		out, err := p.transform("files", "data", nil, values, true)
+		if err != nil {
+			t.Fatalf("transform() error = %v", err)
+		}
+		for j := range values {
+			if !bytes.Equal(out[j].([]byte), values[j].([]byte)) {
+				t.Errorf("value %d = %v, want %v", j, out[j], values[j])
+			}
+		}
+		// A stopped plugin is restarted on the next batch
+		p.stop()
+	}
+}
+
+func TestPluginStopWhileWriting(t *testing.T) {
+	if _, err := exec.LookPath("yes"); err != nil {
+		t.Skip("yes not available")
+	}
+
+	// The plugin keeps writing lines nobody reads; stopping it must not
+	// hang on the reader
+	p := newPlugin(config.PluginConfig{Name: "yes", Command: "yes", Args: []string{`{"id":0}`}})
+	if err := p.start(); err != nil {
+		t.Fatalf("start() error = %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		p.stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("stop() did not return")
+	}
+}
@@ -0,0 +1,264 @@
+package anonymizer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+// detector finds one kind of PII inside free text
+type detector struct {
+	name     string
+	re       *regexp.Regexp
+	validate func(match string) bool
+	fake     func(f *Faker, match string) string
+}
+
+// builtinDetectors are listed in priority order: when two matches overlap the
+// earlier detector wins (e.g. a card number is not also reported as a phone)
+var builtinDetectors = []*detector{
+	{
+		name: "email",
+		re:   regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		fake: func(f *Faker, _ string) string { return f.Email() },
+	},
+	{
+		name:     "iban",
+		re:       regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?\b`),
+		validate: validIBAN,
+		fake:     func(f *Faker, m string) string { return f.IBANLike(m) },
+	},
+	{
+		name:     "credit_card",
+		re:       regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		validate: func(m string) bool { return luhnValid(digitsOf(m)) },
+		fake:     func(f *Faker, m string) string { return f.CreditCardLike(m) },
+	},
+	{
+		name: "ssn",
+		re:   regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+		fake: func(f *Faker, _ string) string { return f.SSN() },
+	},
+	{
+		name: "phone",
+		re:   regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b`),
+		fake: func(f *Faker, m string) string { return f.Like(m) },
+	},
+	{
+		name: "ipv4",
+		re:   regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+		fake: func(f *Faker, _ string) string { return f.IPv4() },
+	},
+}
+
+// DetectorNames returns the names of the built-in scrub detectors
+func DetectorNames() []string {
+	names := make([]string, len(builtinDetectors))
+	for i, d := range builtinDetectors {
+		names[i] = d.name
+	}
+	return names
+}
+
+// span is a detected PII occurrence within a text
+type span struct {
+	start, end int
+	det        *detector
+}
+
+// Scrub replaces every PII span found by the given detectors with a fake
+// equivalent and returns the new text with a count of replacements per detector
+func (a *Anonymizer) Scrub(text string, detectors []*detector) (string, map[string]int64) {
+	var accepted []span
+	for _, d := range detectors {
+		for _, loc := range d.re.FindAllStringIndex(text, -1) {
+			match := text[loc[0]:loc[1]]
+			if d.validate != nil && !d.validate(match) {
+				continue
+			}
+			s := span{start: loc[0], end: loc[1], det: d}
+			if !overlapsAny(s, accepted) {
+				accepted = append(accepted, s)
+			}
+		}
+	}
+	if len(accepted) == 0 {
+		return text, nil
+	}
+
+	sort.Slice(accepted, func(i, j int) bool { return accepted[i].start < accepted[j].start })
+
+	counts := make(map[string]int64)
+	var b strings.Builder
+	pos := 0
+	for _, s := range accepted {
+		match := text[s.start:s.end]
+		b.WriteString(text[pos:s.start])
+		b.WriteString(s.det.fake(a.fakerFor(match), match))
+		pos = s.end
+		counts[s.det.name]++
+	}
+	b.WriteString(text[pos:])
+
+	return b.String(), counts
+}
+
+// applyScrub implements the scrub strategy
+func applyScrub(a *Anonymizer, f *Field) (interface{}, error) {
+	var text string
+	switch v := f.Value.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return f.Value, nil
+	}
+
+	detectors, err := a.detectorsFor(f.Rule)
+	if err != nil {
+		return nil, err
+	}
+
+	out, counts := a.Scrub(text, detectors)
+	a.recordReplacements(f.Table, f.Column, counts)
+
+	if _, ok := f.Value.([]byte); ok {
+		return []byte(out), nil
+	}
+	return out, nil
+}
+
+// scrubDetectors builds the detector set for a rule. Custom patterns come first
+// so they take precedence over the built-in detectors. The detectors option is a
+// comma-separated list of built-in names ("none" disables them); it defaults to all.
+func scrubDetectors(rule *config.AnonymizeRule) ([]*detector, error) {
+	var detectors []*detector
+
+	names := make([]string, 0, len(rule.Patterns))
+	for name := range rule.Patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		re, err := regexp.Compile(rule.Patterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s for %s.%s: %w", name, rule.Table, rule.Column, err)
+		}
+		detectors = append(detectors, &detector{
+			name: name,
+			re:   re,
+			fake: func(f *Faker, m string) string { return f.Like(m) },
+		})
+	}
+
+	selected := rule.Options["detectors"]
+	switch selected {
+	case "", "all":
+		return append(detectors, builtinDetectors...), nil
+	case "none":
+		return detectors, nil
+	}
+
+	enabled := make(map[string]bool)
+	for _, name := range strings.Split(selected, ",") {
+		enabled[strings.TrimSpace(name)] = true
+	}
+	for _, d := range builtinDetectors {
+		if enabled[d.name] {
+			detectors = append(detectors, d)
+			delete(enabled, d.name)
+		}
+	}
+	for name := range enabled {
+		return nil, fmt.Errorf("unknown detector %q for %s.%s", name, rule.Table, rule.Column)
+	}
+
+	return detectors, nil
+}
+
+func validateScrub(rule *config.AnonymizeRule) error {
+	_, err := scrubDetectors(rule)
+	return err
+}
+
+// detectorsFor returns the cached detector set for a rule
+func (a *Anonymizer) detectorsFor(rule *config.AnonymizeRule) ([]*detector, error) {
+	key := ruleKey(rule.Table, rule.Column)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if detectors, ok := a.detectors[key]; ok {
+		return detectors, nil
+	}
+	detectors, err := scrubDetectors(rule)
+	if err != nil {
+		return nil, err
+	}
+	a.detectors[key] = detectors
+	return detectors, nil
+}
+
+// recordReplacements adds scrub counts to the per-table statistics
+func (a *Anonymizer) recordReplacements(table, column string, counts map[string]int64) {
+	if len(counts) == 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tableCounts, ok := a.replacements[table]
+	if !ok {
+		tableCounts = make(map[string]int64)
+		a.replacements[table] = tableCounts
+	}
+	for name, n := range counts {
+		tableCounts[column+"/"+name] += n
+	}
+}
+
+// Replacements returns the number of scrubbed PII spans for a table, keyed by
+// column/detector
+func (a *Anonymizer) Replacements(table string) map[string]int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := make(map[string]int64, len(a.replacements[table]))
+	for k, v := range a.replacements[table] {
+		out[k] = v
+	}
+	return out
+}
+
+func overlapsAny(s span, spans []span) bool {
+	for _, o := range spans {
+		if s.start < o.end && o.start < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+func digitsOf(s string) []int {
+	var digits []int
+	for _, r := range s {
+		if classOf(r) == classDigit {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	return digits
+}
+
+// validIBAN reports whether s has valid ISO 13616 check digits
+func validIBAN(s string) bool {
+	compact := strings.ReplaceAll(s, " ", "")
+	if len(compact) < 15 {
+		return false
+	}
+	return ibanCheckDigits(compact[:2], compact[4:]) == compact[2:4]
+}
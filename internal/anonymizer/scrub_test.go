@@ -0,0 +1,172 @@
+package anonymizer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+func TestValidIBAN(t *testing.T) {
+	tests := []struct {
+		iban string
+		want bool
+	}{
+		{"GB82WEST12345698765432", true},
+		{"GB82 WEST 1234 5698 7654 32", true},
+		{"DE89370400440532013000", true},
+		{"GB83WEST12345698765432", false},
+		{"GB82WEST1234", false},
+	}
+	for _, tt := range tests {
+		if got := validIBAN(tt.iban); got != tt.want {
+			t.Errorf("validIBAN(%s) = %v, want %v", tt.iban, got, tt.want)
+		}
+	}
+}
+
+func TestScrub(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want map[string]int64
+		pii  []string
+	}{
+		{
+			name: "no PII",
+			text: "Order 12 shipped on 2024-01-02, total 99.50",
+		},
+		{
+			name: "email and phone",
+			text: "Reach me at jane.doe@example.com or (555) 123-4567.",
+			want: map[string]int64{"email": 1, "phone": 1},
+			pii:  []string{"jane.doe@example.com", "123-4567"},
+		},
+		{
+			name: "a card number is not also a phone number",
+			text: "card 4111 1111 1111 1111 exp 12/29",
+			want: map[string]int64{"credit_card": 1},
+			pii:  []string{"4111 1111 1111 1111"},
+		},
+		{
+			name: "digits failing the Luhn check are kept",
+			text: "ref 4111 1111 1111 1112",
+		},
+		{
+			name: "IBANs are validated",
+			text: "pay GB82 WEST 1234 5698 7654 32, not GB00 WEST 1234 5698 7654 32",
+			want: map[string]int64{"iban": 1},
+			pii:  []string{"GB82 WEST 1234 5698 7654 32"},
+		},
+		{
+			name: "ssn and ip address",
+			text: "ssn 123-45-6789 from 192.168.10.20",
+			want: map[string]int64{"ssn": 1, "ipv4": 1},
+			pii:  []string{"123-45-6789", "192.168.10.20"},
+		},
+	}
+
+	a := NewAnonymizer(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, counts := a.Scrub(tt.text, builtinDetectors)
+			if !reflect.DeepEqual(counts, tt.want) {
+				t.Errorf("Scrub() counts = %v, want %v", counts, tt.want)
+			}
+			if tt.want == nil && got != tt.text {
+				t.Errorf("Scrub() = %q, want the text unchanged", got)
+			}
+			for _, pii := range tt.pii {
+				if strings.Contains(got, pii) {
+					t.Errorf("Scrub() = %q, still contains %q", got, pii)
+				}
+			}
+		})
+	}
+}
+
+func TestScrubDetectors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    config.AnonymizeRule
+		want    []string
+		wantErr bool
+	}{
+		{name: "all by default", want: DetectorNames()},
+		{
+			name: "selected in priority order",
+			rule: config.AnonymizeRule{Options: map[string]string{"detectors": "phone, email"}},
+			want: []string{"email", "phone"},
+		},
+		{
+			name: "custom patterns come first",
+			rule: config.AnonymizeRule{
+				Options:  map[string]string{"detectors": "email"},
+				Patterns: map[string]string{"order_id": `ORD-\d+`, "badge": `B\d{4}`},
+			},
+			want: []string{"badge", "order_id", "email"},
+		},
+		{
+			name: "none",
+			rule: config.AnonymizeRule{Options: map[string]string{"detectors": "none"}},
+		},
+		{
+			name:    "unknown detector",
+			rule:    config.AnonymizeRule{Options: map[string]string{"detectors": "email,passport"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			rule:    config.AnonymizeRule{Patterns: map[string]string{"bad": `(`}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detectors, err := scrubDetectors(&tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scrubDetectors() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var names []string
+			for _, d := range detectors {
+				names = append(names, d.name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("scrubDetectors() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyScrub(t *testing.T) {
+	a := NewAnonymizer(nil)
+	rule := &config.AnonymizeRule{
+		Table:    "tickets",
+		Column:   "body",
+		Strategy: "scrub",
+		Options:  map[string]string{"detectors": "email"},
+		Patterns: map[string]string{"order_id": `ORD-\d{6}`},
+	}
+
+	got, err := applyScrub(a, &Field{Table: "tickets", Column: "body", Value: []byte("ORD-123456 from a@b.io"), Rule: rule})
+	if err != nil {
+		t.Fatalf("applyScrub() error = %v", err)
+	}
+	out, ok := got.([]byte)
+	if !ok {
+		t.Fatalf("applyScrub() = %#v, want []byte", got)
+	}
+	if strings.Contains(string(out), "ORD-123456") || strings.Contains(string(out), "a@b.io") {
+		t.Errorf("applyScrub() = %q, PII left", out)
+	}
+
+	if _, err := applyScrub(a, &Field{Table: "tickets", Column: "body", Value: "x@y.org", Rule: rule}); err != nil {
+		t.Fatalf("applyScrub() error = %v", err)
+	}
+	want := map[string]int64{"body/order_id": 1, "body/email": 2}
+	if got := a.Replacements("tickets"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Replacements() = %v, want %v", got, want)
+	}
+}
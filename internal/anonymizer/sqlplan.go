@@ -0,0 +1,124 @@
+package anonymizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// textTypes are the column types whose values reach the strategies as strings
+var textTypes = map[string]bool{
+	"text":              true,
+	"character varying": true,
+	"character":         true,
+}
+
+// sqlStrategies render a strategy as a SQL expression over a quoted column,
+// mirroring the Go implementation. They only apply to text columns.
+var sqlStrategies = map[string]func(a *Anonymizer, col string) string{
+	"email": func(a *Anonymizer, col string) string {
+		domains := make([]string, len(a.domains))
+		for i, d := range a.domains {
+			domains[i] = quoteLiteral(d)
+		}
+		return fmt.Sprintf(
+			"CASE WHEN %[1]s = '' THEN '' "+
+				"WHEN %[1]s NOT LIKE '%%@%%' OR %[1]s LIKE '%%@%%@%%' OR split_part(%[1]s, '@', 1) = '' THEN 'anonymous@example.com' "+
+				"ELSE left(%[1]s, 1) || repeat('*', least(length(split_part(%[1]s, '@', 1)) - 1, 5)) || '@' || "+
+				"(ARRAY[%[2]s])[1 + floor(random() * %[3]d)::int] END",
+			col, strings.Join(domains, ", "), len(domains))
+	},
+	"phone": func(a *Anonymizer, col string) string {
+		return fmt.Sprintf(
+			"CASE WHEN %[1]s = '' THEN '' "+
+				"WHEN length(regexp_replace(%[1]s, '\\D', '', 'g')) >= 10 "+
+				"THEN '+' || left(regexp_replace(%[1]s, '\\D', '', 'g'), 2) || '-555-' || lpad(floor(random() * 10000)::int::text, 4, '0') "+
+				"ELSE '+1-555-0100' END",
+			col)
+	},
+	"name": func(a *Anonymizer, col string) string {
+		return fmt.Sprintf(
+			"CASE WHEN %[1]s = '' THEN '' "+
+				"ELSE coalesce((SELECT string_agg(left(w, 1) || '***', ' ') "+
+				"FROM regexp_split_to_table(btrim(%[1]s), '\\s+') AS parts(w) WHERE w <> ''), 'Anonymous User') END",
+			col)
+	},
+	"password": func(a *Anonymizer, col string) string {
+		// One hash for the whole table instead of one bcrypt run per row
+		return fmt.Sprintf("CASE WHEN %s = '' THEN '' ELSE %s END", col, quoteLiteral(a.AnonymizePassword()))
+	},
+	"ssn": func(a *Anonymizer, col string) string {
+		return fmt.Sprintf("CASE WHEN %s = '' THEN '' ELSE '***-**-' || lpad(floor(random() * 10000)::int::text, 4, '0') END", col)
+	},
+	"credit_card": func(a *Anonymizer, col string) string {
+		return fmt.Sprintf(
+			"CASE WHEN %[1]s = '' THEN '' "+
+				"WHEN length(regexp_replace(%[1]s, '\\D', '', 'g')) >= 4 "+
+				"THEN '****-****-****-' || right(regexp_replace(%[1]s, '\\D', '', 'g'), 4) "+
+				"ELSE '****-****-****-0000' END",
+			col)
+	},
+	"address": func(a *Anonymizer, col string) string {
+		return fmt.Sprintf("CASE WHEN %s = '' THEN '' ELSE (floor(random() * 9999) + 1)::int || ' Anonymous Street, Privacy City, XX 00000' END", col)
+	},
+}
+
+// ServerPlan describes how to anonymize a table that was copied in clear
+type ServerPlan struct {
+	Table       string
+	Assignments []string // "column = expression" for a set-based UPDATE
+	RowColumns  []string // columns whose strategy only exists in Go
+}
+
+// PlanServer splits the columns of a table into those that can be anonymized
+// with one set-based UPDATE and those that must be anonymized row by row.
+// types maps column names to their information_schema data_type.
+func (a *Anonymizer) PlanServer(table string, columns []string, types map[string]string) *ServerPlan {
+	plan := &ServerPlan{Table: table}
+
+	for _, col := range columns {
+		strategy, source := a.ColumnStrategy(table, col)
+		quoted := quoteIdent(col)
+
+		switch {
+		case strategy == StrategyClear || strategy == "keep":
+			continue
+		case strategy == "null":
+			plan.Assignments = append(plan.Assignments, fmt.Sprintf("%s = NULL", quoted))
+			continue
+		}
+
+		// String strategies leave other types untouched, as in Go
+		if fn, ok := sqlStrategies[strategy]; ok {
+			if textTypes[types[col]] {
+				plan.Assignments = append(plan.Assignments, fmt.Sprintf("%s = %s", quoted, fn(a, quoted)))
+			}
+			continue
+		}
+
+		// Heuristics only ever map to the string strategies above
+		if source != SourceHeuristic {
+			plan.RowColumns = append(plan.RowColumns, col)
+		}
+	}
+
+	return plan
+}
+
+// UpdateSQL returns the set-based UPDATE of the plan on a relation holding
+// the table's rows, "" when there is none
+func (p *ServerPlan) UpdateSQL(relation string) string {
+	if len(p.Assignments) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("UPDATE %s SET %s", quoteIdent(relation), strings.Join(p.Assignments, ", "))
+}
+
+// quoteIdent quotes a SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes a SQL string literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
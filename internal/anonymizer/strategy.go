@@ -0,0 +1,154 @@
+package anonymizer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+// Field carries a single column value through an anonymization strategy
+type Field struct {
+	Table  string
+	Column string
+	Value  interface{}
+	Rule   *config.AnonymizeRule
+	Row    map[string]interface{} // original values of the row, nil for single values
+}
+
+// StrategyFunc anonymizes a single field value according to its rule
+type StrategyFunc func(a *Anonymizer, f *Field) (interface{}, error)
+
+// strategy describes a named anonymization strategy
+type strategy struct {
+	apply    StrategyFunc
+	keyed    bool                              // requires anonymization.key
+	vaulted  bool                              // requires the token vault passphrase
+	validate func(*config.AnonymizeRule) error // checks the rule options up front
+}
+
+// strategies holds every strategy that can be referenced from a rule
+var strategies = map[string]strategy{
+	"keep": {apply: func(a *Anonymizer, f *Field) (interface{}, error) { return f.Value, nil }},
+	"null": {apply: func(a *Anonymizer, f *Field) (interface{}, error) { return nil, nil }},
+
+	"email":       {apply: stringStrategy(func(a *Anonymizer, s string) string { return a.AnonymizeEmail(s) })},
+	"phone":       {apply: stringStrategy(func(a *Anonymizer, s string) string { return a.AnonymizePhone(s) })},
+	"name":        {apply: stringStrategy(func(a *Anonymizer, s string) string { return a.AnonymizeName(s) })},
+	"password":    {apply: stringStrategy(func(a *Anonymizer, s string) string { return a.AnonymizePassword() })},
+	"ssn":         {apply: stringStrategy(func(a *Anonymizer, s string) string { return a.AnonymizeSSN(s) })},
+	"credit_card": {apply: stringStrategy(func(a *Anonymizer, s string) string { return a.AnonymizeCreditCard(s) })},
+	"address":     {apply: stringStrategy(func(a *Anonymizer, s string) string { return a.AnonymizeAddress(s) })},
+
+	"fpe":      {apply: applyFPE, keyed: true},
+	"fpe_card": {apply: applyFPECard, keyed: true},
+	"fpe_iban": {apply: applyFPEIBAN, keyed: true},
+
+	"scrub": {apply: applyScrub, validate: validateScrub},
+
+	"noise":      {apply: applyNoise, validate: validateNoise},
+	"bucket":     {apply: applyBucket, validate: validateBucket},
+	"scale":      {apply: applyScale, validate: validateScale},
+	"date_shift": {apply: applyDateShift, keyed: true, validate: validateDateShift},
+
+	"tokenize": {apply: applyTokenize, vaulted: true},
+}
+
+func init() {
+	// Strategies that dispatch to other strategies are registered here to
+	// avoid an initialization cycle with the strategies map
+	strategies["json"] = strategy{apply: applyJSON}
+}
+
+// Strategies returns the names of all registered strategies
+func Strategies() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stringStrategy adapts a string transform, leaving non-string values untouched
+func stringStrategy(fn func(a *Anonymizer, s string) string) StrategyFunc {
+	return func(a *Anonymizer, f *Field) (interface{}, error) {
+		s, ok := f.Value.(string)
+		if !ok {
+			return f.Value, nil
+		}
+		return fn(a, s), nil
+	}
+}
+
+// keyedStringStrategy adapts a keyed string transform that may fail
+func keyedStringStrategy(fn func(a *Anonymizer, f *Field, s string) (string, error)) StrategyFunc {
+	return func(a *Anonymizer, f *Field) (interface{}, error) {
+		switch v := f.Value.(type) {
+		case string:
+			return fn(a, f, v)
+		case []byte:
+			out, err := fn(a, f, string(v))
+			return []byte(out), err
+		default:
+			return f.Value, nil
+		}
+	}
+}
+
+var applyFPE = keyedStringStrategy(func(a *Anonymizer, f *Field, s string) (string, error) {
+	prefix, err := optionInt(f.Rule, "preserve_prefix", 0)
+	if err != nil {
+		return "", err
+	}
+	suffix, err := optionInt(f.Rule, "preserve_suffix", 0)
+	if err != nil {
+		return "", err
+	}
+	return a.FPEString(s, tweak(f), prefix, suffix)
+})
+
+var applyFPECard = keyedStringStrategy(func(a *Anonymizer, f *Field, s string) (string, error) {
+	prefix, err := optionInt(f.Rule, "preserve_prefix", 0)
+	if err != nil {
+		return "", err
+	}
+	suffix, err := optionInt(f.Rule, "preserve_suffix", 0)
+	if err != nil {
+		return "", err
+	}
+	return a.FPECreditCard(s, tweak(f), prefix, suffix)
+})
+
+var applyFPEIBAN = keyedStringStrategy(func(a *Anonymizer, f *Field, s string) (string, error) {
+	return a.FPEIBAN(s, tweak(f))
+})
+
+// tweak returns the FPE tweak for a field. It defaults to empty so that the
+// same value maps to the same output in every table (keeping joins intact).
+func tweak(f *Field) string {
+	if f.Rule == nil {
+		return ""
+	}
+	return f.Rule.Options["tweak"]
+}
+
+// optionInt reads an integer option from a rule
+func optionInt(rule *config.AnonymizeRule, name string, def int) (int, error) {
+	if rule == nil {
+		return def, nil
+	}
+	raw, ok := rule.Options[name]
+	if !ok || raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("option %s of %s.%s must be an integer: %w", name, rule.Table, rule.Column, err)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("option %s of %s.%s must not be negative", name, rule.Table, rule.Column)
+	}
+	return v, nil
+}
@@ -0,0 +1,84 @@
+package anonymizer
+
+import (
+	"fmt"
+
+	"github.com/thien/database-migration-tool/internal/config"
+	"github.com/thien/database-migration-tool/internal/vault"
+)
+
+// applyTokenize replaces a value with a random token and records the mapping
+// in the encrypted vault so it can be reversed with detokenize
+var applyTokenize = keyedStringStrategy(func(a *Anonymizer, f *Field, s string) (string, error) {
+	v, err := a.tokenVault()
+	if err != nil {
+		return "", err
+	}
+	return v.Tokenize(f.Table, f.Column, s, tokenPrefix(f.Rule))
+})
+
+func tokenPrefix(rule *config.AnonymizeRule) string {
+	if p, ok := rule.Options["prefix"]; ok {
+		return p
+	}
+	return "tok_"
+}
+
+// TokenLength returns the length of the tokens written to a column, 0 when
+// the column isn't tokenized. For a JSON document it is the longest token
+// written to one of its paths.
+func (a *Anonymizer) TokenLength(table, column string) int {
+	rule, ok := a.rules[ruleKey(table, column)]
+	if !ok {
+		return 0
+	}
+	return tokenLength(rule)
+}
+
+func tokenLength(rule *config.AnonymizeRule) int {
+	switch rule.Strategy {
+	case "tokenize":
+		return len(tokenPrefix(rule)) + vault.TokenLength
+	case "json":
+		longest := 0
+		for _, p := range rule.Paths {
+			if n := tokenLength(pathRule(rule, p)); n > longest {
+				longest = n
+			}
+		}
+		return longest
+	}
+	return 0
+}
+
+// tokenVault opens the vault on first use
+func (a *Anonymizer) tokenVault() (*vault.Vault, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.vault != nil {
+		return a.vault, nil
+	}
+	v, err := vault.Open(a.vaultCfg.Path, a.vaultCfg.VaultPassphrase())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token vault: %w", err)
+	}
+	a.vault = v
+	return v, nil
+}
+
+// SaveVault writes new tokens to the vault file. It is a no-op when no value
+// was tokenized.
+func (a *Anonymizer) SaveVault() (int, error) {
+	a.mu.Lock()
+	v := a.vault
+	a.mu.Unlock()
+
+	if v == nil {
+		return 0, nil
+	}
+	if err := v.Save(); err != nil {
+		return 0, err
+	}
+	return v.Len(), nil
+}
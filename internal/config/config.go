@@ -4,17 +4,20 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config holds all configuration for the migration tool
 type Config struct {
-	Remote   DatabaseConfig `mapstructure:"remote"`
-	Local    DatabaseConfig `mapstructure:"local"`
-	Docker   DockerConfig   `mapstructure:"docker"`
+	Remote    DatabaseConfig  `mapstructure:"remote"`
+	Local     DatabaseConfig  `mapstructure:"local"`
+	Docker    DockerConfig    `mapstructure:"docker"`
 	Migration MigrationConfig `mapstructure:"migration"`
-	Logging  LoggingConfig  `mapstructure:"logging"`
+	Logging   LoggingConfig   `mapstructure:"logging"`
+	Generate  GenerateConfig  `mapstructure:"generate"`
 }
 
 // DatabaseConfig represents database connection settings
@@ -41,6 +44,226 @@ type MigrationConfig struct {
 	Tables         []string `mapstructure:"tables"`
 	ExcludeTables  []string `mapstructure:"exclude_tables"`
 	BatchSize      int      `mapstructure:"batch_size"`
+
+	Anonymization AnonymizationConfig `mapstructure:"anonymization"`
+	Transforms    []TransformRule     `mapstructure:"transforms"`
+	Verify        VerifyConfig        `mapstructure:"verify"`
+	Schema        SchemaConfig        `mapstructure:"schema"`
+}
+
+// SchemaConfig represents how schema changes are planned
+type SchemaConfig struct {
+	Engine  string             `mapstructure:"engine"`  // builtin, or atlas via Docker
+	Renames []SchemaRename     `mapstructure:"renames"` // renames the diff cannot tell from a drop and a create
+	Policy  SchemaPolicyConfig `mapstructure:"policy"`
+}
+
+// SchemaPolicyConfig represents what happens to risky schema changes,
+// separately for changes applied to local and pushed to remote
+type SchemaPolicyConfig struct {
+	Local  SchemaPolicy `mapstructure:"local"`
+	Remote SchemaPolicy `mapstructure:"remote"`
+}
+
+// SchemaPolicy is the action taken for each risk class of schema change:
+// allow, warn, confirm (interactively) or block. Safe changes are allowed.
+type SchemaPolicy struct {
+	Destructive  string `mapstructure:"destructive"`    // drops data or objects
+	DataLossRisk string `mapstructure:"data_loss_risk"` // may alter or reject existing values
+	LockHeavy    string `mapstructure:"lock_heavy"`     // scans or rewrites a table under lock
+}
+
+// SchemaRename hints the rename of a table, e.g. customers to clients, or of
+// a column, e.g. users.name to users.full_name, each named as on its side
+type SchemaRename struct {
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+}
+
+// VerifyConfig represents verification settings
+type VerifyConfig struct {
+	// SchemaIgnore lists glob patterns of schema differences to ignore,
+	// matched against "category:object" or the object, e.g. "index:*_tmp"
+	SchemaIgnore []string `mapstructure:"schema_ignore"`
+
+	SchemaDrift       string           `mapstructure:"schema_drift"`         // fail on schema differences of level: error, warning, or never
+	MaxRowDiff        int64            `mapstructure:"max_row_diff"`         // differing rows tolerated per table
+	MaxRowDiffPercent float64          `mapstructure:"max_row_diff_percent"` // differing rows tolerated per table, in percent of the remote rows
+	Exact             []string         `mapstructure:"exact"`                // tables (globs) that must match exactly
+	Tolerances        []TableTolerance `mapstructure:"tolerances"`           // per-table overrides, the first match wins
+
+	Profile ProfileConfig `mapstructure:"profile"`
+
+	Workers           int            `mapstructure:"workers"` // tables verified concurrently
+	Sampling          SamplingConfig `mapstructure:"sampling"`
+	EstimateTolerance float64        `mapstructure:"estimate_tolerance"` // percent row estimates may differ by
+
+	History  HistoryConfig  `mapstructure:"history"`
+	Baseline BaselineConfig `mapstructure:"baseline"`
+}
+
+// SamplingConfig represents the sampled verification of large tables
+type SamplingConfig struct {
+	Threshold int64 `mapstructure:"threshold"` // estimated rows above which a table is sampled, 0 never
+	Chunks    int   `mapstructure:"chunks"`    // chunks compared per sampled table
+}
+
+// ProfileConfig represents the tolerances of profile-based verification, in
+// percent, and where profiles are saved for trending
+type ProfileConfig struct {
+	NullTolerance     float64 `mapstructure:"null_tolerance"`     // of the remote rows
+	DistinctTolerance float64 `mapstructure:"distinct_tolerance"` // of the remote estimate
+	MeanTolerance     float64 `mapstructure:"mean_tolerance"`     // of the remote mean
+	Dir               string  `mapstructure:"dir"`
+}
+
+// HistoryConfig represents where verification runs are recorded
+type HistoryConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Dir     string `mapstructure:"dir"`  // one JSON file per run
+	Keep    int    `mapstructure:"keep"` // runs kept, 0 keeps all
+}
+
+// BaselineConfig represents the alerts raised when local row counts drift
+// from the last good run, in percent of its rows; 0 disables an alert
+type BaselineConfig struct {
+	MaxDropPercent   float64      `mapstructure:"max_drop_percent"`
+	MaxGrowthPercent float64      `mapstructure:"max_growth_percent"`
+	Tables           []TableDrift `mapstructure:"tables"` // per-table overrides, the first match wins
+}
+
+// TableDrift overrides the drift alerts of matching tables
+type TableDrift struct {
+	Table            string  `mapstructure:"table"` // glob, e.g. audit_*
+	MaxDropPercent   float64 `mapstructure:"max_drop_percent"`
+	MaxGrowthPercent float64 `mapstructure:"max_growth_percent"`
+}
+
+// TableTolerance overrides the row difference tolerance of matching tables
+type TableTolerance struct {
+	Table             string  `mapstructure:"table"` // glob, e.g. audit_*
+	MaxRowDiff        int64   `mapstructure:"max_row_diff"`
+	MaxRowDiffPercent float64 `mapstructure:"max_row_diff_percent"`
+}
+
+// TransformRule computes a column from an expression over the other columns
+// of the row, e.g. first_name + " " + last_name
+type TransformRule struct {
+	Table  string `mapstructure:"table"`
+	Column string `mapstructure:"column"`
+	Expr   string `mapstructure:"expr"`
+}
+
+// AnonymizationConfig represents column-level anonymization settings
+type AnonymizationConfig struct {
+	Key        string          `mapstructure:"key"`  // secret for keyed (deterministic) strategies
+	Mode       string          `mapstructure:"mode"` // client (anonymize in Go) or server (copy to a staging table, UPDATE it there)
+	Rules      []AnonymizeRule `mapstructure:"rules"`
+	Manifest   string          `mapstructure:"manifest"`    // compliance manifest written after an anonymized pull
+	SigningKey string          `mapstructure:"signing_key"` // signs the manifest, defaults to key
+	Risk       RiskConfig      `mapstructure:"risk"`
+	Plugins    []PluginConfig  `mapstructure:"plugins"`
+	Vault      VaultConfig     `mapstructure:"vault"`
+
+	Comments      bool               `mapstructure:"comments"`       // read pii:<strategy> tags from column comments and security labels
+	LabelProvider string             `mapstructure:"label_provider"` // only read security labels of this provider
+	Conventions   []NamingConvention `mapstructure:"conventions"`
+}
+
+// NamingConvention assigns a strategy to every column whose "table.column"
+// matches a regular expression
+type NamingConvention struct {
+	Pattern  string            `mapstructure:"pattern"`
+	Strategy string            `mapstructure:"strategy"`
+	Options  map[string]string `mapstructure:"options"`
+}
+
+// VaultConfig represents the encrypted token vault used by the tokenize strategy
+type VaultConfig struct {
+	Path       string `mapstructure:"path"`
+	Passphrase string `mapstructure:"passphrase"` // falls back to DBMIGRATE_VAULT_PASSPHRASE
+	AuditLog   string `mapstructure:"audit_log"`  // every detokenize lookup is appended here
+}
+
+// VaultPassphrase returns the vault passphrase from the config or the environment
+func (v *VaultConfig) VaultPassphrase() string {
+	if v.Passphrase != "" {
+		return v.Passphrase
+	}
+	return os.Getenv("DBMIGRATE_VAULT_PASSPHRASE")
+}
+
+// PluginConfig describes an external transformer executable. Rules use it
+// with the strategy "plugin:<name>".
+type PluginConfig struct {
+	Name    string        `mapstructure:"name"`
+	Command string        `mapstructure:"command"`
+	Args    []string      `mapstructure:"args"`
+	Timeout time.Duration `mapstructure:"timeout"`  // per batch
+	OnError string        `mapstructure:"on_error"` // fail or null
+}
+
+// RiskConfig represents re-identification risk (k-anonymity) settings
+type RiskConfig struct {
+	K              int               `mapstructure:"k"`
+	L              int               `mapstructure:"l"`               // optional l-diversity target, 0 disables it
+	Enforce        bool              `mapstructure:"enforce"`         // generalize/suppress after pull to reach k
+	MaxSuppression float64           `mapstructure:"max_suppression"` // fraction of rows that may be suppressed
+	Suppression    string            `mapstructure:"suppression"`     // null or delete
+	Tables         []RiskTableConfig `mapstructure:"tables"`
+}
+
+// RiskTableConfig lists the quasi-identifiers of a table
+type RiskTableConfig struct {
+	Table            string   `mapstructure:"table"`
+	QuasiIdentifiers []string `mapstructure:"quasi_identifiers"`
+	Sensitive        string   `mapstructure:"sensitive"` // column checked for l-diversity
+}
+
+// ManifestKey returns the key used to sign the compliance manifest
+func (a *AnonymizationConfig) ManifestKey() []byte {
+	if a.SigningKey != "" {
+		return []byte(a.SigningKey)
+	}
+	return []byte(a.Key)
+}
+
+// AnonymizeRule assigns a named strategy to a single table column
+type AnonymizeRule struct {
+	Table    string            `mapstructure:"table"`
+	Column   string            `mapstructure:"column"`
+	Strategy string            `mapstructure:"strategy"`
+	Options  map[string]string `mapstructure:"options"`
+	Paths    []PathRule        `mapstructure:"paths"`    // for the json strategy
+	Patterns map[string]string `mapstructure:"patterns"` // custom regexes for the scrub strategy
+}
+
+// PathRule assigns a strategy to the nodes matched by a JSON path
+type PathRule struct {
+	Path     string            `mapstructure:"path"` // e.g. $.customer.email or $.addresses[*].street
+	Strategy string            `mapstructure:"strategy"`
+	Options  map[string]string `mapstructure:"options"`
+}
+
+// GenerateConfig represents synthetic data generation settings
+type GenerateConfig struct {
+	Seed        int64               `mapstructure:"seed"`
+	Source      string              `mapstructure:"source"`       // db (introspect the target) or ent (ent/schema)
+	DefaultRows int                 `mapstructure:"default_rows"` // rows per table without an explicit count
+	Rows        map[string]int      `mapstructure:"rows"`         // rows per table
+	Cardinality []CardinalityConfig `mapstructure:"cardinality"`
+	Truncate    bool                `mapstructure:"truncate"`
+	Tables      []string            `mapstructure:"tables"` // limit generation to these tables
+}
+
+// CardinalityConfig generates between Min and Max child rows per parent row,
+// e.g. 5-20 orders per user
+type CardinalityConfig struct {
+	Table  string `mapstructure:"table"`
+	Parent string `mapstructure:"parent"`
+	Column string `mapstructure:"column"` // foreign key column, needed when the table references the parent twice
+	Min    int    `mapstructure:"min"`
+	Max    int    `mapstructure:"max"`
 }
 
 // LoggingConfig represents logging settings
@@ -87,7 +310,7 @@ func Load(configPath string) (*Config, error) {
 		v.SetConfigType("yaml")
 		v.AddConfigPath(".")
 		v.AddConfigPath(filepath.Join(os.Getenv("HOME"), ".database-migration-tool"))
-		
+
 		// Ignore error if config file doesn't exist
 		_ = v.ReadInConfig()
 	}
@@ -133,6 +356,39 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("migration.anonymize", false)
 	v.SetDefault("migration.truncate_tables", true)
 	v.SetDefault("migration.batch_size", 1000)
+	v.SetDefault("migration.verify.schema_drift", "error")
+	v.SetDefault("migration.verify.profile.distinct_tolerance", 10.0)
+	v.SetDefault("migration.verify.profile.mean_tolerance", 0.1)
+	v.SetDefault("migration.verify.profile.dir", "profiles")
+	v.SetDefault("migration.verify.workers", 4)
+	v.SetDefault("migration.verify.sampling.threshold", 10000000)
+	v.SetDefault("migration.verify.sampling.chunks", 300)
+	v.SetDefault("migration.verify.estimate_tolerance", 10.0)
+	v.SetDefault("migration.verify.history.enabled", false)
+	v.SetDefault("migration.verify.history.dir", "verify-history")
+	v.SetDefault("migration.verify.history.keep", 90)
+	v.SetDefault("migration.verify.baseline.max_drop_percent", 20.0)
+	v.SetDefault("migration.schema.engine", "builtin")
+	v.SetDefault("migration.schema.policy.local.destructive", "confirm")
+	v.SetDefault("migration.schema.policy.local.data_loss_risk", "warn")
+	v.SetDefault("migration.schema.policy.local.lock_heavy", "allow")
+	v.SetDefault("migration.schema.policy.remote.destructive", "block")
+	v.SetDefault("migration.schema.policy.remote.data_loss_risk", "confirm")
+	v.SetDefault("migration.schema.policy.remote.lock_heavy", "warn")
+	v.SetDefault("migration.anonymization.manifest", "anonymization-manifest.json")
+	v.SetDefault("migration.anonymization.mode", "client")
+	v.SetDefault("migration.anonymization.comments", true)
+	v.SetDefault("migration.anonymization.vault.path", "token-vault.enc")
+	v.SetDefault("migration.anonymization.vault.audit_log", "token-vault-audit.log")
+	v.SetDefault("migration.anonymization.risk.k", 5)
+	v.SetDefault("migration.anonymization.risk.max_suppression", 0.05)
+	v.SetDefault("migration.anonymization.risk.suppression", "null")
+
+	// Generate defaults
+	v.SetDefault("generate.seed", 1)
+	v.SetDefault("generate.source", "db")
+	v.SetDefault("generate.default_rows", 100)
+	v.SetDefault("generate.truncate", false)
 
 	// Logging defaults
 	v.SetDefault("logging.level", "info")
@@ -166,5 +422,130 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("migration.batch_size must be greater than 0")
 	}
 
+	// Validate risk settings
+	risk := c.Migration.Anonymization.Risk
+	if risk.K < 1 {
+		return fmt.Errorf("migration.anonymization.risk.k must be at least 1")
+	}
+	if risk.Suppression != "null" && risk.Suppression != "delete" {
+		return fmt.Errorf("migration.anonymization.risk.suppression must be null or delete")
+	}
+
+	// Validate transforms
+	for i, t := range c.Migration.Transforms {
+		if t.Table == "" || t.Column == "" || t.Expr == "" {
+			return fmt.Errorf("migration.transforms[%d]: table, column and expr are required", i)
+		}
+	}
+
+	// Validate transformer plugins
+	for i, p := range c.Migration.Anonymization.Plugins {
+		if p.Name == "" || p.Command == "" {
+			return fmt.Errorf("migration.anonymization.plugins[%d]: name and command are required", i)
+		}
+		if p.OnError != "" && p.OnError != "fail" && p.OnError != "null" {
+			return fmt.Errorf("migration.anonymization.plugins[%d]: on_error must be fail or null", i)
+		}
+	}
+
+	// Validate synthetic data generation
+	if src := c.Generate.Source; src != "db" && src != "ent" {
+		return fmt.Errorf("generate.source must be db or ent")
+	}
+	for i, card := range c.Generate.Cardinality {
+		if card.Table == "" || card.Parent == "" {
+			return fmt.Errorf("generate.cardinality[%d]: table and parent are required", i)
+		}
+		if card.Min < 0 || card.Max < card.Min {
+			return fmt.Errorf("generate.cardinality[%d]: min must be >= 0 and max >= min", i)
+		}
+	}
+
+	// Validate verification policy
+	verify := &c.Migration.Verify
+	if d := verify.SchemaDrift; d != "error" && d != "warning" && d != "never" {
+		return fmt.Errorf("migration.verify.schema_drift must be error, warning or never")
+	}
+	if verify.MaxRowDiff < 0 || verify.MaxRowDiffPercent < 0 || verify.MaxRowDiffPercent > 100 {
+		return fmt.Errorf("migration.verify: max_row_diff must be >= 0 and max_row_diff_percent between 0 and 100")
+	}
+	for i, t := range verify.Tolerances {
+		if t.Table == "" {
+			return fmt.Errorf("migration.verify.tolerances[%d]: table is required", i)
+		}
+		if t.MaxRowDiff < 0 || t.MaxRowDiffPercent < 0 || t.MaxRowDiffPercent > 100 {
+			return fmt.Errorf("migration.verify.tolerances[%d]: max_row_diff must be >= 0 and max_row_diff_percent between 0 and 100", i)
+		}
+	}
+	if p := verify.Profile; p.NullTolerance < 0 || p.DistinctTolerance < 0 || p.MeanTolerance < 0 {
+		return fmt.Errorf("migration.verify.profile: tolerances must be >= 0")
+	}
+	if verify.Workers < 1 {
+		return fmt.Errorf("migration.verify.workers must be at least 1")
+	}
+	if verify.Sampling.Threshold < 0 || verify.Sampling.Chunks < 1 {
+		return fmt.Errorf("migration.verify.sampling: threshold must be >= 0 and chunks at least 1")
+	}
+	if verify.EstimateTolerance < 0 {
+		return fmt.Errorf("migration.verify.estimate_tolerance must be >= 0")
+	}
+	if verify.History.Keep < 0 {
+		return fmt.Errorf("migration.verify.history.keep must be >= 0")
+	}
+	if b := verify.Baseline; b.MaxDropPercent < 0 || b.MaxDropPercent > 100 || b.MaxGrowthPercent < 0 {
+		return fmt.Errorf("migration.verify.baseline: max_drop_percent must be between 0 and 100 and max_growth_percent >= 0")
+	}
+	for i, t := range verify.Baseline.Tables {
+		if t.Table == "" {
+			return fmt.Errorf("migration.verify.baseline.tables[%d]: table is required", i)
+		}
+		if t.MaxDropPercent < 0 || t.MaxDropPercent > 100 || t.MaxGrowthPercent < 0 {
+			return fmt.Errorf("migration.verify.baseline.tables[%d]: max_drop_percent must be between 0 and 100 and max_growth_percent >= 0", i)
+		}
+	}
+
+	// Validate schema planning
+	schema := &c.Migration.Schema
+	if e := schema.Engine; e != "builtin" && e != "atlas" {
+		return fmt.Errorf("migration.schema.engine must be builtin or atlas")
+	}
+	for i, r := range schema.Renames {
+		if r.From == "" || r.To == "" {
+			return fmt.Errorf("migration.schema.renames[%d]: from and to are required", i)
+		}
+		if strings.Contains(r.From, ".") != strings.Contains(r.To, ".") {
+			return fmt.Errorf("migration.schema.renames[%d]: from and to must both name a table or both a table.column", i)
+		}
+	}
+	for target, p := range map[string]SchemaPolicy{"local": schema.Policy.Local, "remote": schema.Policy.Remote} {
+		for _, action := range []string{p.Destructive, p.DataLossRisk, p.LockHeavy} {
+			if action != "allow" && action != "warn" && action != "confirm" && action != "block" {
+				return fmt.Errorf("migration.schema.policy.%s: actions must be allow, warn, confirm or block", target)
+			}
+		}
+	}
+
+	// Validate anonymization mode
+	if mode := c.Migration.Anonymization.Mode; mode != "client" && mode != "server" {
+		return fmt.Errorf("migration.anonymization.mode must be client or server")
+	}
+
+	// Validate naming conventions
+	for i, nc := range c.Migration.Anonymization.Conventions {
+		if nc.Pattern == "" || nc.Strategy == "" {
+			return fmt.Errorf("migration.anonymization.conventions[%d]: pattern and strategy are required", i)
+		}
+	}
+
+	// Validate anonymization rules
+	for i, rule := range c.Migration.Anonymization.Rules {
+		if rule.Table == "" || rule.Column == "" {
+			return fmt.Errorf("migration.anonymization.rules[%d]: table and column are required", i)
+		}
+		if rule.Strategy == "" {
+			return fmt.Errorf("migration.anonymization.rules[%d]: strategy is required", i)
+		}
+	}
+
 	return nil
 }
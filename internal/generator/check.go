@@ -0,0 +1,124 @@
+package generator
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// casts added by pg_get_constraintdef, e.g. (0)::numeric or 'a'::text
+	checkCast = regexp.MustCompile(`::(?:character varying|double precision|timestamp with(?:out)? time zone|"?[\w]+"?)(?:\[\])?`)
+
+	checkBound    = regexp.MustCompile(`^"?(\w+)"? (>=|>|<=|<) '?(-?[\d.]+)'?$`)
+	checkAny      = regexp.MustCompile(`^"?(\w+)"? = ANY ARRAY\[(.*)\]$`)
+	checkLength   = regexp.MustCompile(`^(?:length|char_length|btrim) "?(\w+)"? (>=|>) (\d+)$`)
+	checkNotEmpty = regexp.MustCompile(`^"?(\w+)"? <> ''$`)
+	checkNotNull  = regexp.MustCompile(`^"?(\w+)"? IS NOT NULL$`)
+	checkLiteral  = regexp.MustCompile(`'((?:[^']|'')*)'`)
+)
+
+// applyCheck narrows the columns of a table to satisfy a CHECK constraint as
+// printed by pg_get_constraintdef. Only conjunctions of simple comparisons,
+// ANY (ARRAY[...]) lists and non-empty tests are understood; it returns false
+// for anything else so the caller can report the constraint.
+func applyCheck(t *Table, def string) bool {
+	expr := strings.TrimPrefix(def, "CHECK ")
+	expr = strings.TrimSuffix(expr, " NOT VALID")
+	expr = checkCast.ReplaceAllString(expr, "")
+	expr = strings.Join(strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(expr)), " ")
+	if strings.Contains(expr, " OR ") {
+		return false
+	}
+
+	clauses := strings.Split(expr, " AND ")
+	for _, clause := range clauses {
+		if !applyClause(t, strings.TrimSpace(clause)) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyClause applies one comparison of a CHECK constraint
+func applyClause(t *Table, clause string) bool {
+	if m := checkBound.FindStringSubmatch(clause); m != nil {
+		c := t.column(m[1])
+		n, err := strconv.ParseFloat(m[3], 64)
+		if c == nil || err != nil {
+			return false
+		}
+		switch m[2] {
+		case ">":
+			n += c.step()
+			fallthrough
+		case ">=":
+			if c.Min == nil || n > *c.Min {
+				c.Min = &n
+			}
+		case "<":
+			n -= c.step()
+			fallthrough
+		case "<=":
+			if c.Max == nil || n < *c.Max {
+				c.Max = &n
+			}
+		}
+		return true
+	}
+
+	if m := checkAny.FindStringSubmatch(clause); m != nil {
+		c := t.column(m[1])
+		if c == nil {
+			return false
+		}
+		var values []string
+		for _, lit := range checkLiteral.FindAllStringSubmatch(m[2], -1) {
+			values = append(values, strings.ReplaceAll(lit[1], "''", "'"))
+		}
+		c.Enum = values
+		return len(values) > 0
+	}
+
+	if m := checkLength.FindStringSubmatch(clause); m != nil {
+		c := t.column(m[1])
+		if c == nil {
+			return false
+		}
+		// Generated strings are never empty, longer minimums aren't handled
+		n, _ := strconv.Atoi(m[3])
+		c.NonEmpty = true
+		return n == 0 || (n == 1 && m[2] == ">=")
+	}
+
+	for _, re := range []*regexp.Regexp{checkNotEmpty, checkNotNull} {
+		if m := re.FindStringSubmatch(clause); m != nil {
+			c := t.column(m[1])
+			if c == nil {
+				return false
+			}
+			if re == checkNotNull {
+				c.Nullable = false
+			} else {
+				c.NonEmpty = true
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+// step is the smallest increment of a numeric column, used to turn a strict
+// bound into an inclusive one
+func (c *Column) step() float64 {
+	if isInteger(c.Type) {
+		return 1
+	}
+	scale := c.Scale
+	if scale == 0 {
+		scale = 2
+	}
+	return math.Pow10(-scale)
+}
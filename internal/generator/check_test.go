@@ -0,0 +1,144 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thien/database-migration-tool/internal/anonymizer"
+)
+
+func TestApplyCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		def    string
+		column Column
+		want   Column
+		ok     bool
+	}{
+		{
+			name:   "inclusive range",
+			def:    "CHECK (((age >= 0) AND (age <= 120)))",
+			column: Column{Name: "age", Type: "integer"},
+			want:   Column{Name: "age", Type: "integer", Min: float(0), Max: float(120)},
+			ok:     true,
+		},
+		{
+			name:   "strict integer bounds",
+			def:    "CHECK (((age > 17) AND (age < 66)))",
+			column: Column{Name: "age", Type: "smallint"},
+			want:   Column{Name: "age", Type: "smallint", Min: float(18), Max: float(65)},
+			ok:     true,
+		},
+		{
+			name:   "strict numeric bound uses the scale",
+			def:    "CHECK ((price > (0)::numeric))",
+			column: Column{Name: "price", Type: "numeric", Scale: 3},
+			want:   Column{Name: "price", Type: "numeric", Scale: 3, Min: float(0.001)},
+			ok:     true,
+		},
+		{
+			name:   "quoted literal and identifier",
+			def:    `CHECK (("Rating" <= '5'::double precision))`,
+			column: Column{Name: "Rating", Type: "double precision"},
+			want:   Column{Name: "Rating", Type: "double precision", Max: float(5)},
+			ok:     true,
+		},
+		{
+			name:   "in list",
+			def:    "CHECK (((status)::text = ANY ((ARRAY['new'::character varying, 'it''s done'::character varying])::text[])))",
+			column: Column{Name: "status", Type: "character varying"},
+			want:   Column{Name: "status", Type: "character varying", Enum: []string{"new", "it's done"}},
+			ok:     true,
+		},
+		{
+			name:   "non-empty",
+			def:    "CHECK ((length((name)::text) > 0))",
+			column: Column{Name: "name", Type: "text"},
+			want:   Column{Name: "name", Type: "text", NonEmpty: true},
+			ok:     true,
+		},
+		{
+			name:   "not equal to empty and not null",
+			def:    "CHECK (((code <> ''::text) AND (code IS NOT NULL))) NOT VALID",
+			column: Column{Name: "code", Type: "text", Nullable: true},
+			want:   Column{Name: "code", Type: "text", NonEmpty: true},
+			ok:     true,
+		},
+		{
+			name:   "longer minimum lengths are reported",
+			def:    "CHECK ((char_length(code) >= 3))",
+			column: Column{Name: "code", Type: "text"},
+			want:   Column{Name: "code", Type: "text", NonEmpty: true},
+		},
+		{
+			name:   "disjunctions are reported",
+			def:    "CHECK (((age < 18) OR (age > 65)))",
+			column: Column{Name: "age", Type: "integer"},
+			want:   Column{Name: "age", Type: "integer"},
+		},
+		{
+			name:   "unknown columns are reported",
+			def:    "CHECK ((height > 0))",
+			column: Column{Name: "age", Type: "integer"},
+			want:   Column{Name: "age", Type: "integer"},
+		},
+		{
+			name:   "expressions are reported",
+			def:    "CHECK ((ends_at > starts_at))",
+			column: Column{Name: "ends_at", Type: "timestamp without time zone"},
+			want:   Column{Name: "ends_at", Type: "timestamp without time zone"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.column
+			if ok := applyCheck(&Table{Name: "t", Columns: []*Column{&c}}, tt.def); ok != tt.ok {
+				t.Errorf("applyCheck(%s) = %v, want %v", tt.def, ok, tt.ok)
+			}
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("column = %+v, want %+v", c, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyCheckKeepsTighterBounds(t *testing.T) {
+	c := Column{Name: "qty", Type: "integer"}
+	table := &Table{Name: "t", Columns: []*Column{&c}}
+	for _, def := range []string{"CHECK ((qty >= 5))", "CHECK ((qty >= 1))", "CHECK ((qty <= 10))", "CHECK ((qty < 50))"} {
+		if !applyCheck(table, def) {
+			t.Fatalf("applyCheck(%s) = false", def)
+		}
+	}
+	if *c.Min != 5 || *c.Max != 10 {
+		t.Errorf("bounds = [%v, %v], want [5, 10]", *c.Min, *c.Max)
+	}
+}
+
+func TestUniqueRetryStaysInBounds(t *testing.T) {
+	g := NewGenerator(nil, nil)
+	faker := anonymizer.NewFaker(1)
+	c := &Column{Name: "rank", Type: "integer", Min: float(10), Max: float(14)}
+
+	seen := make(map[int64]bool)
+	for index := 0; index < 5; index++ {
+		v := g.value(c, faker, nil, index, 1).(int64)
+		if v < 10 || v > 14 {
+			t.Fatalf("value(index %d) = %d, outside [10, 14]", index, v)
+		}
+		seen[v] = true
+	}
+	if len(seen) != 5 {
+		t.Errorf("retries produced %d distinct values, want 5", len(seen))
+	}
+
+	// Later attempts move on to another value within the bounds
+	if a, b := g.value(c, faker, nil, 4, 1), g.value(c, faker, nil, 4, 2); a == b || b.(int64) < 10 {
+		t.Errorf("attempts 1 and 2 = %v, %v, want different values in bounds", a, b)
+	}
+}
+
+func float(v float64) *float64 {
+	return &v
+}
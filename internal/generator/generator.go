@@ -0,0 +1,517 @@
+package generator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thien/database-migration-tool/internal/anonymizer"
+	"github.com/thien/database-migration-tool/internal/config"
+	"github.com/thien/database-migration-tool/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Generated timestamps fall in a fixed window so a seed always yields the
+// same rows, whatever the day it runs
+var (
+	timeFrom = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeTo   = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// maxAttempts bounds the retries for a row that violates a unique constraint
+const maxAttempts = 100
+
+// Result is the outcome of generating one table
+type Result struct {
+	Table    string
+	Rows     int64
+	Duration time.Duration
+	Error    error
+}
+
+// Generator fills tables with synthetic rows
+type Generator struct {
+	db     *sql.DB
+	config *config.GenerateConfig
+	keys   map[string][]interface{} // referenced keys by "table.column"
+}
+
+// NewGenerator creates a generator writing to db
+func NewGenerator(db *sql.DB, cfg *config.GenerateConfig) *Generator {
+	return &Generator{
+		db:     db,
+		config: cfg,
+		keys:   make(map[string][]interface{}),
+	}
+}
+
+// Generate fills the given tables, parents before children. A failed table
+// stops the run since its children would have nothing to reference.
+func (g *Generator) Generate(ctx context.Context, tables []*Table) ([]Result, error) {
+	selected := tables
+	if len(g.config.Tables) > 0 {
+		keep := make(map[string]bool, len(g.config.Tables))
+		for _, name := range g.config.Tables {
+			keep[name] = true
+		}
+		selected = nil
+		for _, t := range tables {
+			if keep[t.Name] {
+				selected = append(selected, t)
+			}
+		}
+	}
+
+	sorted, err := sortTables(selected)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.config.Truncate {
+		if err := g.truncate(ctx, tables, sorted); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []Result
+	for _, t := range sorted {
+		for _, check := range t.Checks {
+			logger.Warn("CHECK constraint not understood, inserts may fail",
+				zap.String("table", t.Name), zap.String("constraint", check))
+		}
+
+		start := time.Now()
+		rows, err := g.generateTable(ctx, t)
+		results = append(results, Result{
+			Table:    t.Name,
+			Rows:     rows,
+			Duration: time.Since(start),
+			Error:    err,
+		})
+		if err != nil {
+			return results, fmt.Errorf("failed to generate %s: %w", t.Name, err)
+		}
+		logger.Info("Table generated", zap.String("table", t.Name), zap.Int64("rows", rows))
+	}
+	return results, nil
+}
+
+// truncate empties the selected tables in one statement. Tables outside the
+// selection that reference them would lose their rows too, so they are
+// reported instead of truncated with CASCADE.
+func (g *Generator) truncate(ctx context.Context, all, selected []*Table) error {
+	inSet := make(map[string]bool, len(selected))
+	names := make([]string, len(selected))
+	for i, t := range selected {
+		inSet[t.Name] = true
+		names[i] = quoteIdent(t.Name)
+	}
+	for _, t := range all {
+		if inSet[t.Name] {
+			continue
+		}
+		for _, c := range t.Columns {
+			if c.FK != nil && inSet[c.FK.Table] {
+				return fmt.Errorf("cannot truncate %s: %s references it and is not generated", c.FK.Table, t.Name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	if _, err := g.db.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY", strings.Join(names, ", "))); err != nil {
+		return fmt.Errorf("failed to truncate tables: %w", err)
+	}
+	return nil
+}
+
+// generateTable inserts the rows of one table in a single transaction
+func (g *Generator) generateTable(ctx context.Context, t *Table) (int64, error) {
+	faker := anonymizer.NewFaker(g.tableSeed(t.Name))
+
+	var columns []*Column
+	for _, c := range t.Columns {
+		if !c.Serial {
+			columns = append(columns, c)
+		}
+	}
+
+	// Foreign keys take their values from the parent's rows
+	parents := make(map[string][]interface{})
+	for _, c := range columns {
+		if c.FK == nil || c.FK.Table == t.Name {
+			continue
+		}
+		keys, err := g.parentKeys(ctx, c.FK)
+		if err != nil {
+			return 0, err
+		}
+		if len(keys) == 0 && !c.Nullable {
+			return 0, fmt.Errorf("column %s references %s which has no rows", c.Name, c.FK.Table)
+		}
+		parents[c.Name] = keys
+	}
+
+	plan, err := g.plan(t, faker, parents)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	names := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = quoteIdent(c.Name)
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteIdent(t.Name), strings.Join(names, ", "), strings.Join(placeholders, ", "))
+	if len(columns) == 0 {
+		insert = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", quoteIdent(t.Name))
+	}
+
+	stmt, err := tx.PrepareContext(ctx, insert)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	seen := make([]map[string]bool, len(t.Uniques))
+	for i := range seen {
+		seen[i] = make(map[string]bool)
+	}
+
+	var count int64
+	for i, fixed := range plan {
+		values, err := g.uniqueRow(t, columns, faker, parents, fixed, i, seen)
+		if err != nil {
+			return count, err
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return count, fmt.Errorf("failed to insert row %d: %w", i+1, err)
+		}
+		count++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return count, fmt.Errorf("failed to commit table: %w", err)
+	}
+	return count, nil
+}
+
+// plan returns one entry per row to generate, holding the foreign key values
+// fixed by a cardinality rule. Without a rule the row count comes from the
+// rows setting and every foreign key is picked at random.
+func (g *Generator) plan(t *Table, faker *anonymizer.Faker, parents map[string][]interface{}) ([]map[string]interface{}, error) {
+	var card *config.CardinalityConfig
+	var column string
+	for i := range g.config.Cardinality {
+		c := &g.config.Cardinality[i]
+		if c.Table != t.Name {
+			continue
+		}
+		for _, col := range t.Columns {
+			if col.FK != nil && col.FK.Table == c.Parent && (c.Column == "" || c.Column == col.Name) {
+				if column != "" && c.Column == "" {
+					return nil, fmt.Errorf("%s references %s twice, set the cardinality column", t.Name, c.Parent)
+				}
+				card, column = c, col.Name
+			}
+		}
+		if card == nil {
+			return nil, fmt.Errorf("cardinality: %s has no foreign key to %s", c.Table, c.Parent)
+		}
+		break
+	}
+
+	if card == nil {
+		count := g.config.DefaultRows
+		if n, ok := g.config.Rows[t.Name]; ok {
+			count = n
+		}
+		return make([]map[string]interface{}, count), nil
+	}
+
+	var plan []map[string]interface{}
+	for _, key := range parents[column] {
+		n := faker.IntRange(int64(card.Min), int64(card.Max))
+		for j := int64(0); j < n; j++ {
+			plan = append(plan, map[string]interface{}{column: key})
+		}
+	}
+	return plan, nil
+}
+
+// uniqueRow generates a row, retrying until it satisfies the unique
+// constraints of the table
+func (g *Generator) uniqueRow(t *Table, columns []*Column, faker *anonymizer.Faker, parents map[string][]interface{}, fixed map[string]interface{}, index int, seen []map[string]bool) ([]interface{}, error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		values := make([]interface{}, len(columns))
+		byName := make(map[string]interface{}, len(columns))
+		for i, c := range columns {
+			if v, ok := fixed[c.Name]; ok {
+				values[i] = v
+			} else {
+				values[i] = g.value(c, faker, parents, index, attempt)
+			}
+			byName[c.Name] = values[i]
+		}
+
+		keys := make([]string, len(t.Uniques))
+		duplicate := false
+		for u, unique := range t.Uniques {
+			var parts []string
+			null := false
+			for _, col := range unique {
+				v, ok := byName[col]
+				if !ok {
+					null = true // serial column, unique by construction
+					break
+				}
+				if v == nil {
+					null = true // NULLs never conflict
+					break
+				}
+				parts = append(parts, fmt.Sprint(v))
+			}
+			if null {
+				continue
+			}
+			keys[u] = strings.Join(parts, "\x00")
+			if seen[u][keys[u]] {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		for u, key := range keys {
+			if key != "" {
+				seen[u][key] = true
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("could not generate a unique row after %d attempts", maxAttempts)
+}
+
+// value generates a value for a column. Retries of a row (attempt > 0) make
+// integers and text unique by deriving them from the row index.
+func (g *Generator) value(c *Column, faker *anonymizer.Faker, parents map[string][]interface{}, index, attempt int) interface{} {
+	if c.FK != nil {
+		keys := parents[c.Name]
+		if len(keys) == 0 || (c.Nullable && faker.Intn(10) == 0) {
+			return nil
+		}
+		return keys[faker.Intn(len(keys))]
+	}
+	if c.Nullable && faker.Intn(10) == 0 {
+		return nil
+	}
+	if len(c.Enum) > 0 {
+		return faker.Pick(c.Enum)
+	}
+
+	switch {
+	case isInteger(c.Type):
+		lo, hi := c.bounds(1, 1000)
+		first, last := int64(math.Ceil(lo)), int64(math.Floor(hi))
+		if attempt > 0 {
+			// Wrap within the bounds; later attempts move on to the next
+			// values, so a column with more rows than values fails to be
+			// unique rather than breaking its CHECK
+			if span := last - first + 1; span > 0 {
+				return first + (int64(index)+int64(attempt-1))%span
+			}
+			return first + int64(index)
+		}
+		return faker.IntRange(first, last)
+	case isDecimal(c.Type):
+		lo, hi := c.bounds(1, 1000)
+		scale := c.Scale
+		if scale == 0 {
+			scale = 2
+		}
+		v := lo + faker.Float64()*(hi-lo)
+		v = math.Round(v*math.Pow10(scale)) / math.Pow10(scale)
+		if c.Type == "numeric" {
+			return strconv.FormatFloat(v, 'f', scale, 64)
+		}
+		return v
+	case c.Type == "boolean":
+		return faker.Bool()
+	case c.Type == "date":
+		return faker.TimeBetween(timeFrom, timeTo).Format("2006-01-02")
+	case strings.HasPrefix(c.Type, "timestamp"):
+		return faker.TimeBetween(timeFrom, timeTo)
+	case strings.HasPrefix(c.Type, "time"):
+		return faker.TimeBetween(timeFrom, timeTo).Format("15:04:05")
+	case c.Type == "uuid":
+		return faker.UUID()
+	case c.Type == "bytea":
+		return faker.Bytes(16)
+	case c.Type == "json" || c.Type == "jsonb":
+		return fmt.Sprintf(`{"value": %q}`, faker.Word())
+	case c.Type == "inet" || c.Type == "cidr":
+		return faker.IPv4()
+	}
+
+	s := textFor(c.Name, faker)
+	if attempt > 0 {
+		suffix := strconv.Itoa(index + 1)
+		if local, domain, ok := strings.Cut(s, "@"); ok {
+			s = local + "." + suffix + "@" + domain
+		} else {
+			s += suffix
+		}
+	}
+	if c.MaxLength > 0 && len(s) > c.MaxLength {
+		s = s[len(s)-c.MaxLength:]
+	}
+	return s
+}
+
+// textFor picks a realistic value for a text column from its name
+func textFor(name string, faker *anonymizer.Faker) string {
+	n := strings.ToLower(name)
+	switch {
+	case strings.Contains(n, "email"):
+		return faker.Email()
+	case n == "first_name" || n == "firstname" || n == "given_name":
+		return faker.FirstName()
+	case n == "last_name" || n == "lastname" || n == "surname" || n == "family_name":
+		return faker.LastName()
+	case n == "username" || n == "login" || n == "handle":
+		return strings.ToLower(faker.FirstName() + faker.LastName())
+	case strings.Contains(n, "name"):
+		return faker.Name()
+	case strings.Contains(n, "phone") || strings.Contains(n, "mobile"):
+		return faker.Phone()
+	case strings.Contains(n, "address") || strings.Contains(n, "street"):
+		return faker.Address()
+	case strings.Contains(n, "ssn"):
+		return faker.SSN()
+	case strings.Contains(n, "password") || strings.Contains(n, "hash") || strings.Contains(n, "token"):
+		return fmt.Sprintf("%x", faker.Bytes(32))
+	case strings.HasSuffix(n, "ip") || strings.Contains(n, "ip_address"):
+		return faker.IPv4()
+	case strings.Contains(n, "description") || strings.Contains(n, "comment") || strings.Contains(n, "note"):
+		return faker.Sentence(8)
+	default:
+		return faker.Word()
+	}
+}
+
+// bounds returns the range of a numeric column, narrowed by its CHECK constraints
+func (c *Column) bounds(lo, hi float64) (float64, float64) {
+	if c.Min != nil {
+		lo = *c.Min
+		if c.Max == nil && hi < lo {
+			hi = lo + 1000
+		}
+	}
+	if c.Max != nil {
+		hi = *c.Max
+		if c.Min == nil && lo > hi {
+			lo = hi - 1000
+		}
+	}
+	return lo, hi
+}
+
+// parentKeys returns the values of a referenced column, in a stable order
+func (g *Generator) parentKeys(ctx context.Context, fk *ForeignKey) ([]interface{}, error) {
+	key := fk.Table + "." + fk.Column
+	if keys, ok := g.keys[key]; ok {
+		return keys, nil
+	}
+
+	rows, err := g.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %[1]s IS NOT NULL ORDER BY 1",
+		quoteIdent(fk.Column), quoteIdent(fk.Table)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys of %s: %w", fk.Table, err)
+	}
+	defer rows.Close()
+
+	var keys []interface{}
+	for rows.Next() {
+		var v interface{}
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("failed to scan key: %w", err)
+		}
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		keys = append(keys, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	g.keys[key] = keys
+	return keys, nil
+}
+
+// tableSeed derives a seed per table so adding a table doesn't change the
+// rows generated for the others
+func (g *Generator) tableSeed(table string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(table))
+	return g.config.Seed ^ int64(h.Sum64())
+}
+
+// GenerateReport generates a summary report
+func GenerateReport(results []Result) string {
+	var report string
+	report += "\n========================================\n"
+	report += "        SYNTHETIC DATA GENERATION        \n"
+	report += "========================================\n\n"
+
+	totalRows := int64(0)
+	errors := 0
+	for _, r := range results {
+		if r.Error != nil {
+			errors++
+			report += fmt.Sprintf("✗ %s - ERROR: %s\n", r.Table, r.Error.Error())
+			continue
+		}
+		totalRows += r.Rows
+		report += fmt.Sprintf("✓ %s - %d rows (%s)\n", r.Table, r.Rows, r.Duration.Round(time.Millisecond))
+	}
+
+	report += "\n========================================\n"
+	report += fmt.Sprintf("Total Tables:    %d\n", len(results))
+	report += fmt.Sprintf("Errors:          %d\n", errors)
+	report += fmt.Sprintf("Total Rows:      %d\n", totalRows)
+	report += "========================================\n"
+
+	return report
+}
+
+// isInteger reports whether a column type is an integer type
+func isInteger(typ string) bool {
+	return typ == "smallint" || typ == "integer" || typ == "bigint"
+}
+
+// isDecimal reports whether a column type is a fractional number type
+func isDecimal(typ string) bool {
+	return typ == "numeric" || typ == "real" || typ == "double precision"
+}
+
+// quoteIdent quotes a SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,274 @@
+package generator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"github.com/lib/pq"
+	"github.com/thien/database-migration-tool/internal/logger"
+	"github.com/thien/database-migration-tool/internal/pii"
+	"go.uber.org/zap"
+)
+
+// LoadTables introspects the tables of the public schema of a database with
+// their column types, nullability, unique, foreign key and CHECK constraints
+func LoadTables(ctx context.Context, db *sql.DB) ([]*Table, error) {
+	query := `
+		SELECT c.table_name, c.column_name,
+			CASE WHEN c.data_type = 'USER-DEFINED' THEN c.udt_name ELSE c.data_type END,
+			c.is_nullable = 'YES',
+			c.is_identity = 'YES' OR coalesce(c.column_default, '') LIKE 'nextval(%',
+			coalesce(c.character_maximum_length, 0),
+			coalesce(c.numeric_scale, 0)
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = 'public' AND t.table_type = 'BASE TABLE'
+			AND c.table_name NOT IN ('schema_migrations', 'atlas_schema_revisions')
+		ORDER BY c.table_name, c.ordinal_position
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []*Table
+	byName := make(map[string]*Table)
+	for rows.Next() {
+		var tableName string
+		col := &Column{}
+		if err := rows.Scan(&tableName, &col.Name, &col.Type, &col.Nullable, &col.Serial, &col.MaxLength, &col.Scale); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		t, ok := byName[tableName]
+		if !ok {
+			t = &Table{Name: tableName}
+			byName[tableName] = t
+			tables = append(tables, t)
+		}
+		t.Columns = append(t.Columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during column iteration: %w", err)
+	}
+
+	if err := loadEnums(ctx, db, tables); err != nil {
+		return nil, err
+	}
+	if err := loadConstraints(ctx, db, byName); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// loadEnums fills the allowed values of columns of an enum type
+func loadEnums(ctx context.Context, db *sql.DB, tables []*Table) error {
+	query := `
+		SELECT t.typname, e.enumlabel
+		FROM pg_enum e
+		JOIN pg_type t ON t.oid = e.enumtypid
+		ORDER BY t.typname, e.enumsortorder
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to query enum types: %w", err)
+	}
+	defer rows.Close()
+
+	labels := make(map[string][]string)
+	for rows.Next() {
+		var typ, label string
+		if err := rows.Scan(&typ, &label); err != nil {
+			return fmt.Errorf("failed to scan enum label: %w", err)
+		}
+		labels[typ] = append(labels[typ], label)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			if values, ok := labels[c.Type]; ok {
+				c.Enum = values
+			}
+		}
+	}
+	return nil
+}
+
+// loadConstraints reads primary key, unique, foreign key and CHECK constraints
+func loadConstraints(ctx context.Context, db *sql.DB, tables map[string]*Table) error {
+	query := `
+		SELECT r.relname, c.contype,
+			ARRAY(SELECT a.attname FROM unnest(c.conkey) WITH ORDINALITY k(num, pos)
+				JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.num ORDER BY k.pos)::text[],
+			coalesce(f.relname, ''),
+			ARRAY(SELECT a.attname FROM unnest(c.confkey) WITH ORDINALITY k(num, pos)
+				JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.num ORDER BY k.pos)::text[],
+			pg_get_constraintdef(c.oid)
+		FROM pg_constraint c
+		JOIN pg_class r ON r.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = r.relnamespace
+		LEFT JOIN pg_class f ON f.oid = c.confrelid
+		WHERE n.nspname = 'public' AND c.contype IN ('p', 'u', 'f', 'c')
+		ORDER BY r.relname, c.conname
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to query constraints: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, kind, refTable, def string
+		var columns, refColumns []string
+		if err := rows.Scan(&tableName, &kind, pq.Array(&columns), &refTable, pq.Array(&refColumns), &def); err != nil {
+			return fmt.Errorf("failed to scan constraint: %w", err)
+		}
+		t, ok := tables[tableName]
+		if !ok {
+			continue
+		}
+
+		switch kind {
+		case "p":
+			t.PrimaryKey = columns
+			t.Uniques = append(t.Uniques, columns)
+		case "u":
+			t.Uniques = append(t.Uniques, columns)
+		case "f":
+			if len(columns) != 1 || len(refColumns) != 1 {
+				logger.Warn("Skipping multi-column foreign key",
+					zap.String("table", tableName), zap.String("constraint", def))
+				continue
+			}
+			if c := t.column(columns[0]); c != nil {
+				c.FK = &ForeignKey{Table: refTable, Column: refColumns[0]}
+			}
+		case "c":
+			if !applyCheck(t, def) {
+				t.Checks = append(t.Checks, def)
+			}
+		}
+	}
+	return rows.Err()
+}
+
+// LoadEntTables builds the tables from Ent schemas. Ent adds an "id" serial
+// primary key to every table; edges with an explicit field become foreign
+// keys to the id of the referenced table. Validators such as Positive() are
+// functions and can't be read back, so numbers are generated positive.
+func LoadEntTables(schemas []ent.Interface) []*Table {
+	tableOf := make(map[string]string, len(schemas))
+	for _, s := range schemas {
+		tableOf[schemaName(s)] = pii.TableName(s)
+	}
+
+	var tables []*Table
+	for _, s := range schemas {
+		t := &Table{
+			Name:       pii.TableName(s),
+			Columns:    []*Column{{Name: "id", Type: "bigint", Serial: true}},
+			PrimaryKey: []string{"id"},
+			Uniques:    [][]string{{"id"}},
+		}
+
+		refs := make(map[string]string)
+		for _, e := range s.Edges() {
+			d := e.Descriptor()
+			if d.Field != "" {
+				refs[d.Field] = tableOf[d.Type]
+			}
+		}
+
+		for _, f := range s.Fields() {
+			d := f.Descriptor()
+			col := &Column{
+				Name:     d.StorageKey,
+				Type:     entType(d),
+				Nullable: d.Optional || d.Nillable,
+			}
+			if col.Name == "" {
+				col.Name = d.Name
+			}
+			if d.Info.Type == field.TypeString && d.Size > 0 && d.Size < math.MaxInt32 {
+				col.MaxLength = d.Size
+			}
+			if d.Info.Type.Float() {
+				col.Scale = 2
+			}
+			for _, e := range d.Enums {
+				col.Enum = append(col.Enum, e.V)
+			}
+			if ref, ok := refs[d.Name]; ok && ref != "" {
+				col.FK = &ForeignKey{Table: ref, Column: "id"}
+			}
+			if d.Unique {
+				t.Uniques = append(t.Uniques, []string{col.Name})
+			}
+			t.Columns = append(t.Columns, col)
+		}
+
+		for _, idx := range s.Indexes() {
+			d := idx.Descriptor()
+			if d.Unique && len(d.Edges) == 0 {
+				t.Uniques = append(t.Uniques, d.Fields)
+			}
+		}
+
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+// entType maps an Ent field to the PostgreSQL type Ent migrates it to
+func entType(d *field.Descriptor) string {
+	if typ, ok := d.SchemaType["postgres"]; ok {
+		return strings.ToLower(typ)
+	}
+	switch t := d.Info.Type; {
+	case t == field.TypeBool:
+		return "boolean"
+	case t == field.TypeTime:
+		return "timestamp with time zone"
+	case t == field.TypeJSON:
+		return "jsonb"
+	case t == field.TypeUUID:
+		return "uuid"
+	case t == field.TypeBytes:
+		return "bytea"
+	case t == field.TypeString && d.Size >= math.MaxInt32:
+		return "text"
+	case t == field.TypeInt8, t == field.TypeInt16, t == field.TypeUint8, t == field.TypeUint16:
+		return "smallint"
+	case t == field.TypeInt32:
+		return "integer"
+	case t.Integer():
+		return "bigint"
+	case t == field.TypeFloat32:
+		return "real"
+	case t.Float():
+		return "double precision"
+	default:
+		return "character varying"
+	}
+}
+
+// schemaName returns the type name of a schema, as referenced by edges
+func schemaName(s ent.Interface) string {
+	t := reflect.TypeOf(s)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
@@ -0,0 +1,106 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Table describes a table to fill with synthetic rows
+type Table struct {
+	Name       string
+	Columns    []*Column
+	PrimaryKey []string
+	Uniques    [][]string // unique constraints, including the primary key
+	Checks     []string   // CHECK constraints that could not be parsed
+}
+
+// Column describes a column and the constraints its values must satisfy
+type Column struct {
+	Name      string
+	Type      string // information_schema data_type, e.g. integer, character varying
+	Nullable  bool
+	Serial    bool // filled by a sequence or identity, never generated
+	MaxLength int  // for character types, 0 when unbounded
+	Scale     int  // for numeric types
+
+	Enum     []string // allowed values from an enum type or CHECK (col IN ...)
+	Min      *float64 // inclusive bounds from CHECK constraints
+	Max      *float64
+	NonEmpty bool // CHECK (col <> '') or length(col) > 0
+
+	FK *ForeignKey
+}
+
+// ForeignKey references a column of another table
+type ForeignKey struct {
+	Table  string
+	Column string
+}
+
+// column returns a column by name
+func (t *Table) column(name string) *Column {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// sortTables orders tables so every table comes after the tables it
+// references. Nullable references that form a cycle are left NULL.
+func sortTables(tables []*Table) ([]*Table, error) {
+	byName := make(map[string]*Table, len(tables))
+	for _, t := range tables {
+		byName[t.Name] = t
+	}
+
+	var sorted []*Table
+	state := make(map[string]int) // 0 unvisited, 1 visiting, 2 done
+	var visit func(t *Table) error
+	visit = func(t *Table) error {
+		switch state[t.Name] {
+		case 1:
+			return fmt.Errorf("foreign key cycle through %s", t.Name)
+		case 2:
+			return nil
+		}
+		state[t.Name] = 1
+
+		for _, c := range t.Columns {
+			if c.FK == nil || c.FK.Table == t.Name {
+				continue
+			}
+			parent, ok := byName[c.FK.Table]
+			if !ok {
+				continue // parent is not generated, existing rows are used
+			}
+			if err := visit(parent); err != nil {
+				if !c.Nullable {
+					// Leave the table unvisited so a caller that breaks
+					// the cycle on its own reference can visit it again
+					state[t.Name] = 0
+					return err
+				}
+				// Break the cycle on a nullable reference
+				c.FK = nil
+			}
+		}
+
+		state[t.Name] = 2
+		sorted = append(sorted, t)
+		return nil
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(byName[name]); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortTables(t *testing.T) {
+	ref := func(table string, nullable bool) *Column {
+		return &Column{Name: table + "_id", Type: "bigint", Nullable: nullable, FK: &ForeignKey{Table: table, Column: "id"}}
+	}
+	table := func(name string, columns ...*Column) *Table {
+		return &Table{Name: name, Columns: append([]*Column{{Name: "id", Type: "bigint", Serial: true}}, columns...)}
+	}
+
+	tests := []struct {
+		name    string
+		tables  func() []*Table
+		want    string
+		nulled  string // the reference left NULL to break a cycle
+		wantErr bool
+	}{
+		{
+			name: "parents first",
+			tables: func() []*Table {
+				return []*Table{table("orders", ref("users", false)), table("users"), table("items", ref("orders", false))}
+			},
+			want: "users,orders,items",
+		},
+		{
+			name: "self references and tables outside the set are ignored",
+			tables: func() []*Table {
+				return []*Table{table("users", ref("users", true), ref("accounts", false))}
+			},
+			want: "users",
+		},
+		{
+			name: "the nullable reference of a cycle is broken, visited from the nullable side",
+			tables: func() []*Table {
+				return []*Table{table("a", ref("b", true)), table("b", ref("a", false))}
+			},
+			want:   "a,b",
+			nulled: "a",
+		},
+		{
+			name: "the nullable reference of a cycle is broken, visited from the required side",
+			tables: func() []*Table {
+				return []*Table{table("a", ref("b", false)), table("b", ref("a", true))}
+			},
+			want:   "b,a",
+			nulled: "b",
+		},
+		{
+			name: "a cycle of required references fails",
+			tables: func() []*Table {
+				return []*Table{table("a", ref("b", false)), table("b", ref("a", false))}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables := tt.tables()
+			sorted, err := sortTables(tables)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sortTables() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var names []string
+			for _, s := range sorted {
+				names = append(names, s.Name)
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("sortTables() = %s, want %s", got, tt.want)
+			}
+			for _, s := range tables {
+				if len(s.Columns) < 2 {
+					continue
+				}
+				broken := s.Columns[1].FK == nil
+				if broken != (s.Name == tt.nulled) {
+					t.Errorf("table %s: reference nulled = %v", s.Name, broken)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package introspect
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QuoteIdent quotes a SQL identifier
+func QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// QuoteLiteral quotes a SQL string literal
+func QuoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// CreateExtension returns the statement installing an extension
+func CreateExtension(e Extension) string {
+	return fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s WITH SCHEMA %s;", QuoteIdent(e.Name), QuoteIdent(e.Schema))
+}
+
+// CreateEnum returns the statement creating an enum type
+func CreateEnum(e Enum) string {
+	values := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = QuoteLiteral(v)
+	}
+	return fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", QuoteIdent(e.Name), strings.Join(values, ", "))
+}
+
+// CreateSequence returns the statement creating a sequence
+func CreateSequence(q Sequence) string {
+	stmt := fmt.Sprintf("CREATE SEQUENCE %s AS %s START WITH %d INCREMENT BY %d MINVALUE %d MAXVALUE %d CACHE %d",
+		QuoteIdent(q.Name), q.Type, q.Start, q.Increment, q.Min, q.Max, q.Cache)
+	if q.Cycle {
+		stmt += " CYCLE"
+	}
+	return stmt + ";"
+}
+
+// OwnSequence returns the statement tying a sequence to its column
+func OwnSequence(q Sequence) string {
+	table, column, _ := strings.Cut(q.OwnedBy, ".")
+	return fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.%s;", QuoteIdent(q.Name), QuoteIdent(table), QuoteIdent(column))
+}
+
+// ColumnDefinition returns the definition of a column in CREATE TABLE or
+// ADD COLUMN
+func ColumnDefinition(c Column) string {
+	def := QuoteIdent(c.Name) + " " + c.Type
+	if c.Collation != "" {
+		def += " COLLATE " + c.Collation
+	}
+	switch {
+	case c.Identity != "":
+		def += " GENERATED " + c.Identity + " AS IDENTITY"
+	case c.Generated != "":
+		def += " GENERATED ALWAYS AS (" + c.Generated + ") STORED"
+	case c.Default != "":
+		def += " DEFAULT " + c.Default
+	}
+	if c.NotNull {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+// CreateTable returns the statement creating a table with its columns.
+// Constraints, indexes and triggers are separate statements.
+func CreateTable(t *Table) string {
+	if t.PartitionOf != "" {
+		return fmt.Sprintf("CREATE TABLE %s PARTITION OF %s %s;", QuoteIdent(t.Name), QuoteIdent(t.PartitionOf), t.PartitionBound)
+	}
+
+	columns := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		columns[i] = "    " + ColumnDefinition(c)
+	}
+	stmt := fmt.Sprintf("CREATE TABLE %s (\n%s\n)", QuoteIdent(t.Name), strings.Join(columns, ",\n"))
+	if t.PartitionKey != "" {
+		stmt += " PARTITION BY " + t.PartitionKey
+	}
+	return stmt + ";"
+}
+
+// AddConstraint returns the statement adding a constraint to a table
+func AddConstraint(table string, c Constraint) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s;", QuoteIdent(table), QuoteIdent(c.Name), c.Definition)
+}
+
+// CreateIndex returns the statement creating an index
+func CreateIndex(i Index) string {
+	return i.Definition + ";"
+}
+
+// CreateTrigger returns the statements creating a trigger, disabled when it
+// is disabled in the catalog
+func CreateTrigger(table string, t Trigger) string {
+	stmt := t.Definition + ";"
+	if !t.Enabled {
+		stmt += fmt.Sprintf("\nALTER TABLE %s DISABLE TRIGGER %s;", QuoteIdent(table), QuoteIdent(t.Name))
+	}
+	return stmt
+}
+
+// CreateView returns the statement creating a view
+func CreateView(v View) string {
+	kind := "VIEW"
+	if v.Materialized {
+		kind = "MATERIALIZED VIEW"
+	}
+	query := strings.TrimSuffix(strings.TrimSpace(v.Definition), ";")
+	return fmt.Sprintf("CREATE %s %s AS\n%s;", kind, QuoteIdent(v.Name), query)
+}
+
+// CreateFunction returns the statement creating a function or procedure
+func CreateFunction(f Function) string {
+	return strings.TrimSpace(f.Definition) + ";"
+}
+
+// CommentOnTable returns the statement setting the comment of a table
+func CommentOnTable(t *Table) string {
+	return fmt.Sprintf("COMMENT ON TABLE %s IS %s;", QuoteIdent(t.Name), QuoteLiteral(t.Comment))
+}
+
+// CommentOnColumn returns the statement setting the comment of a column
+func CommentOnColumn(table string, c Column) string {
+	return fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;", QuoteIdent(table), QuoteIdent(c.Name), QuoteLiteral(c.Comment))
+}
+
+// SQL returns the DDL creating the schema in an empty database, in
+// dependency order: extensions, types, sequences and functions, tables,
+// their constraints and indexes, foreign keys, views and triggers.
+func (s *Schema) SQL() string {
+	var b strings.Builder
+	section := func(title string, stmts []string) {
+		if len(stmts) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "-- %s\n\n", title)
+		for _, stmt := range stmts {
+			b.WriteString(stmt + "\n\n")
+		}
+	}
+
+	// Function bodies may refer to tables created later
+	b.WriteString("SET check_function_bodies = false;\n\n")
+
+	var stmts []string
+	for _, e := range s.Extensions {
+		stmts = append(stmts, CreateExtension(e))
+	}
+	section("Extensions", stmts)
+
+	stmts = nil
+	for _, e := range s.Enums {
+		stmts = append(stmts, CreateEnum(e))
+	}
+	section("Types", stmts)
+
+	stmts = nil
+	for _, q := range s.Sequences {
+		if !q.Identity {
+			stmts = append(stmts, CreateSequence(q))
+		}
+	}
+	section("Sequences", stmts)
+
+	stmts = nil
+	for _, f := range s.Functions {
+		stmts = append(stmts, CreateFunction(f))
+	}
+	section("Functions", stmts)
+
+	stmts = nil
+	for _, t := range s.Tables {
+		stmts = append(stmts, CreateTable(t))
+	}
+	for _, q := range s.Sequences {
+		if !q.Identity && q.OwnedBy != "" {
+			stmts = append(stmts, OwnSequence(q))
+		}
+	}
+	section("Tables", stmts)
+
+	stmts = nil
+	var fks []string
+	for _, t := range s.Tables {
+		for _, c := range t.Constraints {
+			if c.Kind == ForeignKey {
+				fks = append(fks, AddConstraint(t.Name, c))
+			} else {
+				stmts = append(stmts, AddConstraint(t.Name, c))
+			}
+		}
+		for _, i := range t.Indexes {
+			if !i.Constraint {
+				stmts = append(stmts, CreateIndex(i))
+			}
+		}
+	}
+	section("Constraints and indexes", stmts)
+	section("Foreign keys", fks)
+
+	stmts = nil
+	for _, v := range s.Views {
+		stmts = append(stmts, CreateView(v))
+	}
+	section("Views", stmts)
+
+	stmts = nil
+	for _, t := range s.Tables {
+		for _, tr := range t.Triggers {
+			stmts = append(stmts, CreateTrigger(t.Name, tr))
+		}
+	}
+	section("Triggers", stmts)
+
+	stmts = nil
+	for _, t := range s.Tables {
+		if t.Comment != "" {
+			stmts = append(stmts, CommentOnTable(t))
+		}
+		for _, c := range t.Columns {
+			if c.Comment != "" {
+				stmts = append(stmts, CommentOnColumn(t.Name, c))
+			}
+		}
+	}
+	section("Comments", stmts)
+
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
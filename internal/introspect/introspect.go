@@ -0,0 +1,316 @@
+// Package introspect reads the pg_catalog of a Postgres database into a
+// typed schema model, without external tools
+package introspect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// notExtension leaves out the objects created by extensions; %s is the
+// object's oid
+const notExtension = `NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = %s AND d.deptype = 'e')`
+
+// Inspect reads the objects of a schema, e.g. public. Extensions are
+// database-wide and all listed except plpgsql.
+func Inspect(ctx context.Context, db *sql.DB, schema string) (*Schema, error) {
+	s := &Schema{Name: schema, Tables: []*Table{}}
+
+	steps := []struct {
+		name string
+		load func(context.Context, *sql.DB, *Schema) error
+	}{
+		{"extensions", loadExtensions},
+		{"enums", loadEnums},
+		{"sequences", loadSequences},
+		{"tables", loadTables},
+		{"columns", loadColumns},
+		{"constraints", loadConstraints},
+		{"indexes", loadIndexes},
+		{"triggers", loadTriggers},
+		{"views", loadViews},
+		{"functions", loadFunctions},
+	}
+	for _, step := range steps {
+		if err := step.load(ctx, db, s); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", step.name, err)
+		}
+	}
+	return s, nil
+}
+
+// scanRows runs a query and calls scan for every row
+func scanRows(ctx context.Context, db *sql.DB, query string, scan func(*sql.Rows) error, args ...interface{}) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func loadExtensions(ctx context.Context, db *sql.DB, s *Schema) error {
+	query := `
+		SELECT e.extname, e.extversion, n.nspname
+		FROM pg_extension e
+		JOIN pg_namespace n ON n.oid = e.extnamespace
+		WHERE e.extname <> 'plpgsql'
+		ORDER BY e.extname
+	`
+	return scanRows(ctx, db, query, func(rows *sql.Rows) error {
+		var e Extension
+		if err := rows.Scan(&e.Name, &e.Version, &e.Schema); err != nil {
+			return err
+		}
+		s.Extensions = append(s.Extensions, e)
+		return nil
+	})
+}
+
+func loadEnums(ctx context.Context, db *sql.DB, s *Schema) error {
+	query := fmt.Sprintf(`
+		SELECT t.typname, array_agg(e.enumlabel::text ORDER BY e.enumsortorder)
+		FROM pg_type t
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE n.nspname = $1 AND %s
+		GROUP BY t.typname
+		ORDER BY t.typname
+	`, fmt.Sprintf(notExtension, "t.oid"))
+	return scanRows(ctx, db, query, func(rows *sql.Rows) error {
+		var e Enum
+		if err := rows.Scan(&e.Name, pq.Array(&e.Values)); err != nil {
+			return err
+		}
+		s.Enums = append(s.Enums, e)
+		return nil
+	}, s.Name)
+}
+
+func loadSequences(ctx context.Context, db *sql.DB, s *Schema) error {
+	query := fmt.Sprintf(`
+		SELECT c.relname, format_type(q.seqtypid, NULL), q.seqstart, q.seqincrement,
+			q.seqmin, q.seqmax, q.seqcache, q.seqcycle,
+			coalesce(o.relname || '.' || a.attname, ''), coalesce(d.deptype = 'i', false)
+		FROM pg_sequence q
+		JOIN pg_class c ON c.oid = q.seqrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_depend d ON d.objid = c.oid AND d.classid = 'pg_class'::regclass
+			AND d.refclassid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+		LEFT JOIN pg_class o ON o.oid = d.refobjid
+		LEFT JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+		WHERE n.nspname = $1 AND %s
+		ORDER BY c.relname
+	`, fmt.Sprintf(notExtension, "c.oid"))
+	return scanRows(ctx, db, query, func(rows *sql.Rows) error {
+		var q Sequence
+		if err := rows.Scan(&q.Name, &q.Type, &q.Start, &q.Increment, &q.Min, &q.Max, &q.Cache, &q.Cycle, &q.OwnedBy, &q.Identity); err != nil {
+			return err
+		}
+		s.Sequences = append(s.Sequences, q)
+		return nil
+	}, s.Name)
+}
+
+func loadTables(ctx context.Context, db *sql.DB, s *Schema) error {
+	query := fmt.Sprintf(`
+		SELECT c.relname, c.relkind = 'p', coalesce(pg_get_partkeydef(c.oid), ''),
+			coalesce(p.relname, ''), coalesce(pg_get_expr(c.relpartbound, c.oid), ''),
+			coalesce(obj_description(c.oid, 'pg_class'), '')
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_inherits i ON i.inhrelid = c.oid AND c.relispartition
+		LEFT JOIN pg_class p ON p.oid = i.inhparent
+		WHERE n.nspname = $1 AND c.relkind IN ('r', 'p') AND %s
+		ORDER BY c.relispartition, c.relname
+	`, fmt.Sprintf(notExtension, "c.oid"))
+	return scanRows(ctx, db, query, func(rows *sql.Rows) error {
+		t := &Table{Columns: []Column{}}
+		if err := rows.Scan(&t.Name, &t.Partitioned, &t.PartitionKey, &t.PartitionOf, &t.PartitionBound, &t.Comment); err != nil {
+			return err
+		}
+		s.Tables = append(s.Tables, t)
+		return nil
+	}, s.Name)
+}
+
+func loadColumns(ctx context.Context, db *sql.DB, s *Schema) error {
+	query := `
+		SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+			coalesce(pg_get_expr(d.adbin, d.adrelid), ''), a.attidentity::text, a.attgenerated::text,
+			coalesce(CASE WHEN a.attcollation <> t.typcollation THEN quote_ident(co.collname) END, ''),
+			coalesce(col_description(c.oid, a.attnum), '')
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_type t ON t.oid = a.atttypid
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		LEFT JOIN pg_collation co ON co.oid = a.attcollation
+		WHERE n.nspname = $1 AND c.relkind IN ('r', 'p') AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY c.relname, a.attnum
+	`
+	return scanRows(ctx, db, query, func(rows *sql.Rows) error {
+		var table, identity, generated string
+		var col Column
+		if err := rows.Scan(&table, &col.Name, &col.Type, &col.NotNull, &col.Default, &identity, &generated, &col.Collation, &col.Comment); err != nil {
+			return err
+		}
+		switch identity {
+		case "a":
+			col.Identity = "ALWAYS"
+		case "d":
+			col.Identity = "BY DEFAULT"
+		}
+		if generated == "s" {
+			col.Generated, col.Default = col.Default, ""
+		}
+		if t := s.Table(table); t != nil {
+			t.Columns = append(t.Columns, col)
+		}
+		return nil
+	}, s.Name)
+}
+
+// foreignKeyActions maps confdeltype and confupdtype to SQL
+var foreignKeyActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+func loadConstraints(ctx context.Context, db *sql.DB, s *Schema) error {
+	query := `
+		SELECT r.relname, c.conname, c.contype::text, pg_get_constraintdef(c.oid),
+			ARRAY(SELECT a.attname::text FROM unnest(c.conkey) WITH ORDINALITY AS k(attnum, n)
+				JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum ORDER BY k.n),
+			coalesce(f.relname, ''),
+			ARRAY(SELECT a.attname::text FROM unnest(c.confkey) WITH ORDINALITY AS k(attnum, n)
+				JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.attnum ORDER BY k.n),
+			c.confdeltype::text, c.confupdtype::text, c.condeferrable, c.convalidated
+		FROM pg_constraint c
+		JOIN pg_class r ON r.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = r.relnamespace
+		LEFT JOIN pg_class f ON f.oid = c.confrelid
+		WHERE n.nspname = $1 AND c.contype IN ('p', 'u', 'f', 'c', 'x') AND r.relkind IN ('r', 'p')
+			AND c.conparentid = 0 AND c.conislocal
+		ORDER BY r.relname, c.conname
+	`
+	return scanRows(ctx, db, query, func(rows *sql.Rows) error {
+		var table, onDelete, onUpdate string
+		var c Constraint
+		if err := rows.Scan(&table, &c.Name, &c.Kind, &c.Definition, pq.Array(&c.Columns), &c.RefTable,
+			pq.Array(&c.RefColumns), &onDelete, &onUpdate, &c.Deferrable, &c.Validated); err != nil {
+			return err
+		}
+		if c.Kind == ForeignKey {
+			c.OnDelete, c.OnUpdate = foreignKeyActions[onDelete], foreignKeyActions[onUpdate]
+		}
+		if t := s.Table(table); t != nil {
+			t.Constraints = append(t.Constraints, c)
+		}
+		return nil
+	}, s.Name)
+}
+
+func loadIndexes(ctx context.Context, db *sql.DB, s *Schema) error {
+	query := `
+		SELECT t.relname, i.relname, x.indisunique, x.indisprimary, am.amname,
+			ARRAY(SELECT pg_get_indexdef(x.indexrelid, k, true) FROM generate_series(1, x.indnkeyatts::int) AS k),
+			coalesce(pg_get_expr(x.indpred, x.indrelid, true), ''), pg_get_indexdef(x.indexrelid),
+			EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = x.indexrelid
+				AND c.conrelid = x.indrelid AND c.contype IN ('p', 'u', 'x'))
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		JOIN pg_class t ON t.oid = x.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_am am ON am.oid = i.relam
+		WHERE n.nspname = $1 AND t.relkind IN ('r', 'p')
+			AND NOT EXISTS (SELECT 1 FROM pg_inherits ih WHERE ih.inhrelid = x.indexrelid)
+		ORDER BY t.relname, i.relname
+	`
+	return scanRows(ctx, db, query, func(rows *sql.Rows) error {
+		var table string
+		var idx Index
+		if err := rows.Scan(&table, &idx.Name, &idx.Unique, &idx.Primary, &idx.Method, pq.Array(&idx.Keys),
+			&idx.Predicate, &idx.Definition, &idx.Constraint); err != nil {
+			return err
+		}
+		if t := s.Table(table); t != nil {
+			t.Indexes = append(t.Indexes, idx)
+		}
+		return nil
+	}, s.Name)
+}
+
+func loadTriggers(ctx context.Context, db *sql.DB, s *Schema) error {
+	query := `
+		SELECT c.relname, t.tgname, pg_get_triggerdef(t.oid), t.tgenabled <> 'D'
+		FROM pg_trigger t
+		JOIN pg_class c ON c.oid = t.tgrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND NOT t.tgisinternal AND t.tgparentid = 0
+		ORDER BY c.relname, t.tgname
+	`
+	return scanRows(ctx, db, query, func(rows *sql.Rows) error {
+		var table string
+		var tr Trigger
+		if err := rows.Scan(&table, &tr.Name, &tr.Definition, &tr.Enabled); err != nil {
+			return err
+		}
+		if t := s.Table(table); t != nil {
+			t.Triggers = append(t.Triggers, tr)
+		}
+		return nil
+	}, s.Name)
+}
+
+func loadViews(ctx context.Context, db *sql.DB, s *Schema) error {
+	// In creation order, which respects the dependencies between views in
+	// all but unusual cases (views replaced to depend on later views)
+	query := fmt.Sprintf(`
+		SELECT c.relname, c.relkind = 'm', pg_get_viewdef(c.oid)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relkind IN ('v', 'm') AND %s
+		ORDER BY c.oid
+	`, fmt.Sprintf(notExtension, "c.oid"))
+	return scanRows(ctx, db, query, func(rows *sql.Rows) error {
+		var v View
+		if err := rows.Scan(&v.Name, &v.Materialized, &v.Definition); err != nil {
+			return err
+		}
+		s.Views = append(s.Views, v)
+		return nil
+	}, s.Name)
+}
+
+func loadFunctions(ctx context.Context, db *sql.DB, s *Schema) error {
+	query := fmt.Sprintf(`
+		SELECT p.proname, pg_get_function_identity_arguments(p.oid), coalesce(pg_get_function_result(p.oid), ''),
+			l.lanname, p.prokind = 'p', pg_get_functiondef(p.oid)
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		JOIN pg_language l ON l.oid = p.prolang
+		WHERE n.nspname = $1 AND p.prokind IN ('f', 'p') AND %s
+		ORDER BY p.proname, p.oid
+	`, fmt.Sprintf(notExtension, "p.oid"))
+	return scanRows(ctx, db, query, func(rows *sql.Rows) error {
+		var f Function
+		if err := rows.Scan(&f.Name, &f.Arguments, &f.Result, &f.Language, &f.Procedure, &f.Definition); err != nil {
+			return err
+		}
+		s.Functions = append(s.Functions, f)
+		return nil
+	}, s.Name)
+}
@@ -0,0 +1,149 @@
+package introspect
+
+// Schema is the typed model of a Postgres schema
+type Schema struct {
+	Name       string      `json:"name"`
+	Extensions []Extension `json:"extensions,omitempty"`
+	Enums      []Enum      `json:"enums,omitempty"`
+	Sequences  []Sequence  `json:"sequences,omitempty"`
+	Tables     []*Table    `json:"tables"`
+	Views      []View      `json:"views,omitempty"`
+	Functions  []Function  `json:"functions,omitempty"`
+}
+
+// Table is a regular or partitioned table
+type Table struct {
+	Name           string       `json:"name"`
+	Partitioned    bool         `json:"partitioned,omitempty"`
+	PartitionKey   string       `json:"partition_key,omitempty"`   // e.g. RANGE (created_at)
+	PartitionOf    string       `json:"partition_of,omitempty"`    // parent of a partition
+	PartitionBound string       `json:"partition_bound,omitempty"` // e.g. FOR VALUES FROM (...) TO (...)
+	Comment        string       `json:"comment,omitempty"`
+	Columns        []Column     `json:"columns"`
+	Constraints    []Constraint `json:"constraints,omitempty"`
+	Indexes        []Index      `json:"indexes,omitempty"`
+	Triggers       []Trigger    `json:"triggers,omitempty"`
+}
+
+// Column is a table column
+type Column struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"` // as format_type prints it, e.g. character varying(255)
+	NotNull   bool   `json:"not_null,omitempty"`
+	Default   string `json:"default,omitempty"`
+	Identity  string `json:"identity,omitempty"`  // ALWAYS or BY DEFAULT
+	Generated string `json:"generated,omitempty"` // expression of a stored generated column
+	Collation string `json:"collation,omitempty"` // when not the type's default
+	Comment   string `json:"comment,omitempty"`
+}
+
+// Constraint kinds
+const (
+	PrimaryKey = "p"
+	Unique     = "u"
+	ForeignKey = "f"
+	Check      = "c"
+	Exclusion  = "x"
+)
+
+// Constraint is a table constraint
+type Constraint struct {
+	Name       string   `json:"name"`
+	Kind       string   `json:"kind"`
+	Definition string   `json:"definition"` // as pg_get_constraintdef prints it
+	Columns    []string `json:"columns,omitempty"`
+	RefTable   string   `json:"ref_table,omitempty"`
+	RefColumns []string `json:"ref_columns,omitempty"`
+	OnDelete   string   `json:"on_delete,omitempty"`
+	OnUpdate   string   `json:"on_update,omitempty"`
+	Deferrable bool     `json:"deferrable,omitempty"`
+	Validated  bool     `json:"validated"`
+}
+
+// Index is a table index
+type Index struct {
+	Name       string   `json:"name"`
+	Unique     bool     `json:"unique,omitempty"`
+	Primary    bool     `json:"primary,omitempty"`
+	Method     string   `json:"method"`
+	Keys       []string `json:"keys"` // columns or expressions
+	Predicate  string   `json:"predicate,omitempty"`
+	Definition string   `json:"definition"`           // as pg_get_indexdef prints it
+	Constraint bool     `json:"constraint,omitempty"` // created by a primary key, unique or exclusion constraint
+}
+
+// Trigger is a table trigger
+type Trigger struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"` // as pg_get_triggerdef prints it
+	Enabled    bool   `json:"enabled"`
+}
+
+// Sequence is a sequence
+type Sequence struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Start     int64  `json:"start"`
+	Increment int64  `json:"increment"`
+	Min       int64  `json:"min"`
+	Max       int64  `json:"max"`
+	Cache     int64  `json:"cache"`
+	Cycle     bool   `json:"cycle,omitempty"`
+	OwnedBy   string `json:"owned_by,omitempty"` // table.column
+	Identity  bool   `json:"identity,omitempty"` // backs an identity column
+}
+
+// Enum is an enum type
+type Enum struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// View is a view or materialized view
+type View struct {
+	Name         string `json:"name"`
+	Materialized bool   `json:"materialized,omitempty"`
+	Definition   string `json:"definition"` // the query
+}
+
+// Function is a function or procedure
+type Function struct {
+	Name       string `json:"name"`
+	Arguments  string `json:"arguments"` // identity arguments, e.g. integer, text
+	Result     string `json:"result,omitempty"`
+	Language   string `json:"language"`
+	Procedure  bool   `json:"procedure,omitempty"`
+	Definition string `json:"definition"` // as pg_get_functiondef prints it
+}
+
+// Signature identifies a function among its overloads
+func (f Function) Signature() string {
+	return f.Name + "(" + f.Arguments + ")"
+}
+
+// Extension is an installed extension
+type Extension struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Schema  string `json:"schema"`
+}
+
+// Table returns a table by name, nil when there is none
+func (s *Schema) Table(name string) *Table {
+	for _, t := range s.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Column returns a column by name, nil when there is none
+func (t *Table) Column(name string) *Column {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
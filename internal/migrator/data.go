@@ -9,38 +9,67 @@ import (
 	"github.com/thien/database-migration-tool/internal/anonymizer"
 	"github.com/thien/database-migration-tool/internal/config"
 	"github.com/thien/database-migration-tool/internal/logger"
+	"github.com/thien/database-migration-tool/internal/transform"
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
 // DataMigrator handles data migration between databases
 type DataMigrator struct {
-	remoteDB   *sql.DB
-	localDB    *sql.DB
-	config     *config.MigrationConfig
-	anonymizer *anonymizer.Anonymizer
+	remoteDB    *sql.DB
+	localDB     *sql.DB
+	config      *config.MigrationConfig
+	anonymizer  *anonymizer.Anonymizer
+	transformer *transform.Transformer
 }
 
 // NewDataMigrator creates a new data migrator
 func NewDataMigrator(remoteDB, localDB *sql.DB, cfg *config.MigrationConfig) *DataMigrator {
 	return &DataMigrator{
-		remoteDB:   remoteDB,
-		localDB:    localDB,
-		config:     cfg,
-		anonymizer: anonymizer.NewAnonymizer(),
+		remoteDB:    remoteDB,
+		localDB:     localDB,
+		config:      cfg,
+		anonymizer:  anonymizer.NewAnonymizer(&cfg.Anonymization),
+		transformer: transform.NewTransformer(cfg.Transforms),
 	}
 }
 
+// Anonymizer returns the anonymizer used for the migration
+func (m *DataMigrator) Anonymizer() *anonymizer.Anonymizer {
+	return m.anonymizer
+}
+
 // MigrateResult holds migration results
 type MigrateResult struct {
 	Table        string
+	Columns      []string
 	RowsMigrated int64
 	Success      bool
 	Error        error
+	Replacements map[string]int64 // scrubbed PII spans per column/detector
+}
+
+// TotalReplacements sums the scrubbed PII spans over all results
+func TotalReplacements(results []MigrateResult) int64 {
+	var total int64
+	for _, r := range results {
+		for _, n := range r.Replacements {
+			total += n
+		}
+	}
+	return total
 }
 
 // MigrateAll migrates all tables or specified tables
 func (m *DataMigrator) MigrateAll(ctx context.Context) ([]MigrateResult, error) {
+	if m.config.Anonymize {
+		if err := m.anonymizer.ValidateRules(); err != nil {
+			return nil, fmt.Errorf("invalid anonymization rules: %w", err)
+		}
+	}
+
+	defer m.anonymizer.Close()
+
 	tables, err := m.getTablesToMigrate(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get tables: %w", err)
@@ -49,10 +78,27 @@ func (m *DataMigrator) MigrateAll(ctx context.Context) ([]MigrateResult, error)
 	logger.Info("Starting data migration", zap.Int("table_count", len(tables)))
 
 	var results []MigrateResult
+	server := m.config.Anonymize && m.config.Anonymization.Mode == ModeServer
+	if server {
+		logger.Info("Anonymizing in place on the local database (server mode)")
+	}
+
 	for _, table := range tables {
-		result := m.migrateTable(ctx, table)
+		var result MigrateResult
+		if server {
+			result = m.migrateTableServer(ctx, table)
+		} else {
+			result = m.migrateTable(ctx, table)
+		}
 		results = append(results, result)
 
+		// Server mode commits a table at once, the vault is saved after it so
+		// that no committed token is missing from it if the run aborts. Client
+		// mode saves it before each batch commit.
+		if _, err := m.anonymizer.SaveVault(); err != nil {
+			return results, fmt.Errorf("failed to save token vault: %w", err)
+		}
+
 		if !result.Success {
 			logger.Error("Failed to migrate table", 
 				zap.String("table", table),
@@ -61,12 +107,38 @@ func (m *DataMigrator) MigrateAll(ctx context.Context) ([]MigrateResult, error)
 			logger.Info("Successfully migrated table",
 				zap.String("table", table),
 				zap.Int64("rows", result.RowsMigrated))
+			if len(result.Replacements) > 0 {
+				logger.Info("Scrubbed PII in free text",
+					zap.String("table", table),
+					zap.Any("replacements", result.Replacements))
+			}
 		}
 	}
 
+	tokens, err := m.anonymizer.SaveVault()
+	if err != nil {
+		return results, fmt.Errorf("failed to save token vault: %w", err)
+	}
+	if tokens > 0 {
+		logger.Info("Saved token vault",
+			zap.String("path", m.config.Anonymization.Vault.Path),
+			zap.Int("tokens", tokens))
+	}
+
 	return results, nil
 }
 
+// BuildManifest records the strategy applied to every column of the migrated tables
+func (m *DataMigrator) BuildManifest(results []MigrateResult, source, target string) *anonymizer.Manifest {
+	manifest := anonymizer.NewManifest(source, target)
+	for _, r := range results {
+		if r.Success {
+			manifest.AddTable(m.anonymizer, r.Table, r.Columns, r.RowsMigrated)
+		}
+	}
+	return manifest
+}
+
 // getTablesToMigrate returns list of tables to migrate
 func (m *DataMigrator) getTablesToMigrate(ctx context.Context) ([]string, error) {
 	// If specific tables are configured, use those
@@ -129,6 +201,27 @@ func (m *DataMigrator) migrateTable(ctx context.Context, table string) MigrateRe
 		result.Error = fmt.Errorf("failed to get columns: %w", err)
 		return result
 	}
+	result.Columns = columns
+
+	// Compile transforms against the column list before reading any rows
+	program, err := m.transformer.Compile(table, columns)
+	if err != nil {
+		result.Error = fmt.Errorf("invalid transform: %w", err)
+		return result
+	}
+
+	if m.config.Anonymize {
+		types, err := m.getColumnTypes(ctx, table)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to get column types: %w", err)
+			return result
+		}
+		m.anonymizer.SetColumnTypes(table, types)
+		if err := m.checkTokenLengths(ctx, table, columns); err != nil {
+			result.Error = err
+			return result
+		}
+	}
 
 	// Read data from remote
 	selectQuery := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), table)
@@ -166,7 +259,7 @@ func (m *DataMigrator) migrateTable(ctx context.Context, table string) MigrateRe
 	}
 
 	var rowCount int64
-	batchCount := 0
+	batch := make([][]interface{}, 0, m.config.BatchSize)
 
 	for rows.Next() {
 		// Scan row
@@ -181,48 +274,54 @@ func (m *DataMigrator) migrateTable(ctx context.Context, table string) MigrateRe
 			result.Error = fmt.Errorf("failed to scan row: %w", err)
 			return result
 		}
+		batch = append(batch, values)
 
-		// Anonymize if configured
-		if m.config.Anonymize {
-			for i, col := range columns {
-				values[i] = m.anonymizer.AnonymizeValue(col, values[i])
-			}
+		if len(batch) < m.config.BatchSize {
+			continue
 		}
 
-		// Insert into local DB
-		if _, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, values...); err != nil {
+		// Anonymize and insert the batch, then commit it
+		if err := m.insertBatch(ctx, tx, stmt, program, table, columns, batch); err != nil {
 			tx.Rollback()
-			result.Error = fmt.Errorf("failed to insert row: %w", err)
+			result.Error = err
 			return result
 		}
+		if err := m.saveVault(tx); err != nil {
+			result.Error = err
+			return result
+		}
+		if err := tx.Commit(); err != nil {
+			result.Error = fmt.Errorf("failed to commit batch: %w", err)
+			return result
+		}
+		rowCount += int64(len(batch))
+		batch = batch[:0]
 
-		rowCount++
-		batchCount++
-
-		// Commit in batches
-		if batchCount >= m.config.BatchSize {
-			if err := tx.Commit(); err != nil {
-				result.Error = fmt.Errorf("failed to commit batch: %w", err)
-				return result
-			}
-
-			// Start new transaction
-			tx, err = m.localDB.BeginTx(ctx, nil)
-			if err != nil {
-				result.Error = fmt.Errorf("failed to begin new transaction: %w", err)
-				return result
-			}
-
-			batchCount = 0
-			logger.Debug("Committed batch", zap.String("table", table), zap.Int64("rows", rowCount))
+		// Start new transaction
+		tx, err = m.localDB.BeginTx(ctx, nil)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to begin new transaction: %w", err)
+			return result
 		}
+
+		logger.Debug("Committed batch", zap.String("table", table), zap.Int64("rows", rowCount))
 	}
 
 	// Commit remaining rows
+	if err := m.insertBatch(ctx, tx, stmt, program, table, columns, batch); err != nil {
+		tx.Rollback()
+		result.Error = err
+		return result
+	}
+	if err := m.saveVault(tx); err != nil {
+		result.Error = err
+		return result
+	}
 	if err := tx.Commit(); err != nil {
 		result.Error = fmt.Errorf("failed to commit final batch: %w", err)
 		return result
 	}
+	rowCount += int64(len(batch))
 
 	if err := rows.Err(); err != nil {
 		result.Error = fmt.Errorf("error during row iteration: %w", err)
@@ -230,10 +329,78 @@ func (m *DataMigrator) migrateTable(ctx context.Context, table string) MigrateRe
 	}
 
 	result.RowsMigrated = rowCount
+	result.Replacements = m.anonymizer.Replacements(table)
 	result.Success = true
 	return result
 }
 
+// saveVault writes the tokens of a batch to the vault before the batch
+// commits, so that no committed token is missing from it if the run aborts.
+// The transaction is rolled back when the vault can't be saved.
+func (m *DataMigrator) saveVault(tx *sql.Tx) error {
+	if _, err := m.anonymizer.SaveVault(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to save token vault: %w", err)
+	}
+	return nil
+}
+
+// checkTokenLengths fails when a tokenized column of a local table is too
+// short to hold its tokens
+func (m *DataMigrator) checkTokenLengths(ctx context.Context, table string, columns []string) error {
+	for _, column := range columns {
+		length := m.anonymizer.TokenLength(table, column)
+		if length == 0 {
+			continue
+		}
+
+		var max sql.NullInt64
+		query := `
+			SELECT character_maximum_length
+			FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name = $1 AND column_name = $2
+		`
+		if err := m.localDB.QueryRowContext(ctx, query, table, column).Scan(&max); err != nil {
+			return fmt.Errorf("failed to look up %s.%s: %w", table, column, err)
+		}
+		if max.Valid && max.Int64 < int64(length) {
+			return fmt.Errorf("%s.%s holds %d characters, its tokens have %d", table, column, max.Int64, length)
+		}
+	}
+	return nil
+}
+
+// insertBatch transforms and anonymizes a batch of rows if configured and inserts it
+func (m *DataMigrator) insertBatch(ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, program *transform.Program, table string, columns []string, batch [][]interface{}) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// Transforms run on the source values, so derived columns are anonymized too
+	if program != nil {
+		for _, values := range batch {
+			if err := program.Apply(values); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Anonymize if configured
+	if m.config.Anonymize {
+		if err := m.anonymizer.AnonymizeBatch(table, columns, batch); err != nil {
+			return fmt.Errorf("failed to anonymize batch: %w", err)
+		}
+	}
+
+	txStmt := tx.StmtContext(ctx, stmt)
+	for _, values := range batch {
+		if _, err := txStmt.ExecContext(ctx, values...); err != nil {
+			return fmt.Errorf("failed to insert row: %w", err)
+		}
+	}
+	return nil
+}
+
 // truncateTable truncates a table in the local database
 func (m *DataMigrator) truncateTable(ctx context.Context, table string) error {
 	query := fmt.Sprintf("TRUNCATE TABLE %s CASCADE", table)
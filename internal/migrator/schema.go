@@ -1,59 +1,129 @@
 package migrator
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"fmt"
+	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/thien/database-migration-tool/internal/config"
+	"github.com/thien/database-migration-tool/internal/introspect"
 	"github.com/thien/database-migration-tool/internal/logger"
+	"github.com/thien/database-migration-tool/internal/schemadiff"
 	"go.uber.org/zap"
 )
 
-// SchemaMigrator handles schema migration using Atlas
+// SchemaMigrator handles schema migration with the built-in diff engine or
+// Atlas
 type SchemaMigrator struct {
 	remoteDB  *sql.DB
 	localDB   *sql.DB
 	remoteCfg *config.DatabaseConfig
 	localCfg  *config.DatabaseConfig
+	schemaCfg *config.SchemaConfig
+	confirm   func(prompt string) bool
 }
 
 // NewSchemaMigrator creates a new schema migrator
-func NewSchemaMigrator(remoteDB, localDB *sql.DB, remoteCfg, localCfg *config.DatabaseConfig) *SchemaMigrator {
+func NewSchemaMigrator(remoteDB, localDB *sql.DB, remoteCfg, localCfg *config.DatabaseConfig, schemaCfg *config.SchemaConfig) *SchemaMigrator {
 	return &SchemaMigrator{
 		remoteDB:  remoteDB,
 		localDB:   localDB,
 		remoteCfg: remoteCfg,
 		localCfg:  localCfg,
+		schemaCfg: schemaCfg,
 	}
 }
 
+// SetConfirm sets how changes the policy wants confirmed are confirmed;
+// without it they are refused
+func (s *SchemaMigrator) SetConfirm(confirm func(prompt string) bool) {
+	s.confirm = confirm
+}
+
 // Migrate performs schema migration from remote to local
 func (s *SchemaMigrator) Migrate(ctx context.Context, dryRun bool) error {
-	logger.Info("Starting schema migration", zap.Bool("dry_run", dryRun))
+	logger.Info("Starting schema migration", zap.Bool("dry_run", dryRun), zap.String("engine", s.schemaCfg.Engine))
 
-	// Use Atlas CLI to diff and apply schema
-	if err := s.applyWithAtlas(ctx, dryRun); err != nil {
-		return fmt.Errorf("atlas migration failed: %w", err)
+	plan, err := s.plan(ctx)
+	if err != nil {
+		return err
 	}
+	if plan.Empty() {
+		logger.Info("Schema is up to date")
+		return nil
+	}
+	evaluation := schemadiff.NewPolicy(&s.schemaCfg.Policy.Local).Evaluate("local", plan)
 
-	logger.Info("Schema migration completed successfully")
+	if dryRun {
+		fmt.Print(plan.SQL())
+		if len(evaluation.Risks) > 0 {
+			fmt.Print(evaluation.Report())
+		}
+		return nil
+	}
+
+	if len(evaluation.Risks) > 0 {
+		fmt.Print(evaluation.Report())
+	}
+	if err := EnforcePolicy(evaluation, s.confirm); err != nil {
+		return err
+	}
+	if err := s.Apply(ctx, plan); err != nil {
+		return err
+	}
+
+	logger.Info("Schema migration completed successfully", zap.Int("changes", len(plan.Changes)))
 	return nil
 }
 
-// applyWithAtlas uses Atlas CLI via Docker to diff and apply schema
-func (s *SchemaMigrator) applyWithAtlas(ctx context.Context, dryRun bool) error {
-	// First, try to use Atlas via Docker
-	if err := s.applyWithAtlasDocker(ctx, dryRun); err != nil {
-		logger.Warn("Atlas Docker failed, falling back to pg_dump", zap.Error(err))
-		return s.applyWithPgDump(ctx)
+// EnforcePolicy logs the changes a policy warns about, asks to confirm those
+// it wants confirmed, and fails on those it blocks. Without confirm, changes
+// to confirm are refused.
+func EnforcePolicy(e *schemadiff.Evaluation, confirm func(prompt string) bool) error {
+	for _, r := range e.Risks {
+		if r.Action == schemadiff.Warn {
+			logger.Warn("Risky schema change",
+				zap.String("target", e.Target),
+				zap.String("change", r.Change.Kind),
+				zap.String("object", r.Change.Object),
+				zap.String("class", r.Class))
+		}
+	}
+
+	if n := e.Count(schemadiff.Block); n > 0 {
+		return fmt.Errorf("%d schema changes blocked by the %s policy", n, e.Target)
+	}
+	if n := e.Count(schemadiff.Confirm); n > 0 {
+		prompt := fmt.Sprintf("Apply %d risky schema changes to %s?", n, e.Target)
+		if confirm == nil || !confirm(prompt) {
+			return fmt.Errorf("%d schema changes to %s were not confirmed", n, e.Target)
+		}
 	}
 	return nil
 }
 
-// applyWithAtlasDocker uses Atlas CLI from Docker container
-func (s *SchemaMigrator) applyWithAtlasDocker(ctx context.Context, dryRun bool) error {
+// plan plans the changes bringing local up to date. With the atlas engine
+// they are the statements of an Atlas dry run, so that the statements
+// checked against the policy are the ones applied; when Atlas cannot run,
+// the built-in engine plans them.
+func (s *SchemaMigrator) plan(ctx context.Context) (*schemadiff.Plan, error) {
+	if s.schemaCfg.Engine == "atlas" {
+		plan, err := s.planWithAtlasDocker(ctx)
+		if err == nil {
+			return plan, nil
+		}
+		logger.Warn("Atlas Docker failed, falling back to the built-in engine", zap.Error(err))
+	}
+	return s.Plan(ctx)
+}
+
+// planWithAtlasDocker reads the statements Atlas would apply from a dry run
+// of Atlas CLI from Docker container
+func (s *SchemaMigrator) planWithAtlasDocker(ctx context.Context) (*schemadiff.Plan, error) {
 	localURL := s.convertDSNForDocker(s.localCfg)
 	remoteURL := s.convertDSNForDocker(s.remoteCfg)
 
@@ -64,58 +134,96 @@ func (s *SchemaMigrator) applyWithAtlasDocker(ctx context.Context, dryRun bool)
 		"schema", "apply",
 		"--url", localURL,
 		"--to", remoteURL,
-	}
-
-	if dryRun {
-		args = append(args, "--dry-run")
-	} else {
-		args = append(args, "--auto-approve")
+		"--dry-run",
 	}
 
 	logger.Debug("Running Atlas via Docker", zap.Strings("args", args))
 
 	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
 
 	logger.Info("Atlas output", zap.String("output", string(output)))
 
 	if err != nil {
-		return fmt.Errorf("atlas docker command failed: %w\nOutput: %s", err, string(output))
+		return nil, fmt.Errorf("atlas docker command failed: %w\nOutput: %s", err, stderr.String())
+	}
+	if strings.Contains(string(output), "Schema is synced") {
+		return &schemadiff.Plan{}, nil
 	}
 
-	return nil
+	plan := schemadiff.Parse(string(output))
+	logger.Info("Schema diff planned", zap.String("engine", "atlas"), zap.Int("changes", len(plan.Changes)))
+	return plan, nil
 }
 
-// applyWithPgDump uses pg_dump and psql as fallback
-func (s *SchemaMigrator) applyWithPgDump(ctx context.Context) error {
-	logger.Info("Using pg_dump/psql for schema migration")
-
-	// Use Docker to run pg_dump from remote and psql to local
-	dumpCmd := fmt.Sprintf(
-		"docker exec db_remote pg_dump -U %s -d %s --schema-only",
-		s.remoteCfg.User,
-		s.remoteCfg.Database,
-	)
-
-	restoreCmd := fmt.Sprintf(
-		"docker exec -i db_local psql -U %s -d %s",
-		s.localCfg.User,
-		s.localCfg.Database,
-	)
-
-	// Combine commands with pipe
-	fullCmd := fmt.Sprintf("%s | %s", dumpCmd, restoreCmd)
+// Plan compares the schemas of both databases and plans the changes
+// bringing local up to date with remote
+func (s *SchemaMigrator) Plan(ctx context.Context) (*schemadiff.Plan, error) {
+	remote, err := s.Inspect(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	local, err := s.Inspect(ctx, false)
+	if err != nil {
+		return nil, err
+	}
 
-	logger.Debug("Running pg_dump pipeline", zap.String("command", fullCmd))
+	plan := schemadiff.Diff(local, remote, s.schemaCfg.Renames)
+	for _, w := range plan.Warnings {
+		logger.Warn("Schema difference not planned", zap.String("reason", w))
+	}
+	logger.Info("Schema diff planned", zap.Int("changes", len(plan.Changes)))
+	return plan, nil
+}
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", fullCmd)
-	output, err := cmd.CombinedOutput()
+// Apply runs the statements of a plan on the local database. New enum
+// values cannot be used before the transaction adding them commits, so they
+// are added and committed first; the other changes then run in one
+// transaction.
+func (s *SchemaMigrator) Apply(ctx context.Context, plan *schemadiff.Plan) error {
+	var values, changes []schemadiff.Change
+	for _, c := range plan.Changes {
+		if c.Kind == schemadiff.AddEnumValue {
+			values = append(values, c)
+		} else {
+			changes = append(changes, c)
+		}
+	}
+	if len(values) > 0 {
+		if err := applyChanges(ctx, s.localDB, values); err != nil {
+			return err
+		}
+	}
+	if len(changes) > 0 {
+		if err := applyChanges(ctx, s.localDB, changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// applyChanges runs the statements of changes in one transaction
+func applyChanges(ctx context.Context, db *sql.DB, changes []schemadiff.Change) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("pg_dump/psql pipeline failed: %w\nOutput: %s", err, string(output))
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	logger.Info("Schema migrated successfully using pg_dump")
+	// Function bodies may refer to tables created later
+	if _, err := tx.ExecContext(ctx, "SET LOCAL check_function_bodies = false"); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if _, err := tx.ExecContext(ctx, c.SQL); err != nil {
+			return fmt.Errorf("failed to apply %s %s: %w", c.Kind, c.Object, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit schema changes: %w", err)
+	}
 	return nil
 }
 
@@ -138,10 +246,25 @@ func (s *SchemaMigrator) convertDSNForDocker(cfg *config.DatabaseConfig) string
 	)
 }
 
-// Diff generates a schema diff without applying
-func (s *SchemaMigrator) Diff(ctx context.Context) (string, error) {
+// Diff generates a schema diff without applying. With down, it returns the
+// statements undoing the diff instead.
+func (s *SchemaMigrator) Diff(ctx context.Context, down bool) (string, error) {
 	logger.Info("Generating schema diff")
 
+	if s.schemaCfg.Engine != "atlas" {
+		plan, err := s.Plan(ctx)
+		if err != nil {
+			return "", err
+		}
+		if down {
+			return plan.Down(), nil
+		}
+		return plan.SQL(), nil
+	}
+	if down {
+		return "", fmt.Errorf("reverse statements need the builtin schema engine")
+	}
+
 	// Use Atlas via Docker
 	localURL := s.convertDSNForDocker(s.localCfg)
 	remoteURL := s.convertDSNForDocker(s.remoteCfg)
@@ -165,62 +288,34 @@ func (s *SchemaMigrator) Diff(ctx context.Context) (string, error) {
 	return string(output), nil
 }
 
-// Inspect inspects the schema of a database
-func (s *SchemaMigrator) Inspect(ctx context.Context, remote bool) (string, error) {
-	var cfg *config.DatabaseConfig
-	var dbType string
-
+// Inspect reads the public schema of the remote or local database
+func (s *SchemaMigrator) Inspect(ctx context.Context, remote bool) (*introspect.Schema, error) {
+	db := s.localDB
+	dbType := "local"
 	if remote {
-		cfg = s.remoteCfg
+		db = s.remoteDB
 		dbType = "remote"
-	} else {
-		cfg = s.localCfg
-		dbType = "local"
 	}
 
 	logger.Info("Inspecting schema", zap.String("database", dbType))
 
-	// Use Atlas via Docker
-	dsn := s.convertDSNForDocker(cfg)
-
-	args := []string{
-		"run", "--rm",
-		"--network", "host",
-		"arigaio/atlas:latest",
-		"schema", "inspect",
-		"--url", dsn,
-	}
-
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
-
+	schema, err := introspect.Inspect(ctx, db, "public")
 	if err != nil {
-		return "", fmt.Errorf("failed to inspect schema: %w\nOutput: %s", err, string(output))
+		return nil, fmt.Errorf("failed to inspect schema: %w", err)
 	}
-
-	return string(output), nil
+	return schema, nil
 }
 
 // ExportSchema exports the remote schema to an SQL file
 func (s *SchemaMigrator) ExportSchema(ctx context.Context, outputFile string) error {
 	logger.Info("Exporting schema to file", zap.String("file", outputFile))
 
-	// Use pg_dump to export schema only
-	args := []string{
-		"-h", s.remoteCfg.Host,
-		"-p", fmt.Sprintf("%d", s.remoteCfg.Port),
-		"-U", s.remoteCfg.User,
-		"-d", s.remoteCfg.Database,
-		"--schema-only",
-		"-f", outputFile,
-	}
-
-	cmd := exec.CommandContext(ctx, "pg_dump", args...)
-	cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", s.remoteCfg.Password))
-
-	output, err := cmd.CombinedOutput()
+	schema, err := s.Inspect(ctx, true)
 	if err != nil {
-		return fmt.Errorf("pg_dump failed: %w\nOutput: %s", err, string(output))
+		return err
+	}
+	if err := os.WriteFile(outputFile, []byte(schema.SQL()), 0644); err != nil {
+		return fmt.Errorf("failed to write schema: %w", err)
 	}
 
 	logger.Info("Schema exported successfully", zap.String("file", outputFile))
@@ -0,0 +1,300 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/thien/database-migration-tool/internal/logger"
+	"github.com/thien/database-migration-tool/internal/transform"
+	"go.uber.org/zap"
+)
+
+// Anonymization modes
+const (
+	ModeClient = "client" // every row is anonymized in Go before insert
+	ModeServer = "server" // rows are copied in clear to a staging table and anonymized there
+)
+
+// stagingTable holds the clear rows of a table during server-side
+// anonymization
+const stagingTable = "anonymize_staging"
+
+// migrateTableServer copies a table in clear with COPY into a temporary
+// staging table, anonymizes it there and inserts the result into the local
+// table, all in one transaction so a failure never leaves raw PII behind.
+//
+// Temporary tables are not WAL-logged and their files are removed when the
+// transaction ends, so the clear values never reach the WAL or dead tuples
+// of the local table. They may linger in freed disk blocks and in temporary
+// files Postgres spills to, as with any data the server once held.
+func (m *DataMigrator) migrateTableServer(ctx context.Context, table string) MigrateResult {
+	result := MigrateResult{
+		Table:   table,
+		Success: false,
+	}
+
+	columns, err := m.getTableColumns(ctx, table)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to get columns: %w", err)
+		return result
+	}
+	result.Columns = columns
+
+	program, err := m.transformer.Compile(table, columns)
+	if err != nil {
+		result.Error = fmt.Errorf("invalid transform: %w", err)
+		return result
+	}
+
+	types, err := m.getColumnTypes(ctx, table)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to get column types: %w", err)
+		return result
+	}
+	m.anonymizer.SetColumnTypes(table, types)
+	if err := m.checkTokenLengths(ctx, table, columns); err != nil {
+		result.Error = err
+		return result
+	}
+	plan := m.anonymizer.PlanServer(table, columns, types)
+
+	tx, err := m.localDB.BeginTx(ctx, nil)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to begin transaction: %w", err)
+		return result
+	}
+	defer tx.Rollback()
+
+	if m.config.TruncateTables {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE %s CASCADE", quoteIdent(table))); err != nil {
+			result.Error = fmt.Errorf("failed to truncate table: %w", err)
+			return result
+		}
+	}
+
+	stage := fmt.Sprintf("CREATE TEMPORARY TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP",
+		quoteIdent(stagingTable), quoteIdent(table))
+	if _, err := tx.ExecContext(ctx, stage); err != nil {
+		result.Error = fmt.Errorf("failed to create staging table: %w", err)
+		return result
+	}
+
+	rowCount, err := m.copyTable(ctx, tx, table, stagingTable, columns, types, program)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	// Row-by-row strategies run first so they see the original row
+	if len(plan.RowColumns) > 0 {
+		if err := m.anonymizeRowsInPlace(ctx, tx, table, stagingTable, columns, plan.RowColumns); err != nil {
+			result.Error = err
+			return result
+		}
+	}
+
+	if update := plan.UpdateSQL(stagingTable); update != "" {
+		logger.Debug("Anonymizing staged rows", zap.String("table", table), zap.String("sql", update))
+		if _, err := tx.ExecContext(ctx, update); err != nil {
+			result.Error = fmt.Errorf("failed to anonymize table: %w", err)
+			return result
+		}
+	}
+
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quoteIdent(col)
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE SELECT %s FROM %s",
+		quoteIdent(table), strings.Join(quoted, ", "), strings.Join(quoted, ", "), quoteIdent(stagingTable))
+	if _, err := tx.ExecContext(ctx, insert); err != nil {
+		result.Error = fmt.Errorf("failed to insert anonymized rows: %w", err)
+		return result
+	}
+
+	if err := tx.Commit(); err != nil {
+		result.Error = fmt.Errorf("failed to commit table: %w", err)
+		return result
+	}
+
+	result.RowsMigrated = rowCount
+	result.Replacements = m.anonymizer.Replacements(table)
+	result.Success = true
+	return result
+}
+
+// copyTable streams the rows of a remote table into a local relation with
+// COPY
+func (m *DataMigrator) copyTable(ctx context.Context, tx *sql.Tx, table, target string, columns []string, types map[string]string, program *transform.Program) (int64, error) {
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quoteIdent(col)
+	}
+	rows, err := m.remoteDB.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), quoteIdent(table)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query remote table: %w", err)
+	}
+	defer rows.Close()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(target, columns...))
+	if err != nil {
+		return 0, fmt.Errorf("failed to start copy: %w", err)
+	}
+	defer stmt.Close()
+
+	var rowCount int64
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return 0, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if program != nil {
+			if err := program.Apply(values); err != nil {
+				return 0, err
+			}
+		}
+
+		// COPY encodes []byte as bytea, so text read as bytes (numeric,
+		// json, ...) is passed on as a string
+		for i, v := range values {
+			if b, ok := v.([]byte); ok && types[columns[i]] != "bytea" {
+				values[i] = string(b)
+			}
+		}
+
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return 0, fmt.Errorf("failed to copy row: %w", err)
+		}
+		rowCount++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	// Flush the buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, fmt.Errorf("failed to finish copy: %w", err)
+	}
+	return rowCount, nil
+}
+
+// anonymizeRowsInPlace anonymizes the rows of a table held in a relation,
+// for the columns whose strategy only exists in Go. A cursor keeps reading
+// the original row versions while they are updated.
+func (m *DataMigrator) anonymizeRowsInPlace(ctx context.Context, tx *sql.Tx, table, relation string, columns, only []string) error {
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quoteIdent(col)
+	}
+	declare := fmt.Sprintf("DECLARE anonymize_cursor NO SCROLL CURSOR FOR SELECT ctid::text, %s FROM %s",
+		strings.Join(quoted, ", "), quoteIdent(relation))
+	if _, err := tx.ExecContext(ctx, declare); err != nil {
+		return fmt.Errorf("failed to declare cursor: %w", err)
+	}
+
+	index := make(map[string]int, len(columns))
+	for i, col := range columns {
+		index[col] = i
+	}
+	sets := make([]string, len(only))
+	for i, col := range only {
+		sets[i] = fmt.Sprintf("%s = $%d", quoteIdent(col), i+1)
+	}
+	update, err := tx.PrepareContext(ctx, fmt.Sprintf("UPDATE %s SET %s WHERE ctid = $%d::tid",
+		quoteIdent(relation), strings.Join(sets, ", "), len(only)+1))
+	if err != nil {
+		return fmt.Errorf("failed to prepare update: %w", err)
+	}
+	defer update.Close()
+
+	fetch := fmt.Sprintf("FETCH %d FROM anonymize_cursor", m.config.BatchSize)
+	for {
+		ctids, batch, err := fetchBatch(ctx, tx, fetch, len(columns))
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := m.anonymizer.AnonymizeBatchColumns(table, columns, batch, only); err != nil {
+			return fmt.Errorf("failed to anonymize batch: %w", err)
+		}
+
+		for r, values := range batch {
+			args := make([]interface{}, 0, len(only)+1)
+			for _, col := range only {
+				args = append(args, values[index[col]])
+			}
+			args = append(args, ctids[r])
+			if _, err := update.ExecContext(ctx, args...); err != nil {
+				return fmt.Errorf("failed to update row: %w", err)
+			}
+		}
+	}
+}
+
+// fetchBatch reads the next rows of a cursor, whose first column is the ctid
+func fetchBatch(ctx context.Context, tx *sql.Tx, fetch string, width int) ([]string, [][]interface{}, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch rows: %w", err)
+	}
+	defer rows.Close()
+
+	var ctids []string
+	var batch [][]interface{}
+	for rows.Next() {
+		var ctid string
+		values := make([]interface{}, width)
+		ptrs := make([]interface{}, width+1)
+		ptrs[0] = &ctid
+		for i := range values {
+			ptrs[i+1] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ctids = append(ctids, ctid)
+		batch = append(batch, values)
+	}
+	return ctids, batch, rows.Err()
+}
+
+// getColumnTypes returns the data type of every column of a local table
+func (m *DataMigrator) getColumnTypes(ctx context.Context, table string) (map[string]string, error) {
+	query := `
+		SELECT column_name, data_type
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = $1
+	`
+
+	rows, err := m.localDB.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query column types: %w", err)
+	}
+	defer rows.Close()
+
+	types := make(map[string]string)
+	for rows.Next() {
+		var column, dataType string
+		if err := rows.Scan(&column, &dataType); err != nil {
+			return nil, fmt.Errorf("failed to scan column type: %w", err)
+		}
+		types[column] = dataType
+	}
+	return types, rows.Err()
+}
+
+// quoteIdent quotes a SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -2,6 +2,7 @@ package migrator
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"os"
 	"os/exec"
@@ -12,9 +13,14 @@ import (
 
 	"github.com/thien/database-migration-tool/internal/config"
 	"github.com/thien/database-migration-tool/internal/logger"
+	"github.com/thien/database-migration-tool/internal/schemadiff"
 	"go.uber.org/zap"
 )
 
+// revisionTables are where Atlas records the applied versions: in the
+// schema the URL is bound to, otherwise in a schema of its own
+var revisionTables = []string{"public.atlas_schema_revisions", "atlas_schema_revisions.atlas_schema_revisions"}
+
 // VersionManager handles versioned database migrations
 type VersionManager struct {
 	migrationsDir string
@@ -154,6 +160,71 @@ func (vm *VersionManager) ApplyMigrations(ctx context.Context, dbConfig *config.
 	return applied, nil
 }
 
+// Pending returns the migration files not yet applied to a database, in
+// order
+func (vm *VersionManager) Pending(ctx context.Context, db *sql.DB) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(vm.migrationsDir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	applied := make(map[string]bool)
+	for _, table := range revisionTables {
+		var exists bool
+		if err := db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to look up %s: %w", table, err)
+		}
+		if !exists {
+			continue
+		}
+		rows, err := db.QueryContext(ctx, "SELECT version FROM "+table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+		for rows.Next() {
+			var version string
+			if err := rows.Scan(&version); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			applied[version] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	var pending []string
+	for _, file := range files {
+		version, _, _ := strings.Cut(filepath.Base(file), "_")
+		if !applied[strings.TrimSuffix(version, ".sql")] {
+			pending = append(pending, file)
+		}
+	}
+	return pending, nil
+}
+
+// PendingPlan reads the pending migrations of a database into one plan, so
+// they can be checked against a schema change policy before they are applied
+func (vm *VersionManager) PendingPlan(ctx context.Context, db *sql.DB) (*schemadiff.Plan, error) {
+	files, err := vm.Pending(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	plan := &schemadiff.Plan{}
+	for _, file := range files {
+		script, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration: %w", err)
+		}
+		plan.Changes = append(plan.Changes, schemadiff.Parse(string(script)).Changes...)
+	}
+	logger.Info("Pending migrations read", zap.Int("files", len(files)), zap.Int("changes", len(plan.Changes)))
+	return plan, nil
+}
+
 // RollbackMigrations rolls back N migrations
 func (vm *VersionManager) RollbackMigrations(ctx context.Context, dbConfig *config.DatabaseConfig, steps int) error {
 	logger.Info("Rolling back migrations",
@@ -0,0 +1,43 @@
+// Package annotation holds the PII annotation of Ent schema fields. It only
+// depends on the Ent schema package, so the generated client stays free of
+// the code generator and the tool's configuration.
+package annotation
+
+import "entgo.io/ent/schema"
+
+// Name is the key of the annotation in the Ent graph
+const Name = "PII"
+
+// Annotation classifies an Ent field for anonymization:
+//
+//	field.String("email").Annotations(annotation.Strategy("email"))
+//	field.Time("created_at").Annotations(annotation.Clear())
+type Annotation struct {
+	Strategy string            `json:"strategy"`
+	Options  map[string]string `json:"options,omitempty"`
+}
+
+var _ schema.Annotation = Annotation{}
+
+// Name implements schema.Annotation
+func (Annotation) Name() string {
+	return Name
+}
+
+// Strategy marks a field as PII anonymized with the named strategy.
+// Options are given as key/value pairs.
+func Strategy(name string, options ...string) Annotation {
+	a := Annotation{Strategy: name}
+	if len(options) > 0 {
+		a.Options = make(map[string]string, len(options)/2)
+		for i := 0; i+1 < len(options); i += 2 {
+			a.Options[options[i]] = options[i+1]
+		}
+	}
+	return a
+}
+
+// Clear marks a field as reviewed and safe to copy as is
+func Clear() Annotation {
+	return Annotation{Strategy: "keep"}
+}
@@ -0,0 +1,118 @@
+package pii
+
+import (
+	"reflect"
+	"sort"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/entc/gen"
+	"github.com/thien/database-migration-tool/internal/config"
+	"github.com/thien/database-migration-tool/internal/pii/annotation"
+)
+
+// Field is a column of an Ent schema and its classification
+type Field struct {
+	Schema     string
+	Table      string
+	Column     string
+	Annotation *annotation.Annotation // nil when the field is not classified
+}
+
+// Load reads the fields of the given schemas. Edge (foreign key) fields are
+// skipped since they only hold references.
+func Load(schemas []ent.Interface) []Field {
+	var fields []Field
+	for _, s := range schemas {
+		name := typeName(s)
+		table := TableName(s)
+
+		edgeFields := make(map[string]bool)
+		for _, e := range s.Edges() {
+			if f := e.Descriptor().Field; f != "" {
+				edgeFields[f] = true
+			}
+		}
+
+		for _, f := range s.Fields() {
+			d := f.Descriptor()
+			if edgeFields[d.Name] {
+				continue
+			}
+
+			field := Field{Schema: name, Table: table, Column: d.StorageKey}
+			if field.Column == "" {
+				field.Column = d.Name
+			}
+			for _, ant := range d.Annotations {
+				switch a := ant.(type) {
+				case annotation.Annotation:
+					field.Annotation = &a
+				case *annotation.Annotation:
+					field.Annotation = a
+				}
+			}
+			fields = append(fields, field)
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].Table != fields[j].Table {
+			return fields[i].Table < fields[j].Table
+		}
+		return fields[i].Column < fields[j].Column
+	})
+	return fields
+}
+
+// Rules converts the classified fields to anonymization rules
+func Rules(fields []Field) []config.AnonymizeRule {
+	var rules []config.AnonymizeRule
+	for _, f := range fields {
+		if f.Annotation == nil {
+			continue
+		}
+		rules = append(rules, config.AnonymizeRule{
+			Table:    f.Table,
+			Column:   f.Column,
+			Strategy: f.Annotation.Strategy,
+			Options:  f.Annotation.Options,
+		})
+	}
+	return rules
+}
+
+// Unclassified returns the fields without a PII annotation
+func Unclassified(fields []Field) []Field {
+	var out []Field
+	for _, f := range fields {
+		if f.Annotation == nil {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// TableName returns the table of a schema: the entsql table annotation when
+// set, otherwise the snake_case plural of the type name as Ent generates it
+func TableName(s ent.Interface) string {
+	for _, ant := range s.Annotations() {
+		if a, ok := ant.(entsql.Annotation); ok && a.Table != "" {
+			return a.Table
+		} else if a, ok := ant.(*entsql.Annotation); ok && a.Table != "" {
+			return a.Table
+		}
+	}
+	snake := gen.Funcs["snake"].(func(string) string)
+	plural := gen.Funcs["plural"].(func(string) string)
+	return snake(plural(typeName(s)))
+}
+
+// typeName returns the name of a schema type, e.g. User
+func typeName(s ent.Interface) string {
+	t := reflect.TypeOf(s)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
@@ -0,0 +1,99 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+)
+
+// htmlPage is a standalone page with inline styles so it can be attached to
+// a ticket or archived as a CI artifact
+var htmlPage = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds": func(s float64) string { return seconds(s) + "s" },
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+.meta { color: #666; margin-bottom: 1.5em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ddd; padding: 4px 10px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+td.num { text-align: right; }
+.passed, .info { color: #1a7f37; }
+.failed, .error { color: #cf222e; font-weight: bold; }
+.warning, .tolerated { color: #9a6700; }
+.badge { display: inline-block; padding: 2px 10px; border-radius: 10px; color: #fff; }
+.badge.ok { background: #1a7f37; }
+.badge.ko { background: #cf222e; }
+code { font-size: 0.95em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">
+{{if .Summary.OK}}<span class="badge ok">passed</span>{{else}}<span class="badge ko">failed</span>{{end}}
+{{.Source}} &rarr; {{.Target}} &middot; {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}} &middot; {{seconds .Seconds}}
+</div>
+
+{{with .Schema}}
+<h2>Schema</h2>
+{{if .Error}}<p class="error">{{.Error}}</p>
+{{else if not .Differences}}<p class="passed">No differences.</p>
+{{else}}
+<table>
+<tr><th>Severity</th><th>Category</th><th>Object</th><th>Kind</th><th>Property</th><th>Source</th><th>Target</th></tr>
+{{range .Differences}}<tr><td class="{{.Severity}}">{{.Severity}}</td><td>{{.Category}}</td><td><code>{{.Object}}</code></td><td>{{.Kind}}</td><td>{{.Property}}</td><td><code>{{.Source}}</code></td><td><code>{{.Target}}</code></td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+
+<h2>Tables</h2>
+<table>
+<tr><th>Table</th><th>Status</th><th>Source rows</th><th>Target rows</th><th>Chunks</th><th>Differing rows</th><th>Time</th></tr>
+{{range .Tables}}<tr>
+<td><code>{{.Name}}</code>{{if .Error}}<br><span class="error">{{.Error}}</span>{{end}}{{with .Checksum}}{{range .Rows}}<br><small>{{.Kind}} {{.Key}}</small>{{end}}{{end}}{{with .Profile}}{{range .Mismatches}}<br><small>{{.Column}}.{{.Stat}}: {{.Source}} &rarr; {{.Target}}</small>{{end}}{{end}}</td>
+<td class="{{.Status}}">{{.Status}}</td>
+<td class="num">{{if .Estimated}}~{{end}}{{.SourceRows}}</td>
+<td class="num">{{if .Estimated}}~{{end}}{{.TargetRows}}</td>
+<td class="num">{{with .Checksum}}{{.MismatchedChunks}}/{{.Chunks}}{{if .Sampled}}<br><small>sampled, {{percent .Confidence}} confidence</small>{{end}}{{else}}-{{end}}</td>
+<td class="num">{{with .Checksum}}{{.RowsDiffering}}{{else}}-{{end}}</td>
+<td class="num">{{seconds .Seconds}}</td>
+</tr>
+{{end}}</table>
+
+{{with .Baseline}}
+<h2>Baseline</h2>
+<p class="meta">Compared with the run of {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}.</p>
+{{if not .Alerts}}<p class="passed">No table drifted beyond its limits.</p>
+{{else}}
+<table>
+<tr><th>Table</th><th>Alert</th><th>Baseline rows</th><th>Current rows</th><th>Change</th></tr>
+{{range .Alerts}}<tr><td><code>{{.Table}}</code></td><td class="failed">{{.Kind}}</td><td class="num">{{.Baseline}}</td><td class="num">{{.Current}}</td><td class="num">{{printf "%+.1f%%" .Change}}</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+
+<h2>Summary</h2>
+<ul>
+<li>Tables: {{.Summary.Tables}} ({{.Summary.Passed}} passed, {{.Summary.Tolerated}} tolerated, {{.Summary.Failed}} failed, {{.Summary.Errors}} errors)</li>
+<li>Rows: {{.Summary.SourceRows}} source / {{.Summary.TargetRows}} target</li>
+<li>Schema: {{.Summary.SchemaErrors}} errors, {{.Summary.SchemaWarnings}} warnings</li>
+{{if .Baseline}}<li>Drift alerts: {{.Summary.DriftAlerts}}</li>{{end}}
+</ul>
+</body>
+</html>
+`))
+
+// renderHTML renders the report as a standalone HTML page
+func renderHTML(r *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlPage.Execute(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,196 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// JUnit XML as understood by most CI servers
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// renderJUnit renders one test case per table, plus a schema suite with one
+// test case per error-level difference and a baseline suite with one test
+// case per drift alert
+func renderJUnit(r *Report) ([]byte, error) {
+	timestamp := r.GeneratedAt.Format("2006-01-02T15:04:05")
+
+	tables := junitSuite{Name: "tables", Timestamp: timestamp}
+	var tablesTime float64
+	for _, t := range r.Tables {
+		c := junitCase{Name: t.Name, Classname: "verify.tables", Time: seconds(t.Seconds)}
+		tablesTime += t.Seconds
+		switch t.Status {
+		case StatusFailed:
+			c.Failure = &junitMessage{Message: tableMessage(t), Type: "mismatch", Text: checksumDetail(t.Checksum) + profileDetail(t.Profile)}
+			tables.Failures++
+		case StatusError:
+			c.Error = &junitMessage{Message: t.Error, Type: "error"}
+			tables.Errors++
+		case StatusTolerated:
+			c.SystemOut = "within tolerance: " + tableMessage(t)
+		}
+		tables.Cases = append(tables.Cases, c)
+	}
+	tables.Tests = len(tables.Cases)
+	tables.Time = seconds(tablesTime)
+
+	suites := junitSuites{Name: r.Title, Time: seconds(r.Seconds)}
+	if r.Schema != nil {
+		schema := junitSuite{Name: "schema", Timestamp: timestamp, Time: seconds(r.Schema.Seconds)}
+		switch {
+		case r.Schema.Error != "":
+			schema.Cases = append(schema.Cases, junitCase{
+				Name: "schema", Classname: "verify.schema", Time: seconds(r.Schema.Seconds),
+				Error: &junitMessage{Message: r.Schema.Error, Type: "error"},
+			})
+			schema.Errors++
+		default:
+			var notes []string
+			for _, d := range r.Schema.Differences {
+				if d.Severity != "error" {
+					notes = append(notes, differenceLine(d))
+					continue
+				}
+				schema.Cases = append(schema.Cases, junitCase{
+					Name: d.Category + " " + d.Object, Classname: "verify.schema", Time: seconds(0),
+					Failure: &junitMessage{Message: differenceLine(d), Type: d.Kind},
+				})
+				schema.Failures++
+			}
+			if len(schema.Cases) == 0 {
+				schema.Cases = append(schema.Cases, junitCase{
+					Name: "schema", Classname: "verify.schema", Time: seconds(r.Schema.Seconds), SystemOut: strings.Join(notes, "\n"),
+				})
+			}
+		}
+		schema.Tests = len(schema.Cases)
+		suites.Suites = append(suites.Suites, schema)
+	}
+	suites.Suites = append(suites.Suites, tables)
+
+	if r.Baseline != nil {
+		baseline := junitSuite{Name: "baseline", Timestamp: timestamp, Time: seconds(0)}
+		for _, a := range r.Baseline.Alerts {
+			baseline.Cases = append(baseline.Cases, junitCase{
+				Name: a.Table, Classname: "verify.baseline", Time: seconds(0),
+				Failure: &junitMessage{
+					Message: fmt.Sprintf("%s: %d rows in the baseline, %d now (%+.1f%%)", a.Kind, a.Baseline, a.Current, a.Change),
+					Type:    a.Kind,
+				},
+			})
+			baseline.Failures++
+		}
+		if len(baseline.Cases) == 0 {
+			baseline.Cases = append(baseline.Cases, junitCase{Name: "baseline", Classname: "verify.baseline", Time: seconds(0)})
+		}
+		baseline.Tests = len(baseline.Cases)
+		suites.Suites = append(suites.Suites, baseline)
+	}
+
+	for _, s := range suites.Suites {
+		suites.Tests += s.Tests
+		suites.Failures += s.Failures
+		suites.Errors += s.Errors
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// seconds formats a duration in seconds for JUnit
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
+
+// tableMessage describes why a table failed
+func tableMessage(t Table) string {
+	if t.SourceRows != t.TargetRows {
+		if t.Estimated {
+			return fmt.Sprintf("estimated row count mismatch: ~%d source, ~%d target", t.SourceRows, t.TargetRows)
+		}
+		return fmt.Sprintf("row count mismatch: %d source, %d target", t.SourceRows, t.TargetRows)
+	}
+	if t.Profile != nil && len(t.Profile.Mismatches) > 0 {
+		return fmt.Sprintf("profile mismatch: %d column statistics", len(t.Profile.Mismatches))
+	}
+	if t.Checksum != nil {
+		return fmt.Sprintf("content mismatch: %d of %d chunks, %d rows", t.Checksum.MismatchedChunks, t.Checksum.Chunks, t.Checksum.RowsDiffering)
+	}
+	return "mismatch"
+}
+
+// checksumDetail lists the first differing rows
+func checksumDetail(c *Checksum) string {
+	if c == nil {
+		return ""
+	}
+	var lines []string
+	for _, row := range c.Rows {
+		lines = append(lines, row.Kind+" "+row.Key)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// profileDetail lists the differing column statistics
+func profileDetail(p *Profile) string {
+	if p == nil {
+		return ""
+	}
+	var lines []string
+	for _, m := range p.Mismatches {
+		lines = append(lines, profileLine(m))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// profileLine describes a differing column statistic on one line
+func profileLine(m ProfileMismatch) string {
+	return fmt.Sprintf("%s.%s: %s -> %s", m.Column, m.Stat, m.Source, m.Target)
+}
+
+// differenceLine describes a schema difference on one line
+func differenceLine(d SchemaDifference) string {
+	line := fmt.Sprintf("%s %s %s", d.Category, d.Object, d.Kind)
+	if d.Property != "" {
+		line += fmt.Sprintf(" %s: %q -> %q", d.Property, d.Source, d.Target)
+	}
+	return line
+}
@@ -0,0 +1,100 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// renderMarkdown renders the report as Markdown, e.g. for a ticket or a pull
+// request comment
+func renderMarkdown(r *Report) []byte {
+	var b strings.Builder
+
+	status := "✅ passed"
+	if !r.Summary.OK {
+		status = "❌ failed"
+	}
+	fmt.Fprintf(&b, "# %s\n\n", r.Title)
+	fmt.Fprintf(&b, "**Status:** %s  \n", status)
+	fmt.Fprintf(&b, "**Source:** %s  \n", r.Source)
+	fmt.Fprintf(&b, "**Target:** %s  \n", r.Target)
+	fmt.Fprintf(&b, "**Generated:** %s (%.1fs)\n\n", r.GeneratedAt.Format("2006-01-02 15:04:05 MST"), r.Seconds)
+
+	if r.Schema != nil {
+		b.WriteString("## Schema\n\n")
+		switch {
+		case r.Schema.Error != "":
+			fmt.Fprintf(&b, "Error: %s\n\n", mdEscape(r.Schema.Error))
+		case len(r.Schema.Differences) == 0:
+			b.WriteString("No differences.\n\n")
+		default:
+			b.WriteString("| Severity | Category | Object | Kind | Property | Source | Target |\n")
+			b.WriteString("|---|---|---|---|---|---|---|\n")
+			for _, d := range r.Schema.Differences {
+				fmt.Fprintf(&b, "| %s | %s | `%s` | %s | %s | %s | %s |\n",
+					d.Severity, d.Category, d.Object, d.Kind, d.Property, mdEscape(d.Source), mdEscape(d.Target))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("## Tables\n\n")
+	b.WriteString("| Table | Status | Source rows | Target rows | Chunks | Differing rows | Time |\n")
+	b.WriteString("|---|---|---:|---:|---:|---:|---:|\n")
+	for _, t := range r.Tables {
+		chunks, differing := "-", "-"
+		if t.Checksum != nil {
+			chunks = fmt.Sprintf("%d/%d", t.Checksum.MismatchedChunks, t.Checksum.Chunks)
+			differing = fmt.Sprintf("%d", t.Checksum.RowsDiffering)
+			if t.Checksum.Sampled {
+				chunks += fmt.Sprintf(" sampled, %.0f%% confidence", t.Checksum.Confidence*100)
+			}
+		}
+		source, target := fmt.Sprintf("%d", t.SourceRows), fmt.Sprintf("%d", t.TargetRows)
+		if t.Estimated {
+			source, target = "~"+source, "~"+target
+		}
+		name := fmt.Sprintf("`%s`", t.Name)
+		if t.Error != "" {
+			name += " — " + mdEscape(t.Error)
+		}
+		if t.Profile != nil {
+			for _, m := range t.Profile.Mismatches {
+				name += "<br>" + mdEscape(profileLine(m))
+			}
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %.2fs |\n",
+			name, t.Status, source, target, chunks, differing, t.Seconds)
+	}
+
+	if r.Baseline != nil {
+		b.WriteString("\n## Baseline\n\n")
+		fmt.Fprintf(&b, "Compared with the run of %s.\n\n", r.Baseline.GeneratedAt.Format("2006-01-02 15:04:05 MST"))
+		if len(r.Baseline.Alerts) == 0 {
+			b.WriteString("No table drifted beyond its limits.\n")
+		} else {
+			b.WriteString("| Table | Alert | Baseline rows | Current rows | Change |\n")
+			b.WriteString("|---|---|---:|---:|---:|\n")
+			for _, a := range r.Baseline.Alerts {
+				fmt.Fprintf(&b, "| `%s` | %s | %d | %d | %+.1f%% |\n", a.Table, a.Kind, a.Baseline, a.Current, a.Change)
+			}
+		}
+	}
+
+	s := r.Summary
+	b.WriteString("\n## Summary\n\n")
+	fmt.Fprintf(&b, "- Tables: %d (%d passed, %d tolerated, %d failed, %d errors)\n", s.Tables, s.Passed, s.Tolerated, s.Failed, s.Errors)
+	fmt.Fprintf(&b, "- Rows: %d source / %d target\n", s.SourceRows, s.TargetRows)
+	fmt.Fprintf(&b, "- Schema: %d errors, %d warnings\n", s.SchemaErrors, s.SchemaWarnings)
+	if r.Baseline != nil {
+		fmt.Fprintf(&b, "- Drift alerts: %d\n", s.DriftAlerts)
+	}
+
+	return []byte(b.String())
+}
+
+// mdEscape keeps a value on one table cell
+func mdEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
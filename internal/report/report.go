@@ -0,0 +1,186 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Table statuses
+const (
+	StatusPassed    = "passed"
+	StatusTolerated = "tolerated" // differs within the configured tolerance
+	StatusFailed    = "failed"
+	StatusError     = "error"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{"json", "junit", "html", "markdown"}
+
+// Report is the structured result of a verification run
+type Report struct {
+	Title       string         `json:"title"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Source      string         `json:"source"`
+	Target      string         `json:"target"`
+	Seconds     float64        `json:"seconds"`
+	Schema      *SchemaCheck   `json:"schema,omitempty"`
+	Tables      []Table        `json:"tables"`
+	Baseline    *BaselineCheck `json:"baseline,omitempty"`
+	Summary     Summary        `json:"summary"`
+}
+
+// SchemaCheck is the result of the structural schema comparison
+type SchemaCheck struct {
+	Seconds     float64            `json:"seconds"`
+	Error       string             `json:"error,omitempty"`
+	Differences []SchemaDifference `json:"differences"`
+}
+
+// SchemaDifference is one structural difference between the schemas
+type SchemaDifference struct {
+	Category string `json:"category"`
+	Object   string `json:"object"`
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	Property string `json:"property,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Target   string `json:"target,omitempty"`
+}
+
+// BaselineCheck compares the run with the last good run
+type BaselineCheck struct {
+	GeneratedAt time.Time    `json:"generated_at"` // of the baseline run
+	Alerts      []DriftAlert `json:"alerts"`
+}
+
+// DriftAlert is a table that drifted from the baseline beyond its limits
+type DriftAlert struct {
+	Table    string  `json:"table"`
+	Kind     string  `json:"kind"`
+	Baseline int64   `json:"baseline_rows"`
+	Current  int64   `json:"current_rows"`
+	Change   float64 `json:"change_percent"`
+}
+
+// Table is the verification result of one table
+type Table struct {
+	Name       string    `json:"name"`
+	Status     string    `json:"status"`
+	SourceRows int64     `json:"source_rows"`
+	TargetRows int64     `json:"target_rows"`
+	Estimated  bool      `json:"estimated,omitempty"` // row counts are planner estimates
+	Seconds    float64   `json:"seconds"`
+	Checksum   *Checksum `json:"checksum,omitempty"`
+	Profile    *Profile  `json:"profile,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Checksum is the content comparison of a table
+type Checksum struct {
+	Chunks           int      `json:"chunks"`
+	MismatchedChunks int      `json:"mismatched_chunks"`
+	RowsDiffering    int64    `json:"rows_differing"`
+	Excluded         []string `json:"excluded,omitempty"`
+	Rows             []Row    `json:"rows,omitempty"`
+	Sampled          bool     `json:"sampled,omitempty"`
+	Confidence       float64  `json:"confidence,omitempty"` // of fewer than 1% of chunks differing, when sampled
+}
+
+// Profile is the statistical comparison of a table
+type Profile struct {
+	Columns    int               `json:"columns"`
+	Excluded   []string          `json:"excluded,omitempty"`
+	Mismatches []ProfileMismatch `json:"mismatches,omitempty"`
+}
+
+// ProfileMismatch is a column statistic that differs beyond tolerance
+type ProfileMismatch struct {
+	Column string `json:"column"`
+	Stat   string `json:"stat"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// Row is a differing row by primary key
+type Row struct {
+	Key  string `json:"key"`
+	Kind string `json:"kind"`
+}
+
+// Summary totals a report
+type Summary struct {
+	OK             bool  `json:"ok"`
+	Tables         int   `json:"tables"`
+	Passed         int   `json:"passed"`
+	Tolerated      int   `json:"tolerated"`
+	Failed         int   `json:"failed"`
+	Errors         int   `json:"errors"`
+	SourceRows     int64 `json:"source_rows"`
+	TargetRows     int64 `json:"target_rows"`
+	SchemaErrors   int   `json:"schema_errors"`
+	SchemaWarnings int   `json:"schema_warnings"`
+	DriftAlerts    int   `json:"drift_alerts"`
+}
+
+// Summarize fills the summary from the tables and schema check. OK only
+// accounts for error-level schema differences; callers applying another
+// policy override it.
+func (r *Report) Summarize() {
+	s := Summary{Tables: len(r.Tables)}
+	for _, t := range r.Tables {
+		switch t.Status {
+		case StatusPassed:
+			s.Passed++
+		case StatusTolerated:
+			s.Tolerated++
+		case StatusFailed:
+			s.Failed++
+		default:
+			s.Errors++
+		}
+		s.SourceRows += t.SourceRows
+		s.TargetRows += t.TargetRows
+	}
+
+	if r.Schema != nil {
+		if r.Schema.Error != "" {
+			s.SchemaErrors++
+		}
+		for _, d := range r.Schema.Differences {
+			switch d.Severity {
+			case "error":
+				s.SchemaErrors++
+			case "warning":
+				s.SchemaWarnings++
+			}
+		}
+	}
+
+	if r.Baseline != nil {
+		s.DriftAlerts = len(r.Baseline.Alerts)
+	}
+
+	s.OK = s.Failed == 0 && s.Errors == 0 && s.SchemaErrors == 0 && s.DriftAlerts == 0
+	r.Summary = s
+}
+
+// Render renders a report in one of the supported formats
+func Render(r *Report, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case "junit":
+		return renderJUnit(r)
+	case "html":
+		return renderHTML(r)
+	case "markdown":
+		return renderMarkdown(r), nil
+	default:
+		return nil, fmt.Errorf("unknown report format %q (use json, junit, html or markdown)", format)
+	}
+}
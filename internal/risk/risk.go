@@ -0,0 +1,427 @@
+package risk
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/thien/database-migration-tool/internal/config"
+	"github.com/thien/database-migration-tool/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Analyzer measures the re-identification risk of an anonymized database
+type Analyzer struct {
+	db  *sql.DB
+	cfg *config.RiskConfig
+}
+
+// NewAnalyzer creates a new risk analyzer for the given (local) database
+func NewAnalyzer(db *sql.DB, cfg *config.RiskConfig) *Analyzer {
+	return &Analyzer{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// TableRisk holds the k-anonymity (and l-diversity) figures for a table
+type TableRisk struct {
+	Table            string
+	QuasiIdentifiers []string
+	Rows             int64
+	Classes          int64 // number of equivalence classes
+	K                int64 // smallest equivalence class
+	L                int64 // fewest distinct sensitive values in a class, 0 when not configured
+	ViolatingRows    int64 // rows in classes smaller than the target k
+	Generalization   map[string]int
+	SuppressedRows   int64
+	Passed           bool
+	Error            error
+}
+
+// qiColumn is a quasi-identifier with its generalization hierarchy
+type qiColumn struct {
+	name     string
+	kind     columnKind
+	nullable bool
+	level    int
+}
+
+type columnKind int
+
+const (
+	kindText columnKind = iota
+	kindNumeric
+	kindTime
+	kindOther // not generalized; its rows in small classes are suppressed
+)
+
+// integerTypes are the data types generalized as numbers besides numeric,
+// real and double precision
+var integerTypes = map[string]bool{"smallint": true, "integer": true, "bigint": true}
+
+// numericWidths are the bucket widths of the numeric generalization levels
+var numericWidths = []int{1, 5, 10, 50, 100, 500, 1000, 5000, 10000}
+
+// timeUnits are the date_trunc units of the date generalization levels
+var timeUnits = []string{"", "month", "year", "decade"}
+
+// maxTextLevel is the maximum number of trailing characters masked in text
+const maxTextLevel = 5
+
+func (c *qiColumn) maxLevel() int {
+	switch c.kind {
+	case kindNumeric:
+		return len(numericWidths) - 1
+	case kindTime:
+		return len(timeUnits) - 1
+	case kindText:
+		return maxTextLevel
+	default:
+		return 0
+	}
+}
+
+// expr returns the SQL expression of the column at its current level
+func (c *qiColumn) expr() string {
+	col := quoteIdent(c.name)
+	if c.level == 0 {
+		return col
+	}
+
+	switch c.kind {
+	case kindNumeric:
+		w := numericWidths[c.level]
+		return fmt.Sprintf("(floor(%s / %d) * %d)", col, w, w)
+	case kindTime:
+		return fmt.Sprintf("date_trunc('%s', %s)", timeUnits[c.level], col)
+	case kindText:
+		return fmt.Sprintf(
+			"(CASE WHEN length(%[1]s) > %[2]d THEN left(%[1]s, length(%[1]s) - %[2]d) || repeat('*', %[2]d) ELSE repeat('*', length(%[1]s)) END)",
+			col, c.level)
+	default:
+		return col
+	}
+}
+
+// Analyze computes the risk figures for every configured table
+func (a *Analyzer) Analyze(ctx context.Context) []TableRisk {
+	var results []TableRisk
+	for _, t := range a.cfg.Tables {
+		result := a.analyzeTable(ctx, t, false)
+		a.logResult(result)
+		results = append(results, result)
+	}
+	return results
+}
+
+// Enforce generalizes and, as a last resort, suppresses quasi-identifiers in
+// place until every configured table reaches the target k
+func (a *Analyzer) Enforce(ctx context.Context) []TableRisk {
+	var results []TableRisk
+	for _, t := range a.cfg.Tables {
+		result := a.analyzeTable(ctx, t, true)
+		a.logResult(result)
+		results = append(results, result)
+	}
+	return results
+}
+
+func (a *Analyzer) logResult(r TableRisk) {
+	switch {
+	case r.Error != nil:
+		logger.Error("Risk analysis failed", zap.String("table", r.Table), zap.Error(r.Error))
+	case !r.Passed:
+		logger.Warn("Table is below the k-anonymity threshold",
+			zap.String("table", r.Table),
+			zap.Int64("k", r.K),
+			zap.Int("target_k", a.cfg.K),
+			zap.Int64("violating_rows", r.ViolatingRows))
+	default:
+		logger.Info("Table meets the k-anonymity threshold",
+			zap.String("table", r.Table),
+			zap.Int64("k", r.K))
+	}
+}
+
+// analyzeTable measures a table and, when enforce is set, transforms it
+func (a *Analyzer) analyzeTable(ctx context.Context, t config.RiskTableConfig, enforce bool) TableRisk {
+	result := TableRisk{
+		Table:            t.Table,
+		QuasiIdentifiers: t.QuasiIdentifiers,
+		Generalization:   make(map[string]int),
+	}
+
+	columns, err := a.describeColumns(ctx, t.Table, t.QuasiIdentifiers)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	if err := a.measure(ctx, t, columns, &result); err != nil {
+		result.Error = err
+		return result
+	}
+
+	if enforce && result.ViolatingRows > 0 {
+		if err := a.reachK(ctx, t, columns, &result); err != nil {
+			result.Error = err
+			return result
+		}
+	}
+
+	result.Passed = result.K >= int64(a.cfg.K) && (a.cfg.L == 0 || t.Sensitive == "" || result.L >= int64(a.cfg.L))
+	return result
+}
+
+// reachK raises generalization levels greedily (the column with the most
+// distinct values first) until the rows in too-small classes are within the
+// suppression budget, then applies the transformation and suppresses the rest
+func (a *Analyzer) reachK(ctx context.Context, t config.RiskTableConfig, columns []*qiColumn, result *TableRisk) error {
+	budget := int64(a.cfg.MaxSuppression * float64(result.Rows))
+
+	for result.ViolatingRows > budget {
+		next, err := a.mostDistinct(ctx, t.Table, columns)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			break // every column is fully generalized
+		}
+		next.level++
+
+		if err := a.measure(ctx, t, columns, result); err != nil {
+			return err
+		}
+		logger.Debug("Generalized quasi-identifier",
+			zap.String("table", t.Table),
+			zap.String("column", next.name),
+			zap.Int("level", next.level),
+			zap.Int64("violating_rows", result.ViolatingRows))
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Apply generalization
+	var sets []string
+	for _, c := range columns {
+		result.Generalization[c.name] = c.level
+		if c.level > 0 {
+			sets = append(sets, fmt.Sprintf("%s = %s", quoteIdent(c.name), c.expr()))
+		}
+	}
+	if len(sets) > 0 {
+		query := fmt.Sprintf("UPDATE %s SET %s", quoteIdent(t.Table), strings.Join(sets, ", "))
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to generalize %s: %w", t.Table, err)
+		}
+	}
+
+	// Suppress the rows still in classes smaller than k
+	var names []string
+	for _, c := range columns {
+		names = append(names, quoteIdent(c.name))
+	}
+	violating := fmt.Sprintf(
+		"SELECT ctid FROM (SELECT ctid, count(*) OVER (PARTITION BY %s) AS class_size FROM %s) s WHERE class_size < $1",
+		strings.Join(names, ", "), quoteIdent(t.Table))
+
+	var query string
+	if a.cfg.Suppression == "delete" {
+		query = fmt.Sprintf("DELETE FROM %s WHERE ctid IN (%s)", quoteIdent(t.Table), violating)
+	} else {
+		var nulls []string
+		for _, c := range columns {
+			if !c.nullable {
+				return fmt.Errorf("can't suppress %s.%s: column is NOT NULL (use suppression: delete)", t.Table, c.name)
+			}
+			nulls = append(nulls, fmt.Sprintf("%s = NULL", quoteIdent(c.name)))
+		}
+		query = fmt.Sprintf("UPDATE %s SET %s WHERE ctid IN (%s)", quoteIdent(t.Table), strings.Join(nulls, ", "), violating)
+	}
+
+	res, err := tx.ExecContext(ctx, query, a.cfg.K)
+	if err != nil {
+		return fmt.Errorf("failed to suppress rows of %s: %w", t.Table, err)
+	}
+	result.SuppressedRows, _ = res.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	// Measure the transformed table
+	for _, c := range columns {
+		c.level = 0
+	}
+	return a.measure(ctx, t, columns, result)
+}
+
+// measure computes the equivalence classes under the current generalization
+func (a *Analyzer) measure(ctx context.Context, t config.RiskTableConfig, columns []*qiColumn, result *TableRisk) error {
+	var exprs []string
+	for i, c := range columns {
+		exprs = append(exprs, fmt.Sprintf("%s AS q%d", c.expr(), i))
+	}
+
+	diversity := "0"
+	if t.Sensitive != "" {
+		diversity = fmt.Sprintf("count(DISTINCT %s)", quoteIdent(t.Sensitive))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT coalesce(sum(class_size), 0), count(*), coalesce(min(class_size), 0),
+			coalesce(min(diversity), 0), coalesce(sum(class_size) FILTER (WHERE class_size < $1), 0)
+		FROM (
+			SELECT count(*) AS class_size, %s AS diversity
+			FROM (SELECT %s, %s FROM %s) g
+			GROUP BY %s
+		) classes`,
+		diversity,
+		strings.Join(exprs, ", "), sensitiveColumn(t.Sensitive), quoteIdent(t.Table),
+		groupColumns(len(columns)),
+	)
+
+	err := a.db.QueryRowContext(ctx, query, a.cfg.K).Scan(
+		&result.Rows, &result.Classes, &result.K, &result.L, &result.ViolatingRows)
+	if err != nil {
+		return fmt.Errorf("failed to compute equivalence classes of %s: %w", t.Table, err)
+	}
+	return nil
+}
+
+// mostDistinct returns the column that can still be generalized and has the
+// most distinct values at its current level
+func (a *Analyzer) mostDistinct(ctx context.Context, table string, columns []*qiColumn) (*qiColumn, error) {
+	var best *qiColumn
+	var bestCount int64 = -1
+
+	for _, c := range columns {
+		if c.level >= c.maxLevel() {
+			continue
+		}
+		var count int64
+		query := fmt.Sprintf("SELECT count(DISTINCT %s) FROM %s", c.expr(), quoteIdent(table))
+		if err := a.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count distinct values of %s.%s: %w", table, c.name, err)
+		}
+		if count > bestCount {
+			best, bestCount = c, count
+		}
+	}
+	return best, nil
+}
+
+// describeColumns looks up the type of every quasi-identifier
+func (a *Analyzer) describeColumns(ctx context.Context, table string, names []string) ([]*qiColumn, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no quasi-identifiers configured for %s", table)
+	}
+
+	var columns []*qiColumn
+	for _, name := range names {
+		var dataType, nullable string
+		err := a.db.QueryRowContext(ctx, `
+			SELECT data_type, is_nullable
+			FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name = $1 AND column_name = $2
+		`, table, name).Scan(&dataType, &nullable)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("column %s.%s does not exist", table, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe %s.%s: %w", table, name, err)
+		}
+
+		columns = append(columns, &qiColumn{
+			name:     name,
+			kind:     kindOf(dataType),
+			nullable: nullable == "YES",
+		})
+	}
+	return columns, nil
+}
+
+func kindOf(dataType string) columnKind {
+	switch {
+	case integerTypes[dataType], dataType == "numeric", dataType == "real", dataType == "double precision":
+		return kindNumeric
+	case dataType == "date", strings.HasPrefix(dataType, "timestamp"):
+		return kindTime
+	case strings.Contains(dataType, "char"), dataType == "text":
+		return kindText
+	default:
+		return kindOther
+	}
+}
+
+func sensitiveColumn(name string) string {
+	if name == "" {
+		return "NULL AS s"
+	}
+	return quoteIdent(name)
+}
+
+func groupColumns(n int) string {
+	cols := make([]string, n)
+	for i := range cols {
+		cols[i] = fmt.Sprintf("q%d", i)
+	}
+	return strings.Join(cols, ", ")
+}
+
+// GenerateReport generates a summary report
+func (a *Analyzer) GenerateReport(results []TableRisk) string {
+	var report string
+	report += "\n========================================\n"
+	report += "     RE-IDENTIFICATION RISK REPORT      \n"
+	report += "========================================\n\n"
+
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Error != nil:
+			failed++
+			report += fmt.Sprintf("✗ %s - ERROR: %s\n", r.Table, r.Error.Error())
+			continue
+		case r.Passed:
+			report += fmt.Sprintf("✓ %s - k=%d", r.Table, r.K)
+		default:
+			failed++
+			report += fmt.Sprintf("✗ %s - k=%d (target %d, %d rows at risk)", r.Table, r.K, a.cfg.K, r.ViolatingRows)
+		}
+		if r.L > 0 {
+			report += fmt.Sprintf(", l=%d", r.L)
+		}
+		report += fmt.Sprintf(", %d classes over %d rows\n", r.Classes, r.Rows)
+		report += fmt.Sprintf("    quasi-identifiers: %s\n", strings.Join(r.QuasiIdentifiers, ", "))
+		if r.SuppressedRows > 0 || len(r.Generalization) > 0 {
+			var levels []string
+			for _, q := range r.QuasiIdentifiers {
+				levels = append(levels, fmt.Sprintf("%s=%d", q, r.Generalization[q]))
+			}
+			report += fmt.Sprintf("    generalization: %s, suppressed rows: %d\n", strings.Join(levels, ", "), r.SuppressedRows)
+		}
+	}
+
+	report += "\n========================================\n"
+	report += fmt.Sprintf("Target k:        %d\n", a.cfg.K)
+	if a.cfg.L > 0 {
+		report += fmt.Sprintf("Target l:        %d\n", a.cfg.L)
+	}
+	report += fmt.Sprintf("Tables:          %d\n", len(results))
+	report += fmt.Sprintf("Below threshold: %d\n", failed)
+	report += "========================================\n"
+
+	return report
+}
+
+// quoteIdent quotes a SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,40 @@
+package risk
+
+import "testing"
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		dataType string
+		want     columnKind
+	}{
+		{"integer", kindNumeric},
+		{"bigint", kindNumeric},
+		{"smallint", kindNumeric},
+		{"numeric", kindNumeric},
+		{"double precision", kindNumeric},
+		{"interval", kindOther},
+		{"point", kindOther},
+		{"int4range", kindOther},
+		{"date", kindTime},
+		{"timestamp with time zone", kindTime},
+		{"character varying", kindText},
+		{"text", kindText},
+		{"boolean", kindOther},
+	}
+	for _, tt := range tests {
+		if got := kindOf(tt.dataType); got != tt.want {
+			t.Errorf("kindOf(%q) = %d, want %d", tt.dataType, got, tt.want)
+		}
+	}
+}
+
+func TestOtherColumnsAreNotGeneralized(t *testing.T) {
+	c := &qiColumn{name: "area", kind: kindOther}
+	if c.maxLevel() != 0 {
+		t.Errorf("maxLevel() = %d, want 0", c.maxLevel())
+	}
+	c.level = 1
+	if got := c.expr(); got != `"area"` {
+		t.Errorf("expr() = %s, want the column unchanged", got)
+	}
+}
@@ -0,0 +1,120 @@
+// Package schemadiff compares two schema models and plans the DDL turning
+// one into the other, in dependency order and with reverse statements
+package schemadiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of change
+const (
+	CreateExtension    = "create_extension"
+	UpdateExtension    = "update_extension"
+	DropExtension      = "drop_extension"
+	CreateEnum         = "create_enum"
+	AddEnumValue       = "add_enum_value"
+	DropEnum           = "drop_enum"
+	CreateSequence     = "create_sequence"
+	AlterSequence      = "alter_sequence"
+	OwnSequence        = "own_sequence"
+	DropSequence       = "drop_sequence"
+	CreateFunction     = "create_function"
+	ReplaceFunction    = "replace_function"
+	DropFunction       = "drop_function"
+	CreateTable        = "create_table"
+	RenameTable        = "rename_table"
+	DropTable          = "drop_table"
+	AddColumn          = "add_column"
+	RenameColumn       = "rename_column"
+	AlterColumnType    = "alter_column_type"
+	AlterColumnDefault = "alter_column_default"
+	AlterIdentity      = "alter_identity"
+	SetNotNull         = "set_not_null"
+	DropNotNull        = "drop_not_null"
+	RecreateColumn     = "recreate_column" // a generated column whose expression changed
+	ReplaceColumn      = "replace_column"  // a regular column that becomes generated
+	DropColumn         = "drop_column"
+	AddConstraint      = "add_constraint"
+	DropConstraint     = "drop_constraint"
+	CreateIndex        = "create_index"
+	DropIndex          = "drop_index"
+	CreateView         = "create_view"
+	DropView           = "drop_view"
+	CreateTrigger      = "create_trigger"
+	AlterTrigger       = "alter_trigger" // enabled or disabled
+	DropTrigger        = "drop_trigger"
+	Comment            = "comment"
+)
+
+// Change is one step of a plan
+type Change struct {
+	Kind    string `json:"kind"`
+	Object  string `json:"object"` // e.g. users, users.email, users.users_email_key
+	SQL     string `json:"sql"`
+	Reverse string `json:"reverse,omitempty"` // undoes the change, empty when it cannot be undone
+}
+
+// Plan is the ordered list of changes turning one schema into another
+type Plan struct {
+	Changes []Change `json:"changes"`
+
+	// Warnings are differences the plan cannot express, e.g. a removed
+	// enum value
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Empty reports whether the plan has nothing to change
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// SQL returns the statements of the plan as a script
+func (p *Plan) SQL() string {
+	var b strings.Builder
+	for _, w := range p.Warnings {
+		fmt.Fprintf(&b, "-- warning: %s\n", w)
+	}
+	if len(p.Warnings) > 0 {
+		b.WriteString("\n")
+	}
+	if p.hasFunctions() {
+		// Function bodies may refer to tables created later
+		b.WriteString("SET check_function_bodies = false;\n\n")
+	}
+	for _, c := range p.Changes {
+		b.WriteString(c.SQL + "\n\n")
+	}
+	if p.Empty() {
+		b.WriteString("-- no changes\n")
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// Down returns the script undoing the plan, the reverse statements in
+// reverse order. Changes that cannot be undone are listed as comments.
+func (p *Plan) Down() string {
+	var b strings.Builder
+	if p.hasFunctions() {
+		b.WriteString("SET check_function_bodies = false;\n\n")
+	}
+	for i := len(p.Changes) - 1; i >= 0; i-- {
+		c := p.Changes[i]
+		if c.Reverse == "" {
+			fmt.Fprintf(&b, "-- irreversible %s %s\n\n", c.Kind, c.Object)
+			continue
+		}
+		b.WriteString(c.Reverse + "\n\n")
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+func (p *Plan) hasFunctions() bool {
+	for _, c := range p.Changes {
+		switch c.Kind {
+		case CreateFunction, ReplaceFunction, DropFunction:
+			return true
+		}
+	}
+	return false
+}
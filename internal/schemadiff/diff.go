@@ -0,0 +1,781 @@
+package schemadiff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/thien/database-migration-tool/internal/config"
+	"github.com/thien/database-migration-tool/internal/introspect"
+)
+
+// Phases of a plan, in the order they run: dependents are dropped before
+// what they depend on, and created after it
+const (
+	dropTriggers = iota
+	dropViews
+	dropForeignKeys
+	dropConstraints
+	createTypes
+	createSequences
+	createFunctions
+	renames
+	createTables
+	alterColumns
+	ownSequences
+	dropTables
+	dropFunctions
+	dropSequences
+	dropEnums
+	dropExtensions
+	addConstraints
+	addForeignKeys
+	createViews
+	createTriggers
+	comments
+	phases
+)
+
+var quote = introspect.QuoteIdent
+
+// differ accumulates the changes of a plan by phase
+type differ struct {
+	from, to *introspect.Schema
+	steps    [phases][]Change
+	warnings []string
+
+	tableRenames  map[string]string            // to table -> from table
+	renamedTables map[string]string            // from table -> to table
+	columnRenames map[string]map[string]string // to table -> to column -> from column
+
+	// altered are the tables whose columns are dropped, renamed or retyped,
+	// by both names; views reading them are recreated
+	altered map[string]bool
+}
+
+// Diff plans the changes turning the schema from into the schema to.
+// Renames are hints: without them a renamed table or column is dropped and
+// created anew.
+func Diff(from, to *introspect.Schema, hints []config.SchemaRename) *Plan {
+	d := &differ{
+		from:          from,
+		to:            to,
+		tableRenames:  make(map[string]string),
+		renamedTables: make(map[string]string),
+		columnRenames: make(map[string]map[string]string),
+		altered:       make(map[string]bool),
+	}
+	d.hints(hints)
+	d.extensions()
+	d.enums()
+	d.sequences()
+	d.functions()
+	d.tables()
+	d.views()
+
+	plan := &Plan{Changes: []Change{}, Warnings: d.warnings}
+	for _, steps := range d.steps {
+		plan.Changes = append(plan.Changes, steps...)
+	}
+	return plan
+}
+
+func (d *differ) add(phase int, c Change) {
+	d.steps[phase] = append(d.steps[phase], c)
+}
+
+func (d *differ) warn(format string, args ...interface{}) {
+	d.warnings = append(d.warnings, fmt.Sprintf(format, args...))
+}
+
+// hints checks the rename hints against both schemas; table renames are
+// read first since column hints name the table as on their side
+func (d *differ) hints(hints []config.SchemaRename) {
+	for _, h := range hints {
+		if strings.Contains(h.From, ".") {
+			continue
+		}
+		if d.from.Table(h.From) == nil || d.to.Table(h.To) == nil || d.from.Table(h.To) != nil || d.to.Table(h.From) != nil {
+			d.warn("rename of table %s to %s ignored: %s must exist only before and %s only after", h.From, h.To, h.From, h.To)
+			continue
+		}
+		d.tableRenames[h.To] = h.From
+		d.renamedTables[h.From] = h.To
+	}
+
+	for _, h := range hints {
+		fromTable, fromColumn, ok := strings.Cut(h.From, ".")
+		if !ok {
+			continue
+		}
+		toTable, toColumn, _ := strings.Cut(h.To, ".")
+		old, t := d.from.Table(fromTable), d.to.Table(toTable)
+		if old == nil || t == nil || d.source(t) != old {
+			d.warn("rename of column %s to %s ignored: the tables do not match", h.From, h.To)
+			continue
+		}
+		if old.Column(fromColumn) == nil || t.Column(toColumn) == nil || old.Column(toColumn) != nil || t.Column(fromColumn) != nil {
+			d.warn("rename of column %s to %s ignored: %s must exist only before and %s only after", h.From, h.To, fromColumn, toColumn)
+			continue
+		}
+		if d.columnRenames[toTable] == nil {
+			d.columnRenames[toTable] = make(map[string]string)
+		}
+		d.columnRenames[toTable][toColumn] = fromColumn
+	}
+}
+
+// source returns the table of the old schema a table of the new schema
+// comes from, nil for a new table
+func (d *differ) source(t *introspect.Table) *introspect.Table {
+	if name, ok := d.tableRenames[t.Name]; ok {
+		return d.from.Table(name)
+	}
+	if _, ok := d.renamedTables[t.Name]; ok {
+		return nil
+	}
+	return d.from.Table(t.Name)
+}
+
+// target returns the table of the new schema a table of the old schema
+// becomes, nil for a dropped table
+func (d *differ) target(old *introspect.Table) *introspect.Table {
+	if name, ok := d.renamedTables[old.Name]; ok {
+		return d.to.Table(name)
+	}
+	if _, ok := d.tableRenames[old.Name]; ok {
+		return nil
+	}
+	return d.to.Table(old.Name)
+}
+
+// sourceColumn returns the name a column of the new table has in the old one
+func (d *differ) sourceColumn(table, column string) string {
+	if name, ok := d.columnRenames[table][column]; ok {
+		return name
+	}
+	return column
+}
+
+// renamedTable maps a table of the old schema to its new name
+func (d *differ) renamedTable(table string) string {
+	if name, ok := d.renamedTables[table]; ok {
+		return name
+	}
+	return table
+}
+
+// renamedColumn maps a table.column of the old schema to its new name
+func (d *differ) renamedColumn(ref string) string {
+	table, column, ok := strings.Cut(ref, ".")
+	if !ok {
+		return ref
+	}
+	table = d.renamedTable(table)
+	for to, from := range d.columnRenames[table] {
+		if from == column {
+			column = to
+		}
+	}
+	return table + "." + column
+}
+
+func (d *differ) extensions() {
+	old := make(map[string]introspect.Extension, len(d.from.Extensions))
+	for _, e := range d.from.Extensions {
+		old[e.Name] = e
+	}
+	current := make(map[string]bool, len(d.to.Extensions))
+	for _, e := range d.to.Extensions {
+		current[e.Name] = true
+		oe, ok := old[e.Name]
+		switch {
+		case !ok:
+			d.add(createTypes, Change{Kind: CreateExtension, Object: e.Name, SQL: introspect.CreateExtension(e),
+				Reverse: fmt.Sprintf("DROP EXTENSION %s;", quote(e.Name))})
+		case oe.Version != e.Version:
+			d.add(createTypes, Change{Kind: UpdateExtension, Object: e.Name,
+				SQL:     fmt.Sprintf("ALTER EXTENSION %s UPDATE TO %s;", quote(e.Name), introspect.QuoteLiteral(e.Version)),
+				Reverse: fmt.Sprintf("ALTER EXTENSION %s UPDATE TO %s;", quote(e.Name), introspect.QuoteLiteral(oe.Version))})
+		}
+	}
+	for _, e := range d.from.Extensions {
+		if !current[e.Name] {
+			d.add(dropExtensions, Change{Kind: DropExtension, Object: e.Name,
+				SQL: fmt.Sprintf("DROP EXTENSION %s;", quote(e.Name)), Reverse: introspect.CreateExtension(e)})
+		}
+	}
+}
+
+func (d *differ) enums() {
+	old := make(map[string]introspect.Enum, len(d.from.Enums))
+	for _, e := range d.from.Enums {
+		old[e.Name] = e
+	}
+	current := make(map[string]bool, len(d.to.Enums))
+	for _, e := range d.to.Enums {
+		current[e.Name] = true
+		oe, ok := old[e.Name]
+		if !ok {
+			d.add(createTypes, Change{Kind: CreateEnum, Object: e.Name, SQL: introspect.CreateEnum(e),
+				Reverse: fmt.Sprintf("DROP TYPE %s;", quote(e.Name))})
+			continue
+		}
+		d.enumValues(oe, e)
+	}
+	for _, e := range d.from.Enums {
+		if !current[e.Name] {
+			d.add(dropEnums, Change{Kind: DropEnum, Object: e.Name,
+				SQL: fmt.Sprintf("DROP TYPE %s;", quote(e.Name)), Reverse: introspect.CreateEnum(e)})
+		}
+	}
+}
+
+// enumValues adds the new values of an enum in place, in order, each next to
+// a value that exists by then: after the previous value, or before the first
+// old value for leading values. Values cannot be removed from an enum, so
+// neither the removal nor the addition is undone.
+func (d *differ) enumValues(old, e introspect.Enum) {
+	known := make(map[string]bool, len(old.Values))
+	for _, v := range old.Values {
+		known[v] = true
+	}
+	first := ""
+	for _, v := range e.Values {
+		if known[v] {
+			first = v
+			break
+		}
+	}
+
+	for i, v := range e.Values {
+		if known[v] {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TYPE %s ADD VALUE %s", quote(e.Name), introspect.QuoteLiteral(v))
+		switch {
+		case i > 0:
+			stmt += " AFTER " + introspect.QuoteLiteral(e.Values[i-1])
+		case first != "":
+			stmt += " BEFORE " + introspect.QuoteLiteral(first)
+		}
+		d.add(createTypes, Change{Kind: AddEnumValue, Object: e.Name + "." + v, SQL: stmt + ";"})
+	}
+
+	current := make(map[string]bool, len(e.Values))
+	for _, v := range e.Values {
+		current[v] = true
+	}
+	for _, v := range old.Values {
+		if !current[v] {
+			d.warn("enum %s: value %s cannot be removed in place", e.Name, introspect.QuoteLiteral(v))
+		}
+	}
+}
+
+// sequences diffs the sequences not backing identity columns, which follow
+// their column
+func (d *differ) sequences() {
+	old := make(map[string]introspect.Sequence, len(d.from.Sequences))
+	for _, q := range d.from.Sequences {
+		if !q.Identity {
+			old[q.Name] = q
+		}
+	}
+	current := make(map[string]bool, len(d.to.Sequences))
+	for _, q := range d.to.Sequences {
+		if q.Identity {
+			continue
+		}
+		current[q.Name] = true
+		oq, ok := old[q.Name]
+		switch {
+		case !ok:
+			d.add(createSequences, Change{Kind: CreateSequence, Object: q.Name, SQL: introspect.CreateSequence(q),
+				Reverse: fmt.Sprintf("DROP SEQUENCE %s;", quote(q.Name))})
+		case oq.Type != q.Type || oq.Start != q.Start || oq.Increment != q.Increment || oq.Min != q.Min ||
+			oq.Max != q.Max || oq.Cache != q.Cache || oq.Cycle != q.Cycle:
+			d.add(createSequences, Change{Kind: AlterSequence, Object: q.Name, SQL: alterSequence(q), Reverse: alterSequence(oq)})
+		}
+
+		if d.renamedColumn(oq.OwnedBy) == q.OwnedBy {
+			continue
+		}
+		prev := q
+		prev.OwnedBy = d.renamedColumn(oq.OwnedBy)
+		d.add(ownSequences, Change{Kind: OwnSequence, Object: q.Name, SQL: ownSequence(q), Reverse: ownSequence(prev)})
+	}
+	for _, q := range d.from.Sequences {
+		if !q.Identity && !current[q.Name] {
+			// Owned sequences are dropped along with their column
+			d.add(dropSequences, Change{Kind: DropSequence, Object: q.Name,
+				SQL: fmt.Sprintf("DROP SEQUENCE IF EXISTS %s;", quote(q.Name)), Reverse: introspect.CreateSequence(q)})
+		}
+	}
+}
+
+func alterSequence(q introspect.Sequence) string {
+	stmt := fmt.Sprintf("ALTER SEQUENCE %s AS %s START WITH %d INCREMENT BY %d MINVALUE %d MAXVALUE %d CACHE %d",
+		quote(q.Name), q.Type, q.Start, q.Increment, q.Min, q.Max, q.Cache)
+	if q.Cycle {
+		return stmt + " CYCLE;"
+	}
+	return stmt + " NO CYCLE;"
+}
+
+func ownSequence(q introspect.Sequence) string {
+	if q.OwnedBy == "" {
+		return fmt.Sprintf("ALTER SEQUENCE %s OWNED BY NONE;", quote(q.Name))
+	}
+	return introspect.OwnSequence(q)
+}
+
+func (d *differ) functions() {
+	old := make(map[string]introspect.Function, len(d.from.Functions))
+	for _, f := range d.from.Functions {
+		old[f.Signature()] = f
+	}
+	current := make(map[string]bool, len(d.to.Functions))
+	for _, f := range d.to.Functions {
+		current[f.Signature()] = true
+		of, ok := old[f.Signature()]
+		switch {
+		case !ok:
+			d.add(createFunctions, Change{Kind: CreateFunction, Object: f.Signature(), SQL: introspect.CreateFunction(f), Reverse: dropFunction(f)})
+		case of.Definition == f.Definition:
+		case of.Result != f.Result || of.Procedure != f.Procedure:
+			// CREATE OR REPLACE cannot change the result type
+			d.add(createFunctions, Change{Kind: DropFunction, Object: of.Signature(), SQL: dropFunction(of), Reverse: introspect.CreateFunction(of)})
+			d.add(createFunctions, Change{Kind: CreateFunction, Object: f.Signature(), SQL: introspect.CreateFunction(f), Reverse: dropFunction(f)})
+		default:
+			d.add(createFunctions, Change{Kind: ReplaceFunction, Object: f.Signature(), SQL: introspect.CreateFunction(f), Reverse: introspect.CreateFunction(of)})
+		}
+	}
+	for _, f := range d.from.Functions {
+		if !current[f.Signature()] {
+			d.add(dropFunctions, Change{Kind: DropFunction, Object: f.Signature(), SQL: dropFunction(f), Reverse: introspect.CreateFunction(f)})
+		}
+	}
+}
+
+func dropFunction(f introspect.Function) string {
+	kind := "FUNCTION"
+	if f.Procedure {
+		kind = "PROCEDURE"
+	}
+	return fmt.Sprintf("DROP %s %s(%s);", kind, quote(f.Name), f.Arguments)
+}
+
+// tables diffs the tables; partitions follow their parents in both schemas,
+// so they are created after and dropped before them
+func (d *differ) tables() {
+	for _, t := range d.to.Tables {
+		old := d.source(t)
+		if old == nil {
+			d.createTable(t)
+			continue
+		}
+		if old.Name != t.Name {
+			d.add(renames, Change{Kind: RenameTable, Object: t.Name,
+				SQL:     fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", quote(old.Name), quote(t.Name)),
+				Reverse: fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", quote(t.Name), quote(old.Name))})
+		}
+		d.alterTable(old, t)
+	}
+
+	for i := len(d.from.Tables) - 1; i >= 0; i-- {
+		if old := d.from.Tables[i]; d.target(old) == nil {
+			d.dropTable(old)
+		}
+	}
+}
+
+func (d *differ) createTable(t *introspect.Table) {
+	d.add(createTables, Change{Kind: CreateTable, Object: t.Name, SQL: introspect.CreateTable(t),
+		Reverse: fmt.Sprintf("DROP TABLE %s;", quote(t.Name))})
+
+	empty := &introspect.Table{Name: t.Name}
+	d.constraints(empty, t)
+	d.indexes(empty, t)
+	d.triggers(empty, t)
+	d.comments(empty, t)
+}
+
+// dropTable drops a table with its constraints, indexes and triggers. Its
+// foreign keys are dropped first, so that undoing the plan can recreate the
+// tables before them. The rows are lost either way.
+func (d *differ) dropTable(old *introspect.Table) {
+	reverse := []string{introspect.CreateTable(old)}
+	for _, c := range old.Constraints {
+		if c.Kind == introspect.ForeignKey {
+			d.dropConstraint(old.Name, c)
+			continue
+		}
+		reverse = append(reverse, introspect.AddConstraint(old.Name, c))
+	}
+	for _, i := range old.Indexes {
+		if !i.Constraint {
+			reverse = append(reverse, introspect.CreateIndex(i))
+		}
+	}
+	for _, tr := range old.Triggers {
+		reverse = append(reverse, introspect.CreateTrigger(old.Name, tr))
+	}
+	if old.Comment != "" {
+		reverse = append(reverse, introspect.CommentOnTable(old))
+	}
+	for _, c := range old.Columns {
+		if c.Comment != "" {
+			reverse = append(reverse, introspect.CommentOnColumn(old.Name, c))
+		}
+	}
+
+	d.add(dropTables, Change{Kind: DropTable, Object: old.Name,
+		SQL: fmt.Sprintf("DROP TABLE %s;", quote(old.Name)), Reverse: strings.Join(reverse, "\n")})
+}
+
+func (d *differ) alterTable(old, t *introspect.Table) {
+	if old.PartitionKey != t.PartitionKey || d.renamedTable(old.PartitionOf) != t.PartitionOf || old.PartitionBound != t.PartitionBound {
+		d.warn("table %s: partitioning changed, the table must be recreated", t.Name)
+	}
+
+	d.columns(old, t)
+	d.constraints(old, t)
+	d.indexes(old, t)
+	d.triggers(old, t)
+	d.comments(old, t)
+}
+
+// columns diffs the columns of a table. Statements name the table as in
+// the new schema since they run after it is renamed.
+func (d *differ) columns(old, t *introspect.Table) {
+	table := quote(t.Name)
+	renamed := make(map[string]bool)
+	altered := false
+
+	for _, c := range t.Columns {
+		name := d.sourceColumn(t.Name, c.Name)
+		oc := old.Column(name)
+		object := t.Name + "." + c.Name
+		if oc == nil {
+			d.add(alterColumns, Change{Kind: AddColumn, Object: object,
+				SQL:     fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, introspect.ColumnDefinition(c)),
+				Reverse: fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, quote(c.Name))})
+			continue
+		}
+		if name != c.Name {
+			renamed[name] = true
+			altered = true
+			d.add(renames, Change{Kind: RenameColumn, Object: object,
+				SQL:     fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", table, quote(name), quote(c.Name)),
+				Reverse: fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", table, quote(c.Name), quote(name))})
+		}
+		prev := *oc
+		prev.Name = c.Name
+		if d.alterColumn(t.Name, prev, c) {
+			altered = true
+		}
+	}
+
+	for _, oc := range old.Columns {
+		if renamed[oc.Name] || t.Column(oc.Name) != nil {
+			continue
+		}
+		altered = true
+		d.add(alterColumns, Change{Kind: DropColumn, Object: t.Name + "." + oc.Name,
+			SQL:     fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, quote(oc.Name)),
+			Reverse: fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, introspect.ColumnDefinition(oc))})
+	}
+
+	if altered {
+		d.altered[old.Name] = true
+		d.altered[t.Name] = true
+	}
+}
+
+// alterColumn diffs a column in place and reports whether it was retyped or
+// recreated. The identity is dropped before and added after the default,
+// which is set after the type so that it casts to the new one.
+func (d *differ) alterColumn(table string, old, c introspect.Column) bool {
+	alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s", quote(table), quote(c.Name))
+	object := table + "." + c.Name
+	retyped := false
+
+	switch {
+	case old.Generated == c.Generated:
+	case c.Generated == "":
+		d.add(alterColumns, Change{Kind: AlterColumnDefault, Object: object,
+			SQL: alter + " DROP EXPRESSION;", Reverse: recreateColumn(table, old)})
+	case old.Generated == "":
+		// A regular column cannot become generated in place; recreating it
+		// drops the values it stores
+		d.add(alterColumns, Change{Kind: ReplaceColumn, Object: object,
+			SQL: recreateColumn(table, c), Reverse: recreateColumn(table, old)})
+		return true
+	default:
+		// Generation expressions cannot be altered; the values are derived,
+		// so recreating the column loses nothing
+		d.add(alterColumns, Change{Kind: RecreateColumn, Object: object,
+			SQL: recreateColumn(table, c), Reverse: recreateColumn(table, old)})
+		return true
+	}
+
+	if old.Identity != "" && c.Identity == "" {
+		d.add(alterColumns, Change{Kind: AlterIdentity, Object: object,
+			SQL: alter + " DROP IDENTITY;", Reverse: alter + " ADD GENERATED " + old.Identity + " AS IDENTITY;"})
+	}
+
+	if old.Type != c.Type || old.Collation != c.Collation {
+		retyped = true
+		d.add(alterColumns, Change{Kind: AlterColumnType, Object: object,
+			SQL:     fmt.Sprintf("%s TYPE %s USING %s::%s;", alter, columnType(c), quote(c.Name), c.Type),
+			Reverse: fmt.Sprintf("%s TYPE %s USING %s::%s;", alter, columnType(old), quote(c.Name), old.Type)})
+	}
+
+	if old.Default != c.Default {
+		d.add(alterColumns, Change{Kind: AlterColumnDefault, Object: object,
+			SQL: setDefault(alter, c.Default), Reverse: setDefault(alter, old.Default)})
+	}
+
+	switch {
+	case old.Identity == c.Identity || c.Identity == "":
+	case old.Identity == "":
+		d.add(alterColumns, Change{Kind: AlterIdentity, Object: object,
+			SQL: alter + " ADD GENERATED " + c.Identity + " AS IDENTITY;", Reverse: alter + " DROP IDENTITY;"})
+	default:
+		d.add(alterColumns, Change{Kind: AlterIdentity, Object: object,
+			SQL: alter + " SET GENERATED " + c.Identity + ";", Reverse: alter + " SET GENERATED " + old.Identity + ";"})
+	}
+
+	switch {
+	case old.NotNull == c.NotNull:
+	case c.NotNull:
+		d.add(alterColumns, Change{Kind: SetNotNull, Object: object, SQL: alter + " SET NOT NULL;", Reverse: alter + " DROP NOT NULL;"})
+	default:
+		d.add(alterColumns, Change{Kind: DropNotNull, Object: object, SQL: alter + " DROP NOT NULL;", Reverse: alter + " SET NOT NULL;"})
+	}
+
+	return retyped
+}
+
+func columnType(c introspect.Column) string {
+	if c.Collation != "" {
+		return c.Type + " COLLATE " + c.Collation
+	}
+	return c.Type
+}
+
+func setDefault(alter, def string) string {
+	if def == "" {
+		return alter + " DROP DEFAULT;"
+	}
+	return alter + " SET DEFAULT " + def + ";"
+}
+
+func recreateColumn(table string, c introspect.Column) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s, ADD COLUMN %s;", quote(table), quote(c.Name), introspect.ColumnDefinition(c))
+}
+
+// constraints diffs the constraints of a table; a changed constraint is
+// dropped and added again
+func (d *differ) constraints(old, t *introspect.Table) {
+	prev := make(map[string]introspect.Constraint, len(old.Constraints))
+	for _, c := range old.Constraints {
+		prev[c.Name] = c
+	}
+	current := make(map[string]bool, len(t.Constraints))
+	for _, c := range t.Constraints {
+		current[c.Name] = true
+		oc, ok := prev[c.Name]
+		if ok && oc.Definition == c.Definition {
+			continue
+		}
+		if ok {
+			d.dropConstraint(old.Name, oc)
+		}
+		phase := addConstraints
+		if c.Kind == introspect.ForeignKey {
+			phase = addForeignKeys
+		}
+		d.add(phase, Change{Kind: AddConstraint, Object: t.Name + "." + c.Name, SQL: introspect.AddConstraint(t.Name, c),
+			Reverse: fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", quote(t.Name), quote(c.Name))})
+	}
+	for _, c := range old.Constraints {
+		if !current[c.Name] {
+			d.dropConstraint(old.Name, c)
+		}
+	}
+}
+
+func (d *differ) dropConstraint(table string, c introspect.Constraint) {
+	phase := dropConstraints
+	if c.Kind == introspect.ForeignKey {
+		phase = dropForeignKeys
+	}
+	d.add(phase, Change{Kind: DropConstraint, Object: table + "." + c.Name,
+		SQL: fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", quote(table), quote(c.Name)), Reverse: introspect.AddConstraint(table, c)})
+}
+
+// indexes diffs the indexes of a table not created by a constraint
+func (d *differ) indexes(old, t *introspect.Table) {
+	prev := make(map[string]introspect.Index, len(old.Indexes))
+	for _, i := range old.Indexes {
+		if !i.Constraint {
+			prev[i.Name] = i
+		}
+	}
+	current := make(map[string]bool, len(t.Indexes))
+	for _, i := range t.Indexes {
+		if i.Constraint {
+			continue
+		}
+		current[i.Name] = true
+		oi, ok := prev[i.Name]
+		if ok && oi.Definition == i.Definition {
+			continue
+		}
+		if ok {
+			d.dropIndex(old.Name, oi)
+		}
+		d.add(addConstraints, Change{Kind: CreateIndex, Object: t.Name + "." + i.Name, SQL: introspect.CreateIndex(i),
+			Reverse: fmt.Sprintf("DROP INDEX %s;", quote(i.Name))})
+	}
+	for _, i := range old.Indexes {
+		if !i.Constraint && !current[i.Name] {
+			d.dropIndex(old.Name, i)
+		}
+	}
+}
+
+func (d *differ) dropIndex(table string, i introspect.Index) {
+	d.add(dropConstraints, Change{Kind: DropIndex, Object: table + "." + i.Name,
+		SQL: fmt.Sprintf("DROP INDEX %s;", quote(i.Name)), Reverse: introspect.CreateIndex(i)})
+}
+
+// triggers diffs the triggers of a table; a changed trigger is dropped and
+// created again, one only enabled or disabled is altered
+func (d *differ) triggers(old, t *introspect.Table) {
+	prev := make(map[string]introspect.Trigger, len(old.Triggers))
+	for _, tr := range old.Triggers {
+		prev[tr.Name] = tr
+	}
+	current := make(map[string]bool, len(t.Triggers))
+	for _, tr := range t.Triggers {
+		current[tr.Name] = true
+		otr, ok := prev[tr.Name]
+		switch {
+		case ok && otr.Definition == tr.Definition && otr.Enabled == tr.Enabled:
+		case ok && otr.Definition == tr.Definition:
+			d.add(createTriggers, Change{Kind: AlterTrigger, Object: t.Name + "." + tr.Name,
+				SQL: enableTrigger(t.Name, tr), Reverse: enableTrigger(t.Name, otr)})
+		default:
+			if ok {
+				d.dropTrigger(old.Name, otr)
+			}
+			d.add(createTriggers, Change{Kind: CreateTrigger, Object: t.Name + "." + tr.Name, SQL: introspect.CreateTrigger(t.Name, tr),
+				Reverse: fmt.Sprintf("DROP TRIGGER %s ON %s;", quote(tr.Name), quote(t.Name))})
+		}
+	}
+	for _, tr := range old.Triggers {
+		if !current[tr.Name] {
+			d.dropTrigger(old.Name, tr)
+		}
+	}
+}
+
+func (d *differ) dropTrigger(table string, tr introspect.Trigger) {
+	d.add(dropTriggers, Change{Kind: DropTrigger, Object: table + "." + tr.Name,
+		SQL: fmt.Sprintf("DROP TRIGGER %s ON %s;", quote(tr.Name), quote(table)), Reverse: introspect.CreateTrigger(table, tr)})
+}
+
+func enableTrigger(table string, tr introspect.Trigger) string {
+	action := "ENABLE"
+	if !tr.Enabled {
+		action = "DISABLE"
+	}
+	return fmt.Sprintf("ALTER TABLE %s %s TRIGGER %s;", quote(table), action, quote(tr.Name))
+}
+
+// comments diffs the comments of a table and its columns
+func (d *differ) comments(old, t *introspect.Table) {
+	target := "TABLE " + quote(t.Name)
+	if old.Comment != t.Comment {
+		d.add(comments, Change{Kind: Comment, Object: t.Name,
+			SQL: commentOn(target, t.Comment), Reverse: commentOn(target, old.Comment)})
+	}
+	for _, c := range t.Columns {
+		prev := ""
+		if oc := old.Column(d.sourceColumn(t.Name, c.Name)); oc != nil {
+			prev = oc.Comment
+		}
+		if prev != c.Comment {
+			target := "COLUMN " + quote(t.Name) + "." + quote(c.Name)
+			d.add(comments, Change{Kind: Comment, Object: t.Name + "." + c.Name,
+				SQL: commentOn(target, c.Comment), Reverse: commentOn(target, prev)})
+		}
+	}
+}
+
+func commentOn(target, comment string) string {
+	if comment == "" {
+		return fmt.Sprintf("COMMENT ON %s IS NULL;", target)
+	}
+	return fmt.Sprintf("COMMENT ON %s IS %s;", target, introspect.QuoteLiteral(comment))
+}
+
+// views diffs the views. Views cannot be altered in place: a changed view is
+// dropped and created again, as are the views reading an altered table or a
+// recreated view, found by name in their definition.
+func (d *differ) views() {
+	current := make(map[string]introspect.View, len(d.to.Views))
+	for _, v := range d.to.Views {
+		current[v.Name] = v
+	}
+	prev := make(map[string]bool, len(d.from.Views))
+	recreate := make(map[string]bool)
+	for _, v := range d.from.Views {
+		prev[v.Name] = true
+		nv, ok := current[v.Name]
+		if !ok || nv.Definition != v.Definition || nv.Materialized != v.Materialized || mentions(v.Definition, d.altered) {
+			recreate[v.Name] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, v := range d.from.Views {
+			if !recreate[v.Name] && mentions(v.Definition, recreate) {
+				recreate[v.Name] = true
+				changed = true
+			}
+		}
+	}
+
+	// Views are listed in creation order, dependents are dropped first
+	for i := len(d.from.Views) - 1; i >= 0; i-- {
+		if v := d.from.Views[i]; recreate[v.Name] {
+			d.add(dropViews, Change{Kind: DropView, Object: v.Name, SQL: dropView(v), Reverse: introspect.CreateView(v)})
+		}
+	}
+	for _, v := range d.to.Views {
+		if !prev[v.Name] || recreate[v.Name] {
+			d.add(createViews, Change{Kind: CreateView, Object: v.Name, SQL: introspect.CreateView(v), Reverse: dropView(v)})
+		}
+	}
+}
+
+func dropView(v introspect.View) string {
+	if v.Materialized {
+		return fmt.Sprintf("DROP MATERIALIZED VIEW %s;", quote(v.Name))
+	}
+	return fmt.Sprintf("DROP VIEW %s;", quote(v.Name))
+}
+
+// mentions reports whether a definition names one of the objects
+func mentions(definition string, names map[string]bool) bool {
+	for name := range names {
+		if regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`).MatchString(definition) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package schemadiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thien/database-migration-tool/internal/config"
+	"github.com/thien/database-migration-tool/internal/introspect"
+)
+
+func TestDiff(t *testing.T) {
+	users := func(columns ...introspect.Column) *introspect.Table {
+		return &introspect.Table{Name: "users", Columns: columns}
+	}
+	id := introspect.Column{Name: "id", Type: "bigint", NotNull: true}
+	usersView := introspect.View{Name: "active_users", Definition: "SELECT id, name FROM users"}
+
+	tests := []struct {
+		name     string
+		from, to *introspect.Schema
+		hints    []config.SchemaRename
+		want     []string // kind object, in plan order
+		warnings []string
+	}{
+		{
+			name: "no changes",
+			from: &introspect.Schema{Tables: []*introspect.Table{users(id)}},
+			to:   &introspect.Schema{Tables: []*introspect.Table{users(id)}},
+		},
+		{
+			name: "new table is created before its constraints, foreign keys and triggers",
+			from: &introspect.Schema{Tables: []*introspect.Table{users(id)}},
+			to: &introspect.Schema{Tables: []*introspect.Table{users(id), {
+				Name:    "orders",
+				Comment: "customer orders",
+				Columns: []introspect.Column{id, {Name: "user_id", Type: "bigint"}},
+				Constraints: []introspect.Constraint{
+					{Name: "orders_user_fk", Kind: introspect.ForeignKey, Definition: "FOREIGN KEY (user_id) REFERENCES users(id)"},
+					{Name: "orders_pkey", Kind: introspect.PrimaryKey, Definition: "PRIMARY KEY (id)"},
+				},
+				Indexes:  []introspect.Index{{Name: "orders_user_idx", Definition: "CREATE INDEX orders_user_idx ON orders (user_id)"}},
+				Triggers: []introspect.Trigger{{Name: "orders_touch", Definition: "CREATE TRIGGER orders_touch ...", Enabled: true}},
+			}}},
+			want: []string{
+				"create_table orders",
+				"add_constraint orders.orders_pkey",
+				"create_index orders.orders_user_idx",
+				"add_constraint orders.orders_user_fk",
+				"create_trigger orders.orders_touch",
+				"comment orders",
+			},
+		},
+		{
+			name: "dropped table loses its foreign keys and views first",
+			from: &introspect.Schema{
+				Tables: []*introspect.Table{users(id), {
+					Name:        "orders",
+					Columns:     []introspect.Column{id, {Name: "user_id", Type: "bigint"}},
+					Constraints: []introspect.Constraint{{Name: "orders_user_fk", Kind: introspect.ForeignKey, Definition: "FOREIGN KEY (user_id) REFERENCES users(id)"}},
+				}},
+				Views: []introspect.View{{Name: "order_totals", Definition: "SELECT count(*) FROM orders"}},
+			},
+			to: &introspect.Schema{Tables: []*introspect.Table{users(id)}},
+			want: []string{
+				"drop_view order_totals",
+				"drop_constraint orders.orders_user_fk",
+				"drop_table orders",
+			},
+		},
+		{
+			name: "column changes recreate the views reading the table",
+			from: &introspect.Schema{
+				Tables: []*introspect.Table{users(id,
+					introspect.Column{Name: "name", Type: "text"},
+					introspect.Column{Name: "nick", Type: "text"},
+					introspect.Column{Name: "age", Type: "integer"})},
+				Views: []introspect.View{usersView},
+			},
+			to: &introspect.Schema{
+				Tables: []*introspect.Table{users(id,
+					introspect.Column{Name: "name", Type: "text", NotNull: true},
+					introspect.Column{Name: "age", Type: "bigint", Default: "0"},
+					introspect.Column{Name: "email", Type: "text"})},
+				Views: []introspect.View{usersView},
+			},
+			want: []string{
+				"drop_view active_users",
+				"set_not_null users.name",
+				"alter_column_type users.age",
+				"alter_column_default users.age",
+				"add_column users.email",
+				"drop_column users.nick",
+				"create_view active_users",
+			},
+		},
+		{
+			name: "generated columns",
+			from: &introspect.Schema{Tables: []*introspect.Table{users(id,
+				introspect.Column{Name: "a", Type: "integer"},
+				introspect.Column{Name: "b", Type: "integer", Generated: "id * 2"},
+				introspect.Column{Name: "c", Type: "integer", Generated: "id + 1"})}},
+			to: &introspect.Schema{Tables: []*introspect.Table{users(id,
+				introspect.Column{Name: "a", Type: "integer", Generated: "id * 3"},
+				introspect.Column{Name: "b", Type: "integer", Generated: "id * 3"},
+				introspect.Column{Name: "c", Type: "integer"})}},
+			want: []string{
+				"replace_column users.a",
+				"recreate_column users.b",
+				"alter_column_default users.c",
+			},
+		},
+		{
+			name: "enum values are added in place",
+			from: &introspect.Schema{Enums: []introspect.Enum{
+				{Name: "status", Values: []string{"new", "done"}},
+				{Name: "mood", Values: []string{"happy", "sad"}},
+			}},
+			to: &introspect.Schema{Enums: []introspect.Enum{
+				{Name: "status", Values: []string{"draft", "new", "review", "done"}},
+				{Name: "mood", Values: []string{"happy"}},
+			}},
+			want:     []string{"add_enum_value status.draft", "add_enum_value status.review"},
+			warnings: []string{"enum mood: value 'sad' cannot be removed in place"},
+		},
+		{
+			name: "renames follow the hints",
+			from: &introspect.Schema{Tables: []*introspect.Table{{Name: "customers", Columns: []introspect.Column{id, {Name: "name", Type: "text"}}}}},
+			to:   &introspect.Schema{Tables: []*introspect.Table{{Name: "clients", Columns: []introspect.Column{id, {Name: "full_name", Type: "text"}}}}},
+			hints: []config.SchemaRename{
+				{From: "customers.name", To: "clients.full_name"},
+				{From: "customers", To: "clients"},
+				{From: "orders", To: "purchases"},
+			},
+			want:     []string{"rename_table clients", "rename_column clients.full_name"},
+			warnings: []string{"rename of table orders to purchases ignored: orders must exist only before and purchases only after"},
+		},
+		{
+			name: "without hints a renamed table is dropped and created",
+			from: &introspect.Schema{Tables: []*introspect.Table{{Name: "customers", Columns: []introspect.Column{id}}}},
+			to:   &introspect.Schema{Tables: []*introspect.Table{{Name: "clients", Columns: []introspect.Column{id}}}},
+			want: []string{"create_table clients", "drop_table customers"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := Diff(tt.from, tt.to, tt.hints)
+			if got := steps(plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if !reflect.DeepEqual(plan.Warnings, tt.warnings) {
+				t.Errorf("Diff() warnings = %q, want %q", plan.Warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestDiffStatements(t *testing.T) {
+	from := &introspect.Schema{
+		Enums:  []introspect.Enum{{Name: "status", Values: []string{"new", "done"}}},
+		Tables: []*introspect.Table{{Name: "users", Columns: []introspect.Column{{Name: "total", Type: "integer"}}}},
+	}
+	to := &introspect.Schema{
+		Enums:  []introspect.Enum{{Name: "status", Values: []string{"draft", "queued", "new", "review", "done"}}},
+		Tables: []*introspect.Table{{Name: "users", Columns: []introspect.Column{{Name: "total", Type: "integer", Generated: "1 + 1"}}}},
+	}
+	plan := Diff(from, to, nil)
+
+	want := []Change{
+		{Kind: AddEnumValue, Object: "status.draft", SQL: `ALTER TYPE "status" ADD VALUE 'draft' BEFORE 'new';`},
+		{Kind: AddEnumValue, Object: "status.queued", SQL: `ALTER TYPE "status" ADD VALUE 'queued' AFTER 'draft';`},
+		{Kind: AddEnumValue, Object: "status.review", SQL: `ALTER TYPE "status" ADD VALUE 'review' AFTER 'new';`},
+		{Kind: ReplaceColumn, Object: "users.total",
+			SQL:     `ALTER TABLE "users" DROP COLUMN "total", ADD COLUMN "total" integer GENERATED ALWAYS AS (1 + 1) STORED;`,
+			Reverse: `ALTER TABLE "users" DROP COLUMN "total", ADD COLUMN "total" integer;`},
+	}
+	if !reflect.DeepEqual(plan.Changes, want) {
+		t.Errorf("Diff() =\n%+v\nwant\n%+v", plan.Changes, want)
+	}
+}
+
+func TestPlanScripts(t *testing.T) {
+	plan := &Plan{
+		Warnings: []string{"enum mood: value 'sad' cannot be removed in place"},
+		Changes: []Change{
+			{Kind: CreateFunction, Object: "touch()", SQL: "CREATE FUNCTION touch() ...;", Reverse: "DROP FUNCTION touch();"},
+			{Kind: AddEnumValue, Object: "status.draft", SQL: "ALTER TYPE status ADD VALUE 'draft';"},
+			{Kind: AddColumn, Object: "users.age", SQL: "ALTER TABLE users ADD COLUMN age integer;", Reverse: "ALTER TABLE users DROP COLUMN age;"},
+		},
+	}
+
+	up := "-- warning: enum mood: value 'sad' cannot be removed in place\n\n" +
+		"SET check_function_bodies = false;\n\n" +
+		"CREATE FUNCTION touch() ...;\n\n" +
+		"ALTER TYPE status ADD VALUE 'draft';\n\n" +
+		"ALTER TABLE users ADD COLUMN age integer;\n"
+	if got := plan.SQL(); got != up {
+		t.Errorf("SQL() =\n%s\nwant\n%s", got, up)
+	}
+
+	down := "SET check_function_bodies = false;\n\n" +
+		"ALTER TABLE users DROP COLUMN age;\n\n" +
+		"-- irreversible add_enum_value status.draft\n\n" +
+		"DROP FUNCTION touch();\n"
+	if got := plan.Down(); got != down {
+		t.Errorf("Down() =\n%s\nwant\n%s", got, down)
+	}
+
+	if got := (&Plan{}).SQL(); got != "-- no changes\n" {
+		t.Errorf("SQL() of an empty plan = %q", got)
+	}
+}
+
+// steps lists the kind and object of every change of a plan
+func steps(plan *Plan) []string {
+	var out []string
+	for _, c := range plan.Changes {
+		out = append(out, c.Kind+" "+c.Object)
+	}
+	return out
+}
@@ -0,0 +1,512 @@
+package schemadiff
+
+import (
+	"strings"
+)
+
+// Kinds of change only read from scripts
+const (
+	AlterTable = "alter_table" // another table action, e.g. OWNER TO or VALIDATE CONSTRAINT
+	Truncate   = "truncate"
+	DeleteRows = "delete_rows"
+	UpdateRows = "update_rows"
+	InsertRows = "insert_rows"
+	Statement  = "statement" // not recognized
+)
+
+// Parse reads a migration script, e.g. a versioned migration or the plan of
+// another tool, into the changes it makes, so it can be classified like a
+// planned diff. Every change runs one statement; an ALTER TABLE with several
+// actions becomes one change per action. Transaction control and SET
+// statements are left out. Parsed changes have no reverse.
+func Parse(script string) *Plan {
+	plan := &Plan{}
+	for _, tokens := range split(script) {
+		p := &parser{tokens: tokens}
+		plan.Changes = append(plan.Changes, p.statement()...)
+	}
+
+	// DROP INDEX does not name the table; take it from the index created
+	// again, so that the drop is known to be recreated
+	for i, c := range plan.Changes {
+		if c.Kind != DropIndex || strings.Contains(c.Object, ".") {
+			continue
+		}
+		for _, n := range plan.Changes[i:] {
+			if n.Kind == CreateIndex && strings.HasSuffix(n.Object, "."+c.Object) {
+				plan.Changes[i].Object = n.Object
+				break
+			}
+		}
+	}
+	return plan
+}
+
+// token is a word of a statement, and whether it followed whitespace
+type token struct {
+	text  string
+	space bool
+}
+
+// split cuts a script into statements of tokens. Comments are dropped;
+// string literals, quoted identifiers and dollar-quoted bodies are kept whole
+// within their token. Parentheses and commas are tokens of their own.
+func split(script string) [][]token {
+	var statements [][]token
+	var tokens []token
+	var text strings.Builder
+	space := false
+
+	endToken := func() {
+		if text.Len() > 0 {
+			tokens = append(tokens, token{text: text.String(), space: space})
+			text.Reset()
+			space = false
+		}
+	}
+
+	for i := 0; i < len(script); i++ {
+		ch := script[i]
+		switch {
+		case strings.HasPrefix(script[i:], "--"):
+			endToken()
+			space = true
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(script[i:], "/*"):
+			endToken()
+			space = true
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+		case ch == '\'' || ch == '"':
+			// E'...' literals escape with backslashes
+			escapes := ch == '\'' && strings.EqualFold(text.String(), "e")
+			j := i + 1
+			for j < len(script) {
+				if escapes && script[j] == '\\' {
+					j += 2
+					continue
+				}
+				if script[j] == ch {
+					if j+1 < len(script) && script[j+1] == ch {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			if j >= len(script) {
+				j = len(script) - 1
+			}
+			text.WriteString(script[i : j+1])
+			i = j
+		case ch == '$' && dollarTag(script[i:]) != "":
+			tag := dollarTag(script[i:])
+			end := strings.Index(script[i+len(tag):], tag)
+			if end < 0 {
+				text.WriteString(script[i:])
+				i = len(script)
+			} else {
+				text.WriteString(script[i : i+len(tag)+end+len(tag)])
+				i += len(tag) + end + len(tag) - 1
+			}
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			endToken()
+			space = true
+		case ch == '(' || ch == ')' || ch == ',':
+			endToken()
+			tokens = append(tokens, token{text: string(ch), space: space})
+			space = false
+		case ch == ';':
+			endToken()
+			if len(tokens) > 0 {
+				statements = append(statements, tokens)
+			}
+			tokens = nil
+			space = false
+		default:
+			text.WriteByte(ch)
+		}
+	}
+	endToken()
+	if len(tokens) > 0 {
+		statements = append(statements, tokens)
+	}
+	return statements
+}
+
+// dollarTag returns the dollar-quote tag, e.g. $$ or $body$, s starts with
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// join writes tokens back as a statement, whitespace and comments between
+// them as single spaces
+func join(tokens []token) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		if i > 0 && t.space {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t.text)
+	}
+	return b.String() + ";"
+}
+
+// name returns the parts of a possibly qualified name token, unquoted and,
+// unless quoted, folded to lower case
+func name(token string) []string {
+	var parts []string
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(token); i++ {
+		switch c := token[i]; {
+		case c == '"' && quoted && i+1 < len(token) && token[i+1] == '"':
+			b.WriteByte('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			parts = append(parts, b.String())
+			b.Reset()
+		case quoted:
+			b.WriteByte(c)
+		default:
+			b.WriteString(strings.ToLower(string(c)))
+		}
+	}
+	return append(parts, b.String())
+}
+
+// object returns the unqualified name of an object token
+func object(token string) string {
+	parts := name(token)
+	return parts[len(parts)-1]
+}
+
+// parser reads the tokens of one statement
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// accept consumes the keywords when the next tokens are them
+func (p *parser) accept(keywords ...string) bool {
+	if p.pos+len(keywords) > len(p.tokens) {
+		return false
+	}
+	for i, k := range keywords {
+		if !strings.EqualFold(p.tokens[p.pos+i].text, k) {
+			return false
+		}
+	}
+	p.pos += len(keywords)
+	return true
+}
+
+// next consumes the next token, empty at the end
+func (p *parser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1].text
+}
+
+// skipTo consumes the tokens up to and including the keyword
+func (p *parser) skipTo(keyword string) bool {
+	for p.pos < len(p.tokens) {
+		if p.accept(keyword) {
+			return true
+		}
+		p.pos++
+	}
+	return false
+}
+
+func (p *parser) statement() []Change {
+	sql := join(p.tokens)
+	one := func(kind, obj string) []Change {
+		return []Change{{Kind: kind, Object: obj, SQL: sql}}
+	}
+
+	switch {
+	case p.accept("SET"), p.accept("RESET"), p.accept("BEGIN"), p.accept("START"),
+		p.accept("COMMIT"), p.accept("END"):
+		return nil
+	case p.accept("CREATE"):
+		return p.create(one)
+	case p.accept("DROP"):
+		return p.drop(one)
+	case p.accept("ALTER", "TABLE"):
+		return p.alterTable()
+	case p.accept("ALTER", "TYPE"):
+		enum := object(p.next())
+		if p.accept("ADD", "VALUE") {
+			p.accept("IF", "NOT", "EXISTS")
+			return one(AddEnumValue, enum+"."+strings.Trim(p.next(), "'"))
+		}
+	case p.accept("ALTER", "SEQUENCE"):
+		p.accept("IF", "EXISTS")
+		return one(AlterSequence, object(p.next()))
+	case p.accept("COMMENT", "ON"):
+		column := p.accept("COLUMN")
+		if !column {
+			p.next()
+		}
+		parts := name(p.next())
+		if column && len(parts) > 1 {
+			return one(Comment, strings.Join(parts[len(parts)-2:], "."))
+		}
+		return one(Comment, parts[len(parts)-1])
+	case p.accept("TRUNCATE"):
+		p.accept("TABLE")
+		var tables []string
+		for {
+			p.accept("ONLY")
+			tables = append(tables, object(p.next()))
+			if !p.accept(",") {
+				break
+			}
+		}
+		return one(Truncate, strings.Join(tables, ","))
+	case p.accept("DELETE", "FROM"):
+		p.accept("ONLY")
+		return one(DeleteRows, object(p.next()))
+	case p.accept("UPDATE"):
+		p.accept("ONLY")
+		return one(UpdateRows, object(p.next()))
+	case p.accept("INSERT", "INTO"):
+		return one(InsertRows, object(p.next()))
+	}
+	return one(Statement, strings.ToLower(p.tokens[0].text))
+}
+
+func (p *parser) create(one func(kind, obj string) []Change) []Change {
+	replace := p.accept("OR", "REPLACE")
+	p.accept("UNIQUE")
+	if !p.accept("TEMPORARY") && !p.accept("TEMP") {
+		p.accept("UNLOGGED")
+	}
+
+	switch {
+	case p.accept("TABLE"):
+		p.accept("IF", "NOT", "EXISTS")
+		return one(CreateTable, object(p.next()))
+	case p.accept("INDEX"):
+		p.accept("CONCURRENTLY")
+		p.accept("IF", "NOT", "EXISTS")
+		index := ""
+		if !p.accept("ON") {
+			index = object(p.next())
+			p.accept("ON")
+		}
+		p.accept("ONLY")
+		table := object(p.next())
+		if index == "" {
+			return one(CreateIndex, table)
+		}
+		return one(CreateIndex, table+"."+index)
+	case p.accept("VIEW"), p.accept("MATERIALIZED", "VIEW"), p.accept("RECURSIVE", "VIEW"):
+		p.accept("IF", "NOT", "EXISTS")
+		return one(CreateView, object(p.next()))
+	case p.accept("FUNCTION"), p.accept("PROCEDURE"):
+		if replace {
+			return one(ReplaceFunction, object(p.next()))
+		}
+		return one(CreateFunction, object(p.next()))
+	case p.accept("TRIGGER"), p.accept("CONSTRAINT", "TRIGGER"):
+		trigger := object(p.next())
+		p.skipTo("ON")
+		return one(CreateTrigger, object(p.next())+"."+trigger)
+	case p.accept("TYPE"):
+		enum := object(p.next())
+		if p.accept("AS", "ENUM") {
+			return one(CreateEnum, enum)
+		}
+		return one(Statement, "create")
+	case p.accept("SEQUENCE"):
+		p.accept("IF", "NOT", "EXISTS")
+		return one(CreateSequence, object(p.next()))
+	case p.accept("EXTENSION"):
+		p.accept("IF", "NOT", "EXISTS")
+		return one(CreateExtension, object(p.next()))
+	}
+	return one(Statement, "create")
+}
+
+// drop reads a DROP statement, one change for each object it drops
+func (p *parser) drop(one func(kind, obj string) []Change) []Change {
+	start := p.pos
+	var kind string
+	switch {
+	case p.accept("TABLE"):
+		kind = DropTable
+	case p.accept("VIEW"), p.accept("MATERIALIZED", "VIEW"):
+		kind = DropView
+	case p.accept("INDEX"):
+		kind = DropIndex
+		p.accept("CONCURRENTLY")
+	case p.accept("FUNCTION"), p.accept("PROCEDURE"):
+		kind = DropFunction
+	case p.accept("TRIGGER"):
+		kind = DropTrigger
+	case p.accept("TYPE"):
+		kind = DropEnum
+	case p.accept("SEQUENCE"):
+		kind = DropSequence
+	case p.accept("EXTENSION"):
+		kind = DropExtension
+	default:
+		return one(Statement, "drop")
+	}
+	p.accept("IF", "EXISTS")
+	prefix := append([]token{{text: "DROP"}}, p.tokens[start:p.pos]...)
+
+	if kind == DropTrigger {
+		trigger := object(p.next())
+		p.accept("ON")
+		return one(DropTrigger, object(p.next())+"."+trigger)
+	}
+
+	// Each object is dropped on its own, with the CASCADE or RESTRICT of
+	// the statement
+	var objects [][]token
+	for p.pos < len(p.tokens) {
+		begin := p.pos
+		p.next()
+		if p.accept("(") {
+			for depth := 1; depth > 0 && p.pos < len(p.tokens); {
+				switch p.next() {
+				case "(":
+					depth++
+				case ")":
+					depth--
+				}
+			}
+		}
+		objects = append(objects, p.tokens[begin:p.pos])
+		if !p.accept(",") {
+			break
+		}
+	}
+	suffix := p.tokens[p.pos:]
+	if len(objects) == 1 {
+		return one(kind, object(objects[0][0].text))
+	}
+
+	changes := make([]Change, len(objects))
+	for i, o := range objects {
+		stmt := append(append(append([]token{}, prefix...), o...), suffix...)
+		stmt[len(prefix)].space = true
+		changes[i] = Change{Kind: kind, Object: object(o[0].text), SQL: join(stmt)}
+	}
+	return changes
+}
+
+// alterTable reads an ALTER TABLE statement, one change for each action
+func (p *parser) alterTable() []Change {
+	start := p.pos
+	p.accept("IF", "EXISTS")
+	p.accept("ONLY")
+	tableToken := p.next()
+	table := object(tableToken)
+	prefix := append([]token{{text: "ALTER"}, {text: "TABLE", space: true}}, p.tokens[start:p.pos]...)
+
+	// Actions are separated by the commas outside parentheses
+	var actions [][]token
+	begin, depth := p.pos, 0
+	for i := p.pos; i < len(p.tokens); i++ {
+		switch p.tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+			if depth == 0 {
+				actions = append(actions, p.tokens[begin:i])
+				begin = i + 1
+			}
+		}
+	}
+	actions = append(actions, p.tokens[begin:])
+
+	var changes []Change
+	for _, action := range actions {
+		if len(action) == 0 {
+			continue
+		}
+		kind, obj := tableAction(table, &parser{tokens: action})
+		stmt := append(append([]token{}, prefix...), action...)
+		stmt[len(prefix)].space = true
+		changes = append(changes, Change{Kind: kind, Object: obj, SQL: join(stmt)})
+	}
+	return changes
+}
+
+// tableAction returns the kind and object of one action of an ALTER TABLE
+func tableAction(table string, p *parser) (string, string) {
+	switch {
+	case p.accept("ADD", "CONSTRAINT"):
+		return AddConstraint, table + "." + object(p.next())
+	case p.accept("ADD", "PRIMARY"), p.accept("ADD", "UNIQUE"), p.accept("ADD", "CHECK"),
+		p.accept("ADD", "FOREIGN"), p.accept("ADD", "EXCLUDE"):
+		return AddConstraint, table
+	case p.accept("ADD"):
+		p.accept("COLUMN")
+		p.accept("IF", "NOT", "EXISTS")
+		return AddColumn, table + "." + object(p.next())
+	case p.accept("DROP", "CONSTRAINT"):
+		p.accept("IF", "EXISTS")
+		return DropConstraint, table + "." + object(p.next())
+	case p.accept("DROP"):
+		p.accept("COLUMN")
+		p.accept("IF", "EXISTS")
+		return DropColumn, table + "." + object(p.next())
+	case p.accept("ALTER"):
+		p.accept("COLUMN")
+		column := table + "." + object(p.next())
+		switch {
+		case p.accept("TYPE"), p.accept("SET", "DATA", "TYPE"):
+			return AlterColumnType, column
+		case p.accept("SET", "NOT", "NULL"):
+			return SetNotNull, column
+		case p.accept("DROP", "NOT", "NULL"):
+			return DropNotNull, column
+		case p.accept("SET", "DEFAULT"), p.accept("DROP", "DEFAULT"), p.accept("DROP", "EXPRESSION"):
+			return AlterColumnDefault, column
+		case p.accept("ADD", "GENERATED"), p.accept("SET", "GENERATED"), p.accept("DROP", "IDENTITY"), p.accept("RESTART"):
+			return AlterIdentity, column
+		}
+		return AlterTable, column
+	case p.accept("RENAME", "TO"):
+		return RenameTable, table
+	case p.accept("RENAME", "CONSTRAINT"):
+		return AlterTable, table
+	case p.accept("RENAME"):
+		p.accept("COLUMN")
+		return RenameColumn, table + "." + object(p.next())
+	case p.accept("ENABLE"), p.accept("DISABLE"):
+		p.accept("ALWAYS")
+		p.accept("REPLICA")
+		if p.accept("TRIGGER") {
+			return AlterTrigger, table + "." + object(p.next())
+		}
+	}
+	return AlterTable, table
+}
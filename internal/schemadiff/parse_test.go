@@ -0,0 +1,150 @@
+package schemadiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []Change
+	}{
+		{
+			name:   "create table",
+			script: `CREATE TABLE "public"."users" ("id" bigint NOT NULL, PRIMARY KEY ("id"));`,
+			want: []Change{{Kind: CreateTable, Object: "users",
+				SQL: `CREATE TABLE "public"."users" ("id" bigint NOT NULL, PRIMARY KEY ("id"));`}},
+		},
+		{
+			name:   "comments and transaction control are left out",
+			script: "-- Planned Changes:\n/* block */ BEGIN;\nSET lock_timeout = '1s';\nDROP TABLE orders; -- gone\nCOMMIT;",
+			want:   []Change{{Kind: DropTable, Object: "orders", SQL: "DROP TABLE orders;"}},
+		},
+		{
+			name:   "alter table actions are split",
+			script: `ALTER TABLE "users" ADD COLUMN "age" integer NULL, ALTER COLUMN "name" SET NOT NULL, DROP COLUMN "nick";`,
+			want: []Change{
+				{Kind: AddColumn, Object: "users.age", SQL: `ALTER TABLE "users" ADD COLUMN "age" integer NULL;`},
+				{Kind: SetNotNull, Object: "users.name", SQL: `ALTER TABLE "users" ALTER COLUMN "name" SET NOT NULL;`},
+				{Kind: DropColumn, Object: "users.nick", SQL: `ALTER TABLE "users" DROP COLUMN "nick";`},
+			},
+		},
+		{
+			name:   "commas in parentheses do not split actions",
+			script: `ALTER TABLE items ALTER COLUMN price TYPE numeric(10,2), ADD CONSTRAINT items_pk PRIMARY KEY (a, b);`,
+			want: []Change{
+				{Kind: AlterColumnType, Object: "items.price", SQL: `ALTER TABLE items ALTER COLUMN price TYPE numeric(10,2);`},
+				{Kind: AddConstraint, Object: "items.items_pk", SQL: `ALTER TABLE items ADD CONSTRAINT items_pk PRIMARY KEY (a, b);`},
+			},
+		},
+		{
+			name:   "dollar-quoted bodies are kept whole",
+			script: "CREATE OR REPLACE FUNCTION touch() RETURNS trigger AS $$ BEGIN NEW.at := now(); RETURN NEW; END; $$ LANGUAGE plpgsql;",
+			want: []Change{{Kind: ReplaceFunction, Object: "touch",
+				SQL: "CREATE OR REPLACE FUNCTION touch() RETURNS trigger AS $$ BEGIN NEW.at := now(); RETURN NEW; END; $$ LANGUAGE plpgsql;"}},
+		},
+		{
+			name:   "semicolons in literals do not end statements",
+			script: `UPDATE users SET bio = 'a; b' WHERE id = 1; COMMENT ON COLUMN public.users.bio IS 'it''s; fine';`,
+			want: []Change{
+				{Kind: UpdateRows, Object: "users", SQL: `UPDATE users SET bio = 'a; b' WHERE id = 1;`},
+				{Kind: Comment, Object: "users.bio", SQL: `COMMENT ON COLUMN public.users.bio IS 'it''s; fine';`},
+			},
+		},
+		{
+			name:   "dropping several objects drops each",
+			script: `DROP TABLE IF EXISTS a, "B" CASCADE;`,
+			want: []Change{
+				{Kind: DropTable, Object: "a", SQL: `DROP TABLE IF EXISTS a CASCADE;`},
+				{Kind: DropTable, Object: "B", SQL: `DROP TABLE IF EXISTS "B" CASCADE;`},
+			},
+		},
+		{
+			name:   "a dropped index takes the table of its recreation",
+			script: `DROP INDEX "users_email_idx"; CREATE UNIQUE INDEX "users_email_idx" ON "users" ("email");`,
+			want: []Change{
+				{Kind: DropIndex, Object: "users.users_email_idx", SQL: `DROP INDEX "users_email_idx";`},
+				{Kind: CreateIndex, Object: "users.users_email_idx", SQL: `CREATE UNIQUE INDEX "users_email_idx" ON "users" ("email");`},
+			},
+		},
+		{
+			name:   "enum values and triggers",
+			script: `ALTER TYPE status ADD VALUE 'archived' AFTER 'done'; CREATE TRIGGER touch BEFORE UPDATE ON orders FOR EACH ROW EXECUTE FUNCTION touch();`,
+			want: []Change{
+				{Kind: AddEnumValue, Object: "status.archived", SQL: `ALTER TYPE status ADD VALUE 'archived' AFTER 'done';`},
+				{Kind: CreateTrigger, Object: "orders.touch", SQL: `CREATE TRIGGER touch BEFORE UPDATE ON orders FOR EACH ROW EXECUTE FUNCTION touch();`},
+			},
+		},
+		{
+			name:   "data statements",
+			script: `TRUNCATE TABLE logs, audit; DELETE FROM sessions; INSERT INTO roles (name) VALUES ('admin');`,
+			want: []Change{
+				{Kind: Truncate, Object: "logs,audit", SQL: `TRUNCATE TABLE logs, audit;`},
+				{Kind: DeleteRows, Object: "sessions", SQL: `DELETE FROM sessions;`},
+				{Kind: InsertRows, Object: "roles", SQL: `INSERT INTO roles (name) VALUES ('admin');`},
+			},
+		},
+		{
+			name:   "unrecognized statements are kept",
+			script: `GRANT SELECT ON users TO reader; DO $$ BEGIN PERFORM 1; END $$;`,
+			want: []Change{
+				{Kind: Statement, Object: "grant", SQL: `GRANT SELECT ON users TO reader;`},
+				{Kind: Statement, Object: "do", SQL: `DO $$ BEGIN PERFORM 1; END $$;`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.script).Changes
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseClassify(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{`CREATE TABLE t (id int); CREATE INDEX t_id ON t (id);`, Safe},
+		{`CREATE INDEX CONCURRENTLY users_name ON users (name);`, Safe},
+		{`CREATE INDEX users_name ON users (name);`, LockHeavy},
+		{`ALTER TABLE users ADD CONSTRAINT users_age CHECK (age > 0) NOT VALID;`, Safe},
+		{`ALTER TABLE users ADD CONSTRAINT users_age CHECK (age > 0);`, LockHeavy},
+		{`ALTER TABLE users ADD COLUMN token uuid DEFAULT gen_random_uuid();`, LockHeavy},
+		{`ALTER TABLE users SET UNLOGGED;`, LockHeavy},
+		{`ALTER TABLE users OWNER TO app;`, Safe},
+		{`ALTER TABLE users ALTER COLUMN age TYPE smallint;`, DataLossRisk},
+		{`UPDATE users SET name = upper(name);`, DataLossRisk},
+		{`DO $$ BEGIN PERFORM 1; END $$;`, DataLossRisk},
+		{`GRANT SELECT ON users TO reader;`, Safe},
+		{`DROP SCHEMA archive CASCADE;`, Destructive},
+		{`TRUNCATE logs;`, Destructive},
+		{`DELETE FROM sessions;`, Destructive},
+		{`ALTER TABLE users DROP COLUMN nick;`, Destructive},
+		{`DROP VIEW v; CREATE VIEW v AS SELECT 1;`, Safe},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			got := Safe
+			for _, r := range Classify(Parse(tt.script)) {
+				if severity(r.Class) > severity(got) {
+					got = r.Class
+				}
+			}
+			if got != tt.want {
+				t.Errorf("class = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func severity(class string) int {
+	return map[string]int{Safe: 0, LockHeavy: 1, DataLossRisk: 2, Destructive: 3}[class]
+}
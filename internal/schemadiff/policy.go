@@ -0,0 +1,217 @@
+package schemadiff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+// Risk classes of a change, from the least to the most severe
+const (
+	Safe         = "safe"
+	LockHeavy    = "lock_heavy"     // scans or rewrites a table under a lock blocking writes
+	DataLossRisk = "data_loss_risk" // may alter or reject existing values
+	Destructive  = "destructive"    // drops data, or an object the plan does not recreate
+)
+
+// Policy actions for a risk class
+const (
+	Allow   = "allow"
+	Warn    = "warn"
+	Confirm = "confirm" // apply only when confirmed interactively
+	Block   = "block"
+)
+
+// volatileDefault matches defaults evaluated per row, which rewrite the
+// table when a column is added
+var volatileDefault = regexp.MustCompile(`(?i)\b(nextval|random|gen_random_uuid|uuid_generate_v[14]|clock_timestamp)\(`)
+
+// rewriteTable matches the table actions that rewrite the table
+var rewriteTable = regexp.MustCompile(`(?i) SET (LOGGED|UNLOGGED|TABLESPACE|ACCESS METHOD)\b`)
+
+// harmlessStatement matches the unrecognized statements that change no data
+var harmlessStatement = regexp.MustCompile(`(?i)^(CREATE|GRANT|REVOKE|SELECT) `)
+
+// Risk is the class of a planned change and the action the policy takes
+type Risk struct {
+	Change Change `json:"change"`
+	Class  string `json:"class"`
+	Reason string `json:"reason,omitempty"`
+	Action string `json:"action"`
+}
+
+// Classify returns the risk class of every change of a plan. Changes to
+// tables created by the plan are safe, as are drops of objects the plan
+// creates again.
+func Classify(plan *Plan) []Risk {
+	created := make(map[string]bool)
+	dropped := make(map[string]bool)
+	for _, c := range plan.Changes {
+		switch c.Kind {
+		case CreateTable:
+			created[c.Object] = true
+		case DropView, DropFunction, DropTrigger, DropConstraint, DropIndex:
+			dropped[c.Object] = true
+		}
+	}
+	recreated := make(map[string]bool)
+	for _, c := range plan.Changes {
+		switch c.Kind {
+		case CreateView, CreateFunction, CreateTrigger, AddConstraint, CreateIndex:
+			if dropped[c.Object] {
+				recreated[c.Object] = true
+			}
+		}
+	}
+
+	risks := make([]Risk, len(plan.Changes))
+	for i, c := range plan.Changes {
+		table, _, _ := strings.Cut(c.Object, ".")
+		class, reason := classify(c, created[table], recreated[c.Object])
+		risks[i] = Risk{Change: c, Class: class, Reason: reason}
+	}
+	return risks
+}
+
+// classify returns the risk class of a change and why
+func classify(c Change, newTable, recreated bool) (string, string) {
+	switch c.Kind {
+	case DropTable:
+		return Destructive, "drops the table and its rows"
+	case DropColumn:
+		return Destructive, "drops the column and its values"
+	case DropSequence:
+		return Destructive, "drops the sequence and its current value"
+	case DropExtension:
+		return Destructive, "drops the extension and the objects it owns"
+	case DropEnum:
+		return Destructive, "drops the type"
+	case DropView, DropFunction, DropTrigger, DropConstraint, DropIndex:
+		if recreated {
+			return Safe, "recreated by the plan"
+		}
+		return Destructive, "drops the " + strings.TrimPrefix(c.Kind, "drop_")
+	case AlterColumnType:
+		return DataLossRisk, "rewrites the table; values may be truncated or fail to cast"
+	case ReplaceColumn:
+		return Destructive, "replaces the stored values with generated ones"
+	case Truncate:
+		return Destructive, "deletes every row"
+	case DeleteRows:
+		return Destructive, "deletes rows"
+	case UpdateRows:
+		return DataLossRisk, "overwrites existing values"
+	case RecreateColumn:
+		return LockHeavy, "rewrites the table to compute the generated column"
+	case AlterTable:
+		if rewriteTable.MatchString(c.SQL) {
+			return LockHeavy, "rewrites the table"
+		}
+	case Statement:
+		switch {
+		case strings.HasPrefix(strings.ToUpper(c.SQL), "DROP "):
+			return Destructive, "drops an object"
+		case !harmlessStatement.MatchString(c.SQL):
+			return DataLossRisk, "not recognized, may change existing data"
+		}
+	case SetNotNull:
+		return LockHeavy, "scans the table under an exclusive lock, fails on nulls"
+	case AddColumn:
+		if strings.Contains(c.SQL, " GENERATED ") || volatileDefault.MatchString(c.SQL) {
+			return LockHeavy, "rewrites the table to fill the column"
+		}
+	case AddConstraint:
+		if !newTable && !strings.HasSuffix(c.SQL, "NOT VALID;") {
+			return LockHeavy, "validates every row under lock"
+		}
+	case CreateIndex:
+		if !newTable && !strings.Contains(c.SQL, " CONCURRENTLY ") {
+			return LockHeavy, "blocks writes while the index builds"
+		}
+	}
+	return Safe, ""
+}
+
+// Policy decides what happens to the risky changes of a plan on a target
+type Policy struct {
+	config *config.SchemaPolicy
+}
+
+// NewPolicy creates a policy from the settings of a target
+func NewPolicy(cfg *config.SchemaPolicy) *Policy {
+	return &Policy{config: cfg}
+}
+
+// Action returns the action taken for a risk class
+func (p *Policy) Action(class string) string {
+	switch class {
+	case Destructive:
+		return p.config.Destructive
+	case DataLossRisk:
+		return p.config.DataLossRisk
+	case LockHeavy:
+		return p.config.LockHeavy
+	default:
+		return Allow
+	}
+}
+
+// Evaluation is a plan checked against a policy
+type Evaluation struct {
+	Target string
+	Risks  []Risk // the changes that are not safe, with their action
+}
+
+// Evaluate classifies the changes of a plan and sets the action for each
+func (p *Policy) Evaluate(target string, plan *Plan) *Evaluation {
+	e := &Evaluation{Target: target}
+	for _, r := range Classify(plan) {
+		if r.Class == Safe {
+			continue
+		}
+		r.Action = p.Action(r.Class)
+		e.Risks = append(e.Risks, r)
+	}
+	return e
+}
+
+// Count returns the number of risky changes the policy takes an action on
+func (e *Evaluation) Count(action string) int {
+	n := 0
+	for _, r := range e.Risks {
+		if r.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Report generates a human-readable report of the risky changes
+func (e *Evaluation) Report() string {
+	var report string
+	report += "\n========================================\n"
+	report += "       SCHEMA CHANGE RISK REPORT        \n"
+	report += "========================================\n\n"
+	report += fmt.Sprintf("Target: %s\n\n", e.Target)
+
+	if len(e.Risks) == 0 {
+		report += "✓ Every planned change is safe\n"
+	}
+	for _, r := range e.Risks {
+		mark := "⚠"
+		if r.Action == Block {
+			mark = "✗"
+		}
+		report += fmt.Sprintf("%s %s %s - %s, %s (%s)\n", mark, r.Change.Kind, r.Change.Object, strings.ToUpper(r.Action), r.Class, r.Reason)
+	}
+
+	report += "\n========================================\n"
+	report += fmt.Sprintf("Blocked:         %d\n", e.Count(Block))
+	report += fmt.Sprintf("To confirm:      %d\n", e.Count(Confirm))
+	report += fmt.Sprintf("Warnings:        %d\n", e.Count(Warn))
+	report += "========================================\n"
+
+	return report
+}
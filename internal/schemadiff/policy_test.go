@@ -0,0 +1,104 @@
+package schemadiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		change Change
+		want   string
+	}{
+		{"drop table", Change{Kind: DropTable, Object: "users"}, Destructive},
+		{"drop column", Change{Kind: DropColumn, Object: "users.nick"}, Destructive},
+		{"drop enum", Change{Kind: DropEnum, Object: "status"}, Destructive},
+		{"drop view", Change{Kind: DropView, Object: "v"}, Destructive},
+		{"regular column becomes generated", Change{Kind: ReplaceColumn, Object: "users.total"}, Destructive},
+		{"generated expression changes", Change{Kind: RecreateColumn, Object: "users.total"}, LockHeavy},
+		{"retype", Change{Kind: AlterColumnType, Object: "users.age"}, DataLossRisk},
+		{"set not null", Change{Kind: SetNotNull, Object: "users.name"}, LockHeavy},
+		{"drop not null", Change{Kind: DropNotNull, Object: "users.name"}, Safe},
+		{"add column", Change{Kind: AddColumn, Object: "users.age", SQL: `ALTER TABLE "users" ADD COLUMN "age" integer DEFAULT 0;`}, Safe},
+		{"add column with a volatile default", Change{Kind: AddColumn, Object: "users.ref",
+			SQL: `ALTER TABLE "users" ADD COLUMN "ref" uuid DEFAULT gen_random_uuid();`}, LockHeavy},
+		{"add generated column", Change{Kind: AddColumn, Object: "users.total",
+			SQL: `ALTER TABLE "users" ADD COLUMN "total" integer GENERATED ALWAYS AS (a + b) STORED;`}, LockHeavy},
+		{"add constraint", Change{Kind: AddConstraint, Object: "users.users_age", SQL: `ALTER TABLE "users" ADD CONSTRAINT "users_age" CHECK (age > 0);`}, LockHeavy},
+		{"add unvalidated constraint", Change{Kind: AddConstraint, Object: "users.users_age",
+			SQL: `ALTER TABLE "users" ADD CONSTRAINT "users_age" CHECK (age > 0) NOT VALID;`}, Safe},
+		{"create index", Change{Kind: CreateIndex, Object: "users.users_name", SQL: "CREATE INDEX users_name ON users (name);"}, LockHeavy},
+		{"enum value", Change{Kind: AddEnumValue, Object: "status.draft"}, Safe},
+		{"comment", Change{Kind: Comment, Object: "users"}, Safe},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risks := Classify(&Plan{Changes: []Change{tt.change}})
+			if risks[0].Class != tt.want {
+				t.Errorf("class = %s, want %s", risks[0].Class, tt.want)
+			}
+			if risks[0].Class != Safe && risks[0].Reason == "" {
+				t.Error("a risky change has no reason")
+			}
+		})
+	}
+}
+
+func TestClassifyInPlan(t *testing.T) {
+	plan := &Plan{Changes: []Change{
+		{Kind: DropView, Object: "active_users"},
+		{Kind: DropIndex, Object: "users.users_name"},
+		{Kind: DropTrigger, Object: "users.audit"},
+		{Kind: CreateTable, Object: "orders"},
+		{Kind: AddConstraint, Object: "orders.orders_total", SQL: `ALTER TABLE "orders" ADD CONSTRAINT "orders_total" CHECK (total > 0);`},
+		{Kind: CreateIndex, Object: "orders.orders_user", SQL: "CREATE INDEX orders_user ON orders (user_id);"},
+		{Kind: CreateIndex, Object: "users.users_name", SQL: "CREATE INDEX users_name ON users (lower(name));"},
+		{Kind: CreateView, Object: "active_users"},
+	}}
+
+	want := []string{Safe, Safe, Destructive, Safe, Safe, Safe, LockHeavy, Safe}
+	for i, r := range Classify(plan) {
+		if r.Class != want[i] {
+			t.Errorf("%s %s: class = %s, want %s", r.Change.Kind, r.Change.Object, r.Class, want[i])
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	policy := NewPolicy(&config.SchemaPolicy{Destructive: Block, DataLossRisk: Confirm, LockHeavy: Warn})
+	plan := &Plan{Changes: []Change{
+		{Kind: CreateTable, Object: "orders", SQL: "CREATE TABLE orders (id bigint);"},
+		{Kind: DropColumn, Object: "users.nick", SQL: `ALTER TABLE "users" DROP COLUMN "nick";`},
+		{Kind: ReplaceColumn, Object: "users.total"},
+		{Kind: AlterColumnType, Object: "users.age"},
+		{Kind: SetNotNull, Object: "users.name"},
+	}}
+
+	e := policy.Evaluate("remote", plan)
+	if len(e.Risks) != 4 {
+		t.Fatalf("Evaluate() returned %d risks, want the 4 unsafe changes", len(e.Risks))
+	}
+	for action, want := range map[string]int{Block: 2, Confirm: 1, Warn: 1, Allow: 0} {
+		if got := e.Count(action); got != want {
+			t.Errorf("Count(%s) = %d, want %d", action, got, want)
+		}
+	}
+
+	report := e.Report()
+	for _, want := range []string{"Target: remote", "✗ drop_column users.nick - BLOCK", "⚠ set_not_null users.name - WARN", "Blocked:         2"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Report() does not contain %q:\n%s", want, report)
+		}
+	}
+
+	if got := policy.Action(Safe); got != Allow {
+		t.Errorf("Action(safe) = %s, want allow", got)
+	}
+	if e := policy.Evaluate("local", &Plan{}); len(e.Risks) != 0 || !strings.Contains(e.Report(), "Every planned change is safe") {
+		t.Errorf("Evaluate() of an empty plan = %+v", e)
+	}
+}
@@ -0,0 +1,148 @@
+package transform
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+	"github.com/expr-lang/expr/vm"
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+// Transformer computes column values from expressions over the other columns
+// of the same row. Expressions run in a sandbox: they can only read the row
+// and call the language's builtins.
+type Transformer struct {
+	rules map[string][]config.TransformRule // keyed by table
+}
+
+// Program is the compiled set of transforms for one table
+type Program struct {
+	table   string
+	columns []string
+	steps   []step
+}
+
+type step struct {
+	column  string
+	index   int
+	program *vm.Program
+}
+
+// NewTransformer creates a transformer for the configured rules
+func NewTransformer(rules []config.TransformRule) *Transformer {
+	t := &Transformer{rules: make(map[string][]config.TransformRule)}
+	for _, r := range rules {
+		t.rules[r.Table] = append(t.rules[r.Table], r)
+	}
+	return t
+}
+
+// Compile compiles the transforms of a table against its column list. It
+// returns nil when the table has no transforms.
+func (t *Transformer) Compile(table string, columns []string) (*Program, error) {
+	rules := t.rules[table]
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	// Columns shadow builtins of the same name (e.g. first, last)
+	index := make(map[string]int, len(columns))
+	options := []expr.Option{expr.AllowUndefinedVariables()}
+	for i, col := range columns {
+		index[col] = i
+		options = append(options, expr.DisableBuiltin(col))
+	}
+
+	p := &Program{table: table, columns: columns}
+	for _, r := range rules {
+		i, ok := index[r.Column]
+		if !ok {
+			return nil, fmt.Errorf("transform for %s.%s: column does not exist", table, r.Column)
+		}
+		// Column types are only known at run time, so the expression is
+		// compiled untyped and its identifiers are checked against the columns
+		tree, err := parser.Parse(r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("transform for %s.%s: %w", table, r.Column, err)
+		}
+		if name := unknownIdentifier(tree, index); name != "" {
+			return nil, fmt.Errorf("transform for %s.%s: unknown column %q", table, r.Column, name)
+		}
+		program, err := expr.Compile(r.Expr, options...)
+		if err != nil {
+			return nil, fmt.Errorf("transform for %s.%s: %w", table, r.Column, err)
+		}
+		p.steps = append(p.steps, step{column: r.Column, index: i, program: program})
+	}
+
+	return p, nil
+}
+
+// Apply evaluates the transforms on a row in place. Transforms run in config
+// order, so each one sees the values computed by the previous ones.
+func (p *Program) Apply(values []interface{}) error {
+	env := make(map[string]interface{}, len(p.columns))
+	for i, col := range p.columns {
+		env[col] = exprValue(values[i])
+	}
+
+	for _, s := range p.steps {
+		out, err := expr.Run(s.program, env)
+		if err != nil {
+			return fmt.Errorf("transform for %s.%s failed: %w", p.table, s.column, err)
+		}
+		v, err := driver.DefaultParameterConverter.ConvertValue(out)
+		if err != nil {
+			return fmt.Errorf("transform for %s.%s returned %T, expected a scalar", p.table, s.column, out)
+		}
+		values[s.index] = v
+		env[s.column] = exprValue(v)
+	}
+	return nil
+}
+
+// identifiers collects the names referenced by an expression
+type identifiers struct {
+	callees  map[ast.Node]bool
+	declared map[string]bool
+	nodes    []*ast.IdentifierNode
+}
+
+func (v *identifiers) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		v.nodes = append(v.nodes, n)
+	case *ast.CallNode:
+		v.callees[n.Callee] = true
+	case *ast.VariableDeclaratorNode:
+		v.declared[n.Name] = true
+	}
+}
+
+// unknownIdentifier returns the first identifier that is neither a column,
+// a function nor a let variable, "" when there is none
+func unknownIdentifier(tree *parser.Tree, columns map[string]int) string {
+	v := &identifiers{callees: make(map[ast.Node]bool), declared: make(map[string]bool)}
+	ast.Walk(&tree.Node, v)
+
+	for _, n := range v.nodes {
+		if v.callees[n] || v.declared[n.Value] {
+			continue
+		}
+		if _, ok := columns[n.Value]; !ok {
+			return n.Value
+		}
+	}
+	return ""
+}
+
+// exprValue exposes text returned as bytes (e.g. numeric columns) as strings
+func exprValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
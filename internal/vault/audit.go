@@ -0,0 +1,65 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+// Audit outcomes
+const (
+	OutcomeFound    = "found"
+	OutcomeNotFound = "not_found"
+	OutcomeDenied   = "denied" // the vault could not be opened with the given passphrase
+)
+
+// AuditEvent records a single detokenization attempt
+type AuditEvent struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Host    string    `json:"host"`
+	Token   string    `json:"token"`
+	Outcome string    `json:"outcome"`
+	Table   string    `json:"table,omitempty"`
+	Column  string    `json:"column,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// NewAuditEvent creates an event for the current user and host
+func NewAuditEvent(token, outcome, reason string) AuditEvent {
+	e := AuditEvent{
+		Time:    time.Now().UTC(),
+		Token:   token,
+		Outcome: outcome,
+		Reason:  reason,
+	}
+	if u, err := user.Current(); err == nil {
+		e.User = u.Username
+	}
+	if h, err := os.Hostname(); err == nil {
+		e.Host = h
+	}
+	return e
+}
+
+// AppendAudit appends an event to the audit log as a JSON line. The log is
+// never read by the tool; it is evidence for whoever reviews lookups.
+func AppendAudit(path string, e AuditEvent) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return f.Sync()
+}
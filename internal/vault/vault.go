@@ -0,0 +1,235 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	fileVersion = 1
+
+	// TokenLength is the length of a token after its prefix, in hex digits
+	TokenLength = 24
+
+	// scrypt parameters for deriving the vault key from the passphrase
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// Entry is the original value behind a token
+type Entry struct {
+	Value     string    `json:"value"`
+	Table     string    `json:"table"`
+	Column    string    `json:"column"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Vault maps random tokens back to the values they replaced. It is stored as
+// a single file encrypted with AES-256-GCM under a key derived from a passphrase.
+type Vault struct {
+	path string
+	key  []byte
+	salt []byte
+
+	mu      sync.Mutex
+	tokens  map[string]Entry
+	byValue map[[32]byte]string // original value hash -> token, so values keep their token
+	dirty   bool
+}
+
+// vaultFile is the on-disk format of a vault
+type vaultFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"` // encrypted JSON map of token -> entry
+}
+
+// ErrWrongPassphrase is returned when a vault can't be decrypted
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted vault")
+
+// Open loads the vault at path, creating an empty one if the file doesn't exist
+func Open(path, passphrase string) (*Vault, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("a vault passphrase is required")
+	}
+
+	v := &Vault{
+		path:    path,
+		tokens:  make(map[string]Entry),
+		byValue: make(map[[32]byte]string),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		v.salt = make([]byte, 16)
+		if _, err := rand.Read(v.salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		if v.key, err = deriveKey(passphrase, v.salt); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	var f vaultFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse vault: %w", err)
+	}
+	if f.Version != fileVersion || f.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported vault version %d (%s)", f.Version, f.KDF)
+	}
+
+	v.salt = f.Salt
+	if v.key, err = deriveKey(passphrase, v.salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plain, &v.tokens); err != nil {
+		return nil, fmt.Errorf("failed to decode vault entries: %w", err)
+	}
+	for token, e := range v.tokens {
+		v.byValue[sha256.Sum256([]byte(e.Value))] = token
+	}
+
+	return v, nil
+}
+
+// Tokenize returns the token for a value, creating one if the value hasn't
+// been seen. The same value always gets the same token so joins still work.
+func (v *Vault) Tokenize(table, column, value, prefix string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	h := sha256.Sum256([]byte(value))
+	if token, ok := v.byValue[h]; ok {
+		return token, nil
+	}
+
+	var raw [TokenLength / 2]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := prefix + hex.EncodeToString(raw[:])
+
+	v.tokens[token] = Entry{Value: value, Table: table, Column: column, CreatedAt: time.Now().UTC()}
+	v.byValue[h] = token
+	v.dirty = true
+	return token, nil
+}
+
+// Lookup returns the original value behind a token
+func (v *Vault) Lookup(token string) (Entry, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e, ok := v.tokens[token]
+	return e, ok
+}
+
+// Len returns the number of tokens in the vault
+func (v *Vault) Len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.tokens)
+}
+
+// Save encrypts the vault and replaces the file atomically
+func (v *Vault) Save() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if !v.dirty {
+		return nil
+	}
+
+	plain, err := json.Marshal(v.tokens)
+	if err != nil {
+		return fmt.Errorf("failed to encode vault entries: %w", err)
+	}
+
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	data, err := json.Marshal(vaultFile{
+		Version: fileVersion,
+		KDF:     "scrypt",
+		Salt:    v.salt,
+		Nonce:   nonce,
+		Data:    gcm.Seal(nil, nonce, plain, nil),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode vault: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), ".vault-*")
+	if err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), v.path); err != nil {
+		return fmt.Errorf("failed to replace vault: %w", err)
+	}
+
+	v.dirty = false
+	return nil
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive vault key: %w", err)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return gcm, nil
+}
@@ -0,0 +1,128 @@
+package vault
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.vault")
+
+	v, err := Open(path, "secret")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	email, err := v.Tokenize("users", "email", "jane@example.com", "tok_")
+	if err != nil {
+		t.Fatalf("Tokenize() error = %v", err)
+	}
+	if !strings.HasPrefix(email, "tok_") || len(email) != len("tok_")+TokenLength {
+		t.Errorf("Tokenize() = %s, want tok_ and %d hex digits", email, TokenLength)
+	}
+
+	// The same value keeps its token, even from another column, so joins on
+	// tokenized columns still match
+	again, _ := v.Tokenize("orders", "customer_email", "jane@example.com", "tok_")
+	if again != email {
+		t.Errorf("Tokenize() = %s for a known value, want %s", again, email)
+	}
+	ssn, _ := v.Tokenize("users", "ssn", "123-45-6789", "")
+	if ssn == email || len(ssn) != TokenLength {
+		t.Errorf("Tokenize() = %s, want a new unprefixed token", ssn)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("vault written before Save(): %v", err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), "jane@example.com") {
+		t.Fatal("vault file contains a plaintext value")
+	}
+
+	reopened, err := Open(path, "secret")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if reopened.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", reopened.Len())
+	}
+	e, ok := reopened.Lookup(email)
+	if !ok || e.Value != "jane@example.com" || e.Table != "users" || e.Column != "email" {
+		t.Errorf("Lookup(%s) = %+v, %v", email, e, ok)
+	}
+	if _, ok := reopened.Lookup("tok_unknown"); ok {
+		t.Error("Lookup() found an unknown token")
+	}
+	if token, _ := reopened.Tokenize("users", "email", "jane@example.com", "tok_"); token != email {
+		t.Errorf("Tokenize() = %s after reopening, want %s", token, email)
+	}
+}
+
+func TestVaultOpenErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.vault")
+
+	if _, err := Open(path, ""); err == nil {
+		t.Error("Open() without a passphrase succeeded")
+	}
+
+	v, err := Open(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Tokenize("users", "email", "jane@example.com", "tok_"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(path, "guess"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Open() with a wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": 2, "kdf": "scrypt"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, "secret"); err == nil {
+		t.Error("Open() accepted an unsupported version")
+	}
+}
+
+func TestAppendAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for _, outcome := range []string{OutcomeFound, OutcomeDenied} {
+		if err := AppendAudit(path, NewAuditEvent("tok_1", outcome, "support ticket 42")); err != nil {
+			t.Fatalf("AppendAudit() error = %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var outcomes []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("audit line %q: %v", scanner.Text(), err)
+		}
+		if e.Token != "tok_1" || e.Reason != "support ticket 42" || e.Time.IsZero() {
+			t.Errorf("audit event = %+v", e)
+		}
+		outcomes = append(outcomes, e.Outcome)
+	}
+	if strings.Join(outcomes, ",") != "found,denied" {
+		t.Errorf("outcomes = %v, want found,denied", outcomes)
+	}
+}
@@ -0,0 +1,118 @@
+package verifier
+
+import (
+	"fmt"
+	"math"
+	"path"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+// Kinds of drift from the baseline
+const (
+	DriftDropped = "rows_dropped" // fewer local rows than the baseline allows
+	DriftGrew    = "rows_grew"    // more local rows than the baseline allows
+	DriftMissing = "missing"      // verified in the baseline, not in this run
+	DriftFailing = "failing"      // passed in the baseline, fails to verify now
+)
+
+// DriftAlert is a table that drifted from the baseline beyond its limits
+type DriftAlert struct {
+	Table    string
+	Kind     string
+	Baseline int64   // local rows of the baseline
+	Current  int64   // local rows of this run
+	Change   float64 // in percent of the baseline rows
+}
+
+// CompareBaseline compares the local row counts of a run with a baseline,
+// the last good run, and returns the tables drifting beyond the limits. A run
+// limited to the tables of filter is compared on those tables only.
+func CompareBaseline(baseline *HistoryRun, results []VerificationResult, filter []string, cfg *config.BaselineConfig) []DriftAlert {
+	current := make(map[string]VerificationResult, len(results))
+	for _, r := range results {
+		current[r.Table] = r
+	}
+	selected := make(map[string]bool, len(filter))
+	for _, name := range filter {
+		selected[name] = true
+	}
+
+	var alerts []DriftAlert
+	for _, base := range baseline.Tables {
+		if len(filter) > 0 && !selected[base.Table] {
+			continue
+		}
+		r, ok := current[base.Table]
+		if !ok {
+			alerts = append(alerts, DriftAlert{Table: base.Table, Kind: DriftMissing, Baseline: base.LocalRows})
+			continue
+		}
+		if r.Error != nil {
+			if base.Error == "" {
+				alerts = append(alerts, DriftAlert{Table: base.Table, Kind: DriftFailing, Baseline: base.LocalRows})
+			}
+			continue
+		}
+
+		alert := DriftAlert{Table: base.Table, Baseline: base.LocalRows, Current: r.LocalRows}
+		// An empty baseline counts as one row, so rows appearing in an empty
+		// table still register as growth
+		alert.Change = float64(r.LocalRows-base.LocalRows) * 100 / math.Max(1, float64(base.LocalRows))
+		maxDrop, maxGrowth := driftLimits(base.Table, cfg)
+		switch {
+		case maxDrop > 0 && -alert.Change > maxDrop:
+			alert.Kind = DriftDropped
+		case maxGrowth > 0 && alert.Change > maxGrowth:
+			alert.Kind = DriftGrew
+		default:
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// driftLimits returns the drop and growth limits of a table; the first
+// matching override wins
+func driftLimits(table string, cfg *config.BaselineConfig) (float64, float64) {
+	for _, t := range cfg.Tables {
+		if ok, _ := path.Match(t.Table, table); ok {
+			return t.MaxDropPercent, t.MaxGrowthPercent
+		}
+	}
+	return cfg.MaxDropPercent, cfg.MaxGrowthPercent
+}
+
+// GenerateBaselineReport generates a human-readable baseline comparison report
+func (v *Verifier) GenerateBaselineReport(baseline *HistoryRun, alerts []DriftAlert) string {
+	var report string
+	report += "\n========================================\n"
+	report += "        BASELINE COMPARISON REPORT       \n"
+	report += "========================================\n\n"
+
+	if baseline == nil {
+		report += "No good run recorded yet, this run has no baseline\n"
+	} else {
+		report += fmt.Sprintf("Baseline: %s (%d tables)\n\n", baseline.GeneratedAt.Format("2006-01-02 15:04:05 MST"), len(baseline.Tables))
+		if len(alerts) == 0 {
+			report += "✓ No table drifted beyond its limits\n"
+		}
+	}
+	for _, a := range alerts {
+		switch a.Kind {
+		case DriftMissing:
+			report += fmt.Sprintf("✗ %s - MISSING (Baseline: %d rows)\n", a.Table, a.Baseline)
+		case DriftFailing:
+			report += fmt.Sprintf("✗ %s - FAILING (passed in the baseline)\n", a.Table)
+		default:
+			report += fmt.Sprintf("✗ %s - %+.1f%% (Baseline: %d, Current: %d)\n", a.Table, a.Change, a.Baseline, a.Current)
+		}
+	}
+
+	report += "\n========================================\n"
+	report += fmt.Sprintf("Drift Alerts:    %d\n", len(alerts))
+	report += "========================================\n"
+
+	return report
+}
@@ -0,0 +1,113 @@
+package verifier
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+func TestCompareBaseline(t *testing.T) {
+	baseline := &HistoryRun{Tables: []HistoryTable{
+		{Table: "users", LocalRows: 100},
+		{Table: "orders", LocalRows: 1000},
+		{Table: "audit", LocalRows: 0},
+	}}
+	cfg := &config.BaselineConfig{MaxDropPercent: 20, MaxGrowthPercent: 50}
+
+	tests := []struct {
+		name    string
+		results []VerificationResult
+		filter  []string
+		want    []string // table kind
+	}{
+		{
+			name:    "within limits",
+			results: []VerificationResult{{Table: "users", LocalRows: 90}, {Table: "orders", LocalRows: 1400}, {Table: "audit"}},
+		},
+		{
+			name:    "dropped, grew and missing",
+			results: []VerificationResult{{Table: "users", LocalRows: 70}, {Table: "orders", LocalRows: 1600}},
+			want:    []string{"users rows_dropped", "orders rows_grew", "audit missing"},
+		},
+		{
+			name:    "rows appearing in an empty table",
+			results: []VerificationResult{{Table: "users", LocalRows: 100}, {Table: "orders", LocalRows: 1000}, {Table: "audit", LocalRows: 5}},
+			want:    []string{"audit rows_grew"},
+		},
+		{
+			name:    "a filtered run is compared on its tables only",
+			results: []VerificationResult{{Table: "users", LocalRows: 100}},
+			filter:  []string{"users"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, a := range CompareBaseline(baseline, tt.results, tt.filter, cfg) {
+				got = append(got, a.Table+" "+a.Kind)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareBaseline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryLastGood(t *testing.T) {
+	h := NewHistory(&config.HistoryConfig{Dir: t.TempDir()})
+	results := func(tables ...string) []VerificationResult {
+		var out []VerificationResult
+		for _, name := range tables {
+			out = append(out, VerificationResult{Table: name, Match: true})
+		}
+		return out
+	}
+
+	runs := []*HistoryRun{
+		NewHistoryRun("prod/app", "localhost/app", nil, true, results("users", "orders")),
+		NewHistoryRun("prod/app", "localhost/app", []string{"users"}, true, results("users")),
+		NewHistoryRun("staging/app", "localhost/app", nil, true, results("users", "orders")),
+		NewHistoryRun("prod/app", "localhost/app", nil, false, results("users", "orders")),
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, run := range runs {
+		run.GeneratedAt = start.Add(time.Duration(i) * time.Hour)
+		if _, err := h.Save(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		source, target string
+		filter         []string
+		want           int // index of the run, -1 for none
+	}{
+		{"full run", "prod/app", "localhost/app", nil, 0},
+		{"filtered run", "prod/app", "localhost/app", []string{"users"}, 1},
+		{"filtered run on a table only the full run covers", "prod/app", "localhost/app", []string{"orders"}, 0},
+		{"other databases", "staging/app", "localhost/app", nil, 2},
+		{"no run yet", "prod/app", "ci/app", nil, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.LastGood(tt.source, tt.target, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want < 0 {
+				if got != nil {
+					t.Errorf("LastGood() = run of %s, want none", got.GeneratedAt)
+				}
+				return
+			}
+			if got == nil || !got.GeneratedAt.Equal(runs[tt.want].GeneratedAt) {
+				t.Errorf("LastGood() = %+v, want run %d", got, tt.want)
+			}
+		})
+	}
+}
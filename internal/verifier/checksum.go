@@ -0,0 +1,361 @@
+package verifier
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/thien/database-migration-tool/internal/logger"
+	"go.uber.org/zap"
+)
+
+// maxRowDiffs bounds the differing rows kept per table
+const maxRowDiffs = 20
+
+// textSettings pin the session settings the text form of values depends on,
+// e.g. of timestamptz and float columns, so that equal values hash alike on
+// both databases
+var textSettings = []string{
+	"SET LOCAL TimeZone = 'UTC'",
+	"SET LOCAL extra_float_digits = 3",
+	"SET LOCAL DateStyle = 'ISO, MDY'",
+	"SET LOCAL IntervalStyle = 'postgres'",
+	"SET LOCAL bytea_output = 'hex'",
+}
+
+// Kinds of row differences
+const (
+	RowMissing = "missing" // only in the remote database
+	RowExtra   = "extra"   // only in the local database
+	RowChanged = "changed" // in both with different values
+)
+
+// RowDiff is a row whose content differs between the databases
+type RowDiff struct {
+	Key  string // primary key as a row literal, e.g. (42)
+	Kind string
+}
+
+// EnableChecksums makes verification compare table contents, not only row
+// counts. Tables are split into chunks of chunkSize rows by primary key and
+// the columns for which exclude returns true (e.g. anonymized) are skipped.
+func (v *Verifier) EnableChecksums(chunkSize int, exclude func(table, column string) bool) {
+	if chunkSize <= 0 {
+		chunkSize = 10000
+	}
+	v.chunkSize = chunkSize
+	v.exclude = exclude
+}
+
+// chunk is a primary key range; nil bounds are open
+type chunk struct {
+	from, to interface{}
+}
+
+// verifyContent compares the chunk checksums of a table and drills into the
+// rows of the chunks that differ
+func (v *Verifier) verifyContent(ctx context.Context, result *VerificationResult) error {
+	table := result.Table
+
+	key, compared, excluded, err := v.comparedColumns(ctx, table)
+	if err != nil {
+		return err
+	}
+	result.Excluded = excluded
+
+	chunks, err := v.getChunks(ctx, table, key[0])
+	if err != nil {
+		return fmt.Errorf("failed to split table: %w", err)
+	}
+	result.Chunks = len(chunks)
+
+	q := newChunkQueries(table, key, compared)
+	digest := md5.New()
+	for _, c := range chunks {
+		remote, err := q.checksum(ctx, v.remoteDB, c)
+		if err != nil {
+			return fmt.Errorf("failed to checksum remote chunk: %w", err)
+		}
+		local, err := q.checksum(ctx, v.localDB, c)
+		if err != nil {
+			return fmt.Errorf("failed to checksum local chunk: %w", err)
+		}
+		digest.Write([]byte(remote))
+		if remote == local {
+			continue
+		}
+
+		result.ChunkMismatches++
+		diffs, err := v.diffChunk(ctx, q, c)
+		if err != nil {
+			return err
+		}
+		result.RowsDiffering += int64(len(diffs))
+		for _, d := range diffs {
+			if len(result.RowDiffs) < maxRowDiffs {
+				result.RowDiffs = append(result.RowDiffs, d)
+			}
+		}
+	}
+	result.Checksum = hex.EncodeToString(digest.Sum(nil))
+
+	return nil
+}
+
+// comparedColumns returns the primary key of a table and splits its columns
+// into those compared and those excluded
+func (v *Verifier) comparedColumns(ctx context.Context, table string) (key, compared, excluded []string, err error) {
+	key, err = v.getPrimaryKey(ctx, table)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get primary key: %w", err)
+	}
+	if len(key) == 0 {
+		return nil, nil, nil, fmt.Errorf("table has no primary key, contents not compared")
+	}
+	for _, col := range key {
+		if v.exclude != nil && v.exclude(table, col) {
+			return nil, nil, nil, fmt.Errorf("primary key column %s is anonymized, contents not compared", col)
+		}
+	}
+
+	columns, err := v.getColumns(ctx, v.remoteDB, table)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+	for _, col := range columns {
+		if v.exclude != nil && v.exclude(table, col) {
+			excluded = append(excluded, col)
+			continue
+		}
+		compared = append(compared, col)
+	}
+	return key, compared, excluded, nil
+}
+
+// diffChunk compares the row hashes of a chunk on both sides
+func (v *Verifier) diffChunk(ctx context.Context, q *chunkQueries, c chunk) ([]RowDiff, error) {
+	remote, err := q.rowHashes(ctx, v.remoteDB, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash remote rows: %w", err)
+	}
+	local, err := q.rowHashes(ctx, v.localDB, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash local rows: %w", err)
+	}
+
+	var diffs []RowDiff
+	for key, hash := range remote {
+		localHash, ok := local[key]
+		switch {
+		case !ok:
+			diffs = append(diffs, RowDiff{Key: key, Kind: RowMissing})
+		case localHash != hash:
+			diffs = append(diffs, RowDiff{Key: key, Kind: RowChanged})
+		}
+	}
+	for key := range local {
+		if _, ok := remote[key]; !ok {
+			diffs = append(diffs, RowDiff{Key: key, Kind: RowExtra})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	return diffs, nil
+}
+
+// getChunks splits a table into ranges of about chunkSize rows on the first
+// primary key column of the remote table. The first and last ranges are open
+// so local rows outside the remote key range are compared too.
+func (v *Verifier) getChunks(ctx context.Context, table, column string) ([]chunk, error) {
+	query := fmt.Sprintf(`
+		SELECT k FROM (
+			SELECT %[1]s AS k, row_number() OVER (ORDER BY %[1]s) AS n FROM %[2]s
+		) s
+		WHERE n > 1 AND (n - 1) %% $1 = 0
+		ORDER BY k
+	`, quoteIdent(column), quoteIdent(table))
+
+	rows, err := v.remoteDB.QueryContext(ctx, query, v.chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bounds []interface{}
+	for rows.Next() {
+		var b interface{}
+		if err := rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		if raw, ok := b.([]byte); ok {
+			b = string(raw)
+		}
+		bounds = append(bounds, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	chunks := make([]chunk, 0, len(bounds)+1)
+	var from interface{}
+	for _, b := range bounds {
+		chunks = append(chunks, chunk{from: from, to: b})
+		from = b
+	}
+	return append(chunks, chunk{from: from}), nil
+}
+
+// getPrimaryKey returns the primary key columns of a remote table in order
+func (v *Verifier) getPrimaryKey(ctx context.Context, table string) ([]string, error) {
+	query := `
+		SELECT a.attname
+		FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY (i.indkey)
+		WHERE i.indrelid = to_regclass('public.' || quote_ident($1)) AND i.indisprimary
+		ORDER BY array_position(i.indkey::int2[], a.attnum)
+	`
+
+	rows, err := v.remoteDB.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// chunkQueries builds the checksum queries of a table
+type chunkQueries struct {
+	table   string
+	key     string // ORDER BY list of the primary key
+	first   string // first primary key column, the chunk boundary
+	rowKey  string // primary key as a row literal
+	rowHash string // md5 of the compared columns
+
+	keyText     string // primary key columns as text
+	columnsText string // compared columns as text
+}
+
+func newChunkQueries(table string, key, columns []string) *chunkQueries {
+	quotedKey := make([]string, len(key))
+	for i, col := range key {
+		quotedKey[i] = quoteIdent(col)
+	}
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quoteIdent(col)
+	}
+	asText := func(cols []string) string {
+		out := make([]string, len(cols))
+		for i, col := range cols {
+			out[i] = col + "::text"
+		}
+		return strings.Join(out, ", ")
+	}
+
+	return &chunkQueries{
+		table:   quoteIdent(table),
+		key:     strings.Join(quotedKey, ", "),
+		first:   quotedKey[0],
+		rowKey:  fmt.Sprintf("ROW(%s)::text", strings.Join(quotedKey, ", ")),
+		rowHash: fmt.Sprintf("md5(ROW(%s)::text)", strings.Join(quoted, ", ")),
+
+		keyText:     asText(quotedKey),
+		columnsText: asText(quoted),
+	}
+}
+
+// where returns the condition and arguments selecting the rows of a chunk
+func (q *chunkQueries) where(c chunk) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if c.from != nil {
+		args = append(args, c.from)
+		conds = append(conds, fmt.Sprintf("%s >= $%d", q.first, len(args)))
+	}
+	if c.to != nil {
+		args = append(args, c.to)
+		conds = append(conds, fmt.Sprintf("%s < $%d", q.first, len(args)))
+	}
+	if len(conds) == 0 {
+		return "TRUE", nil
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// queryText runs a query reading values as text in a read-only transaction
+// with the text settings pinned
+func queryText(ctx context.Context, db *sql.DB, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, setting := range textSettings {
+		if _, err := tx.ExecContext(ctx, setting); err != nil {
+			return err
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checksum returns the row count and hash of a chunk
+func (q *chunkQueries) checksum(ctx context.Context, db *sql.DB, c chunk) (string, error) {
+	where, args := q.where(c)
+	query := fmt.Sprintf("SELECT count(*) || ':' || coalesce(md5(string_agg(%s, '' ORDER BY %s)), '') FROM %s WHERE %s",
+		q.rowHash, q.key, q.table, where)
+
+	var sum string
+	err := queryText(ctx, db, query, args, func(rows *sql.Rows) error {
+		return rows.Scan(&sum)
+	})
+	return sum, err
+}
+
+// rowHashes returns the hash of every row of a chunk by primary key
+func (q *chunkQueries) rowHashes(ctx context.Context, db *sql.DB, c chunk) (map[string]string, error) {
+	where, args := q.where(c)
+	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s", q.rowKey, q.rowHash, q.table, where)
+
+	hashes := make(map[string]string)
+	err := queryText(ctx, db, query, args, func(rows *sql.Rows) error {
+		var key, hash string
+		if err := rows.Scan(&key, &hash); err != nil {
+			return err
+		}
+		hashes[key] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("Hashed chunk rows", zap.String("table", q.table), zap.Int("rows", len(hashes)))
+	return hashes, nil
+}
@@ -0,0 +1,488 @@
+package verifier
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/thien/database-migration-tool/internal/logger"
+	"go.uber.org/zap"
+)
+
+// TableDiff lists the rows of a table that differ between the source (remote)
+// and the target (local) database. Values are in their text representation,
+// nil for NULL.
+type TableDiff struct {
+	Table     string      `json:"table"`
+	Key       []string    `json:"key"`
+	Columns   []string    `json:"columns"`
+	Excluded  []string    `json:"excluded,omitempty"`
+	Missing   []Row       `json:"missing,omitempty"` // in the source only
+	Extra     []Row       `json:"extra,omitempty"`   // in the target only
+	Changed   []RowChange `json:"changed,omitempty"`
+	Truncated bool        `json:"truncated,omitempty"` // stopped at the limit
+	Error     error       `json:"-"`
+
+	// What the patch needs to write the rows back
+	Generated  []string `json:"generated,omitempty"`  // computed columns, never written
+	Identity   bool     `json:"identity,omitempty"`   // has a GENERATED ALWAYS identity column
+	References []string `json:"references,omitempty"` // tables referenced by foreign keys
+}
+
+// Row is a row by primary key with the values of the compared columns
+type Row struct {
+	Key    []*string `json:"key"`
+	Values []*string `json:"values"`
+}
+
+// RowChange is a row present on both sides with differing columns
+type RowChange struct {
+	Key     []*string      `json:"key"`
+	Columns []ColumnChange `json:"columns"`
+}
+
+// ColumnChange holds the values of a column on both sides
+type ColumnChange struct {
+	Column string  `json:"column"`
+	Source *string `json:"source"`
+	Target *string `json:"target"`
+}
+
+// Differences returns the number of differing rows
+func (d *TableDiff) Differences() int {
+	return len(d.Missing) + len(d.Extra) + len(d.Changed)
+}
+
+// DiffAll compares the given tables row by row. Only the chunks whose
+// checksums differ are read in full. At most limit differences are collected
+// per table, 0 means no limit.
+func (v *Verifier) DiffAll(ctx context.Context, tables []string, limit int) []TableDiff {
+	if v.chunkSize == 0 {
+		v.chunkSize = 10000
+	}
+	logger.Info("Starting row diff", zap.Int("table_count", len(tables)))
+
+	// The patch writes parents before children
+	references := make(map[string][]string)
+	fks, err := foreignKeys(ctx, v.remoteDB)
+	if err != nil {
+		logger.Warn("Failed to get foreign keys, the patch is not ordered by them", zap.Error(err))
+	}
+	for _, fk := range fks {
+		if fk.parent != fk.table {
+			references[fk.table] = append(references[fk.table], fk.parent)
+		}
+	}
+
+	var diffs []TableDiff
+	for _, table := range tables {
+		diff := TableDiff{Table: table, References: references[table]}
+		if err := v.diffTable(ctx, &diff, limit); err != nil {
+			diff.Error = err
+			logger.Error("Diff error", zap.String("table", table), zap.Error(err))
+		} else {
+			logger.Info("Table compared", zap.String("table", table), zap.Int("differences", diff.Differences()))
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// diffTable fills the differences of one table
+func (v *Verifier) diffTable(ctx context.Context, diff *TableDiff, limit int) error {
+	key, compared, excluded, err := v.comparedColumns(ctx, diff.Table)
+	if err != nil {
+		return err
+	}
+	diff.Key, diff.Columns, diff.Excluded = key, compared, excluded
+	if diff.Generated, diff.Identity, err = v.generatedColumns(ctx, diff.Table); err != nil {
+		return fmt.Errorf("failed to get generated columns: %w", err)
+	}
+
+	chunks, err := v.getChunks(ctx, diff.Table, key[0])
+	if err != nil {
+		return fmt.Errorf("failed to split table: %w", err)
+	}
+
+	q := newChunkQueries(diff.Table, key, compared)
+	for _, c := range chunks {
+		source, err := q.checksum(ctx, v.remoteDB, c)
+		if err != nil {
+			return fmt.Errorf("failed to checksum source chunk: %w", err)
+		}
+		target, err := q.checksum(ctx, v.localDB, c)
+		if err != nil {
+			return fmt.Errorf("failed to checksum target chunk: %w", err)
+		}
+		if source == target {
+			continue
+		}
+
+		if err := v.diffRows(ctx, q, c, diff, limit); err != nil {
+			return err
+		}
+		if diff.Truncated {
+			return nil
+		}
+	}
+	return nil
+}
+
+// generatedColumns returns the stored generated columns of a remote table
+// and whether it has a GENERATED ALWAYS identity column
+func (v *Verifier) generatedColumns(ctx context.Context, table string) ([]string, bool, error) {
+	query := `
+		SELECT column_name, is_generated = 'ALWAYS',
+			is_identity = 'YES' AND identity_generation = 'ALWAYS'
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = $1
+		ORDER BY ordinal_position
+	`
+
+	rows, err := v.remoteDB.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var generated []string
+	var identity bool
+	for rows.Next() {
+		var column string
+		var isGenerated, isIdentity bool
+		if err := rows.Scan(&column, &isGenerated, &isIdentity); err != nil {
+			return nil, false, err
+		}
+		if isGenerated {
+			generated = append(generated, column)
+		}
+		identity = identity || isIdentity
+	}
+	return generated, identity, rows.Err()
+}
+
+// diffRows compares the rows of a chunk column by column
+func (v *Verifier) diffRows(ctx context.Context, q *chunkQueries, c chunk, diff *TableDiff, limit int) error {
+	source, order, err := q.rows(ctx, v.remoteDB, c, len(diff.Key), len(diff.Columns))
+	if err != nil {
+		return fmt.Errorf("failed to read source rows: %w", err)
+	}
+	target, targetOrder, err := q.rows(ctx, v.localDB, c, len(diff.Key), len(diff.Columns))
+	if err != nil {
+		return fmt.Errorf("failed to read target rows: %w", err)
+	}
+
+	full := func() bool {
+		if limit > 0 && diff.Differences() >= limit {
+			diff.Truncated = true
+			return true
+		}
+		return false
+	}
+
+	for _, k := range order {
+		if full() {
+			return nil
+		}
+		s := source[k]
+		t, ok := target[k]
+		if !ok {
+			diff.Missing = append(diff.Missing, s)
+			continue
+		}
+
+		var changes []ColumnChange
+		for i, col := range diff.Columns {
+			if !equalValues(s.Values[i], t.Values[i]) {
+				changes = append(changes, ColumnChange{Column: col, Source: s.Values[i], Target: t.Values[i]})
+			}
+		}
+		if len(changes) > 0 {
+			diff.Changed = append(diff.Changed, RowChange{Key: s.Key, Columns: changes})
+		}
+	}
+
+	for _, k := range targetOrder {
+		if _, ok := source[k]; ok {
+			continue
+		}
+		if full() {
+			return nil
+		}
+		diff.Extra = append(diff.Extra, target[k])
+	}
+	return nil
+}
+
+// rows reads the key and compared columns of a chunk as text, by row key
+func (q *chunkQueries) rows(ctx context.Context, db *sql.DB, c chunk, keyWidth, width int) (map[string]Row, []string, error) {
+	where, args := q.where(c)
+	query := fmt.Sprintf("SELECT %s, %s, %s FROM %s WHERE %s ORDER BY %s",
+		q.rowKey, q.keyText, q.columnsText, q.table, where, q.key)
+
+	byKey := make(map[string]Row)
+	var order []string
+	err := queryText(ctx, db, query, args, func(rows *sql.Rows) error {
+		var rowKey string
+		values := make([]sql.NullString, keyWidth+width)
+		ptrs := make([]interface{}, len(values)+1)
+		ptrs[0] = &rowKey
+		for i := range values {
+			ptrs[i+1] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+
+		row := Row{Key: make([]*string, keyWidth), Values: make([]*string, width)}
+		for i, v := range values {
+			var s *string
+			if v.Valid {
+				s = &v.String
+			}
+			if i < keyWidth {
+				row.Key[i] = s
+			} else {
+				row.Values[i-keyWidth] = s
+			}
+		}
+		byKey[rowKey] = row
+		order = append(order, rowKey)
+		return nil
+	})
+	return byKey, order, err
+}
+
+// equalValues compares two nullable text values
+func equalValues(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// GenerateDiffReport renders the differences as tables with the source and
+// target values side by side
+func (v *Verifier) GenerateDiffReport(diffs []TableDiff) string {
+	var report string
+	report += "\n========================================\n"
+	report += "            ROW DIFF REPORT              \n"
+	report += "========================================\n"
+
+	total := 0
+	errors := 0
+	for _, d := range diffs {
+		if d.Error != nil {
+			errors++
+			report += fmt.Sprintf("\n✗ %s - ERROR: %s\n", d.Table, d.Error.Error())
+			continue
+		}
+		total += d.Differences()
+		if d.Differences() == 0 {
+			report += fmt.Sprintf("\n✓ %s - identical\n", d.Table)
+			continue
+		}
+
+		report += fmt.Sprintf("\n✗ %s - %d missing, %d extra, %d changed\n", d.Table, len(d.Missing), len(d.Extra), len(d.Changed))
+		keyName := strings.Join(d.Key, ", ")
+		for _, r := range d.Missing {
+			report += fmt.Sprintf("  - missing in target  %s = %s\n", keyName, formatKey(r.Key))
+		}
+		for _, r := range d.Extra {
+			report += fmt.Sprintf("  + extra in target    %s = %s\n", keyName, formatKey(r.Key))
+		}
+		for _, r := range d.Changed {
+			report += fmt.Sprintf("  ~ changed           %s = %s\n", keyName, formatKey(r.Key))
+			report += fmt.Sprintf("      %-24s %-30s %-30s\n", "COLUMN", "SOURCE", "TARGET")
+			for _, c := range r.Columns {
+				report += fmt.Sprintf("      %-24s %-30s %-30s\n", c.Column, formatValue(c.Source), formatValue(c.Target))
+			}
+		}
+		if d.Truncated {
+			report += "  ... limit reached, more rows differ\n"
+		}
+		if len(d.Excluded) > 0 {
+			report += fmt.Sprintf("  (not compared: %s)\n", strings.Join(d.Excluded, ", "))
+		}
+	}
+
+	report += "\n========================================\n"
+	report += fmt.Sprintf("Total Tables:    %d\n", len(diffs))
+	report += fmt.Sprintf("Differing Rows:  %d\n", total)
+	report += fmt.Sprintf("Errors:          %d\n", errors)
+	report += "========================================\n"
+
+	return report
+}
+
+// DiffJSON renders the differences as JSON
+func DiffJSON(diffs []TableDiff) ([]byte, error) {
+	type tableJSON struct {
+		TableDiff
+		Error string `json:"error,omitempty"`
+	}
+	out := make([]tableJSON, len(diffs))
+	for i, d := range diffs {
+		out[i] = tableJSON{TableDiff: d}
+		if d.Error != nil {
+			out[i].Error = d.Error.Error()
+		}
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// DiffPatch renders the differences as SQL statements that make the target
+// equal to the source. Excluded columns are left out of the INSERTs, so they
+// get their defaults, and generated columns are left to the target. Rows are
+// deleted children first and written parents first; deferrable constraints
+// are only checked at the end.
+func DiffPatch(diffs []TableDiff) string {
+	var b strings.Builder
+	b.WriteString("-- Patch generated by the diff command: applying it to the target\n")
+	b.WriteString("-- makes the compared columns equal to the source.\n")
+	b.WriteString("BEGIN;\n")
+	b.WriteString("SET CONSTRAINTS ALL DEFERRED;\n")
+
+	ordered := patchOrder(diffs)
+	for i := len(ordered) - 1; i >= 0; i-- {
+		d := ordered[i]
+		if d.Error != nil || len(d.Extra) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n-- %s: rows only in the target\n", d.Table)
+		for _, r := range d.Extra {
+			fmt.Fprintf(&b, "DELETE FROM %s WHERE %s;\n", quoteIdent(d.Table), keyCondition(d.Key, r.Key))
+		}
+	}
+
+	for _, d := range ordered {
+		if d.Error != nil {
+			fmt.Fprintf(&b, "\n-- %s: skipped, %s\n", d.Table, d.Error.Error())
+			continue
+		}
+		if d.Differences() == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n-- %s\n", d.Table)
+		if d.Truncated {
+			b.WriteString("-- WARNING: limit reached, this patch is incomplete\n")
+		}
+		if len(d.Excluded) > 0 {
+			fmt.Fprintf(&b, "-- not compared: %s\n", strings.Join(d.Excluded, ", "))
+		}
+		table := quoteIdent(d.Table)
+		generated := make(map[string]bool, len(d.Generated))
+		for _, col := range d.Generated {
+			generated[col] = true
+		}
+
+		var columns []string
+		for _, col := range d.Columns {
+			if !generated[col] {
+				columns = append(columns, quoteIdent(col))
+			}
+		}
+		overriding := ""
+		if d.Identity {
+			overriding = " OVERRIDING SYSTEM VALUE"
+		}
+		for _, r := range d.Missing {
+			var values []string
+			for i, v := range r.Values {
+				if !generated[d.Columns[i]] {
+					values = append(values, sqlLiteral(v))
+				}
+			}
+			fmt.Fprintf(&b, "INSERT INTO %s (%s)%s VALUES (%s);\n", table, strings.Join(columns, ", "), overriding, strings.Join(values, ", "))
+		}
+
+		for _, r := range d.Changed {
+			var sets []string
+			for _, c := range r.Columns {
+				if !generated[c.Column] {
+					sets = append(sets, fmt.Sprintf("%s = %s", quoteIdent(c.Column), sqlLiteral(c.Source)))
+				}
+			}
+			if len(sets) == 0 {
+				// Only generated columns differ, they follow the others
+				continue
+			}
+			fmt.Fprintf(&b, "UPDATE %s SET %s WHERE %s;\n", table, strings.Join(sets, ", "), keyCondition(d.Key, r.Key))
+		}
+	}
+
+	b.WriteString("\nCOMMIT;\n")
+	return b.String()
+}
+
+// patchOrder sorts the diffs so that referenced tables come before the
+// tables referencing them. Cycles keep the order in which they are met.
+func patchOrder(diffs []TableDiff) []TableDiff {
+	byName := make(map[string]int, len(diffs))
+	for i, d := range diffs {
+		byName[d.Table] = i
+	}
+
+	ordered := make([]TableDiff, 0, len(diffs))
+	visited := make(map[string]bool, len(diffs))
+	var visit func(d TableDiff)
+	visit = func(d TableDiff) {
+		if visited[d.Table] {
+			return
+		}
+		visited[d.Table] = true
+		for _, parent := range d.References {
+			if i, ok := byName[parent]; ok {
+				visit(diffs[i])
+			}
+		}
+		ordered = append(ordered, d)
+	}
+	for _, d := range diffs {
+		visit(d)
+	}
+	return ordered
+}
+
+// keyCondition matches a row by primary key
+func keyCondition(columns []string, values []*string) string {
+	conds := make([]string, len(columns))
+	for i, col := range columns {
+		conds[i] = fmt.Sprintf("%s = %s", quoteIdent(col), sqlLiteral(values[i]))
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// sqlLiteral quotes a text value; PostgreSQL casts it to the column type
+func sqlLiteral(v *string) string {
+	if v == nil {
+		return "NULL"
+	}
+	return "'" + strings.ReplaceAll(*v, "'", "''") + "'"
+}
+
+// formatKey renders a primary key value, e.g. 42 or (7, 3)
+func formatKey(values []*string) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = formatValue(v)
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// formatValue renders a value for the report, truncating long text
+func formatValue(v *string) string {
+	if v == nil {
+		return "NULL"
+	}
+	if r := []rune(*v); len(r) > 30 {
+		return string(r[:27]) + "..."
+	}
+	return *v
+}
@@ -0,0 +1,54 @@
+package verifier
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffPatch(t *testing.T) {
+	str := func(s string) *string { return &s }
+	diffs := []TableDiff{
+		{
+			Table:      "orders",
+			Key:        []string{"id"},
+			Columns:    []string{"id", "user_id", "total", "total_tax"},
+			Generated:  []string{"total_tax"},
+			References: []string{"users"},
+			Missing:    []Row{{Key: []*string{str("5")}, Values: []*string{str("5"), str("1"), str("10"), str("12")}}},
+			Extra:      []Row{{Key: []*string{str("6")}}},
+			Changed: []RowChange{
+				{Key: []*string{str("7")}, Columns: []ColumnChange{{Column: "total_tax", Source: str("2"), Target: str("3")}}},
+			},
+		},
+		{
+			Table:    "users",
+			Key:      []string{"id"},
+			Columns:  []string{"id", "name"},
+			Identity: true,
+			Missing:  []Row{{Key: []*string{str("1")}, Values: []*string{str("1"), str("O'Hara")}}},
+			Extra:    []Row{{Key: []*string{str("2")}}},
+		},
+	}
+
+	want := `BEGIN;
+SET CONSTRAINTS ALL DEFERRED;
+
+-- orders: rows only in the target
+DELETE FROM "orders" WHERE "id" = '6';
+
+-- users: rows only in the target
+DELETE FROM "users" WHERE "id" = '2';
+
+-- users
+INSERT INTO "users" ("id", "name") OVERRIDING SYSTEM VALUE VALUES ('1', 'O''Hara');
+
+-- orders
+INSERT INTO "orders" ("id", "user_id", "total") VALUES ('5', '1', '10');
+
+COMMIT;
+`
+	got := DiffPatch(diffs)
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("DiffPatch() =\n%s\nwant it to end with\n%s", got, want)
+	}
+}
@@ -0,0 +1,178 @@
+package verifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+// HistoryRun is a verification run as recorded in the history
+type HistoryRun struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Source      string         `json:"source"`
+	Target      string         `json:"target"`
+	OK          bool           `json:"ok"`               // passed the verification policy and did not drift from the baseline
+	Filter      []string       `json:"filter,omitempty"` // the tables selected, empty when every table was verified
+	Tables      []HistoryTable `json:"tables"`
+}
+
+// HistoryTable is the recorded result of one table
+type HistoryTable struct {
+	Table           string             `json:"table"`
+	RemoteRows      int64              `json:"remote_rows"`
+	LocalRows       int64              `json:"local_rows"`
+	Estimated       bool               `json:"estimated,omitempty"`
+	Match           bool               `json:"match"`
+	Checksum        string             `json:"checksum,omitempty"`
+	Chunks          int                `json:"chunks,omitempty"`
+	ChunkMismatches int                `json:"chunk_mismatches,omitempty"`
+	RowsDiffering   int64              `json:"rows_differing,omitempty"`
+	Profile         *ProfileComparison `json:"profile,omitempty"`
+	Error           string             `json:"error,omitempty"`
+}
+
+// NewHistoryRun records verification results
+func NewHistoryRun(source, target string, filter []string, ok bool, results []VerificationResult) *HistoryRun {
+	run := &HistoryRun{
+		GeneratedAt: time.Now(),
+		Source:      source,
+		Target:      target,
+		OK:          ok,
+		Filter:      filter,
+	}
+	for _, r := range results {
+		t := HistoryTable{
+			Table:           r.Table,
+			RemoteRows:      r.RemoteRows,
+			LocalRows:       r.LocalRows,
+			Estimated:       r.Estimated,
+			Match:           r.Match,
+			Checksum:        r.Checksum,
+			Chunks:          r.Chunks,
+			ChunkMismatches: r.ChunkMismatches,
+			RowsDiffering:   r.RowsDiffering,
+			Profile:         r.Profile,
+		}
+		if r.Error != nil {
+			t.Error = r.Error.Error()
+		}
+		run.Tables = append(run.Tables, t)
+	}
+	return run
+}
+
+// Table returns the recorded result of a table
+func (r *HistoryRun) Table(name string) (HistoryTable, bool) {
+	for _, t := range r.Tables {
+		if t.Table == name {
+			return t, true
+		}
+	}
+	return HistoryTable{}, false
+}
+
+// covers reports whether a run can serve as the baseline of a run limited
+// to filter
+func (r *HistoryRun) covers(filter []string) bool {
+	if len(filter) == 0 {
+		return len(r.Filter) == 0
+	}
+	for _, name := range filter {
+		if _, ok := r.Table(name); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// History stores verification runs as JSON files in a directory, one per
+// run, named so they sort by time
+type History struct {
+	config *config.HistoryConfig
+}
+
+// NewHistory creates a history store
+func NewHistory(cfg *config.HistoryConfig) *History {
+	return &History{config: cfg}
+}
+
+// Save records a run, drops the runs beyond the configured number to keep,
+// and returns the path of the new file
+func (h *History) Save(run *HistoryRun) (string, error) {
+	if err := os.MkdirAll(h.config.Dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create history directory: %w", err)
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(h.config.Dir, fmt.Sprintf("run-%s.json", run.GeneratedAt.UTC().Format("20060102-150405.000")))
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write history: %w", err)
+	}
+
+	if h.config.Keep > 0 {
+		files, err := h.files()
+		if err != nil {
+			return file, err
+		}
+		for len(files) > h.config.Keep {
+			if err := os.Remove(files[0]); err != nil {
+				return file, fmt.Errorf("failed to prune history: %w", err)
+			}
+			files = files[1:]
+		}
+	}
+	return file, nil
+}
+
+// LastGood returns the most recent run between the same databases that
+// passed verification and covers the tables of filter, nil when there is
+// none. Without a filter only runs of every table qualify, so a run limited
+// to a few tables never becomes the baseline of a full run.
+func (h *History) LastGood(source, target string, filter []string) (*HistoryRun, error) {
+	files, err := h.files()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(files[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		var run HistoryRun
+		if err := json.Unmarshal(data, &run); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", files[i], err)
+		}
+		if run.OK && run.Source == source && run.Target == target && run.covers(filter) {
+			return &run, nil
+		}
+	}
+	return nil, nil
+}
+
+// files returns the run files of the history, oldest first
+func (h *History) files() ([]string, error) {
+	entries, err := os.ReadDir(h.config.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history: %w", err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), "run-") && strings.HasSuffix(e.Name(), ".json") {
+			files = append(files, filepath.Join(h.config.Dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,376 @@
+package verifier
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/thien/database-migration-tool/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Kinds of audited constraints
+const (
+	ConstraintForeignKey = "foreign_key"
+	ConstraintUnique     = "unique"
+)
+
+// ConstraintResult reports the rows of the local database violating a
+// foreign key or unique key
+type ConstraintResult struct {
+	Table      string
+	Constraint string
+	Kind       string
+	Columns    []string
+	References string   // referenced table(columns) of a foreign key
+	Violations int64    // orphan rows, or duplicated key values
+	Samples    []string // the first violating keys
+	Error      error
+}
+
+// foreignKey is a foreign key of the local database
+type foreignKey struct {
+	name, table, parent string
+	columns, parentCols []string
+}
+
+// uniqueKey is a unique index; enforced when the index is valid
+type uniqueKey struct {
+	name, table string
+	columns     []string
+	valid       bool
+}
+
+// AuditIntegrity checks the local database for rows a constrained load would
+// have rejected: child rows whose parent is missing, for every foreign key of
+// either database, and duplicated values of the unique keys of the source that the local
+// database does not enforce. Only constraints of the given tables are checked.
+func (v *Verifier) AuditIntegrity(ctx context.Context, tables []string, samples int) ([]ConstraintResult, error) {
+	logger.Info("Auditing local referential integrity", zap.Int("table_count", len(tables)))
+
+	include := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		include[t] = true
+	}
+
+	fks, err := v.auditedForeignKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := v.unenforcedUniqueKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ConstraintResult
+	for _, fk := range fks {
+		if !include[fk.table] {
+			continue
+		}
+		result := ConstraintResult{
+			Table:      fk.table,
+			Constraint: fk.name,
+			Kind:       ConstraintForeignKey,
+			Columns:    fk.columns,
+			References: fmt.Sprintf("%s(%s)", fk.parent, strings.Join(fk.parentCols, ", ")),
+		}
+		result.Violations, result.Samples, result.Error = v.findOrphans(ctx, fk, samples)
+		logConstraint(result)
+		results = append(results, result)
+	}
+	for _, key := range keys {
+		if !include[key.table] {
+			continue
+		}
+		result := ConstraintResult{
+			Table:      key.table,
+			Constraint: key.name,
+			Kind:       ConstraintUnique,
+			Columns:    key.columns,
+		}
+		result.Violations, result.Samples, result.Error = v.findDuplicates(ctx, key, samples)
+		logConstraint(result)
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Table < results[j].Table })
+	return results, nil
+}
+
+func logConstraint(r ConstraintResult) {
+	switch {
+	case r.Error != nil:
+		logger.Error("Integrity check failed",
+			zap.String("table", r.Table),
+			zap.String("constraint", r.Constraint),
+			zap.Error(r.Error))
+	case r.Violations > 0:
+		logger.Warn("Integrity violations found",
+			zap.String("table", r.Table),
+			zap.String("constraint", r.Constraint),
+			zap.String("kind", r.Kind),
+			zap.Int64("violations", r.Violations))
+	}
+}
+
+// foreignKeys returns the foreign keys of the public schema
+func foreignKeys(ctx context.Context, db *sql.DB) ([]foreignKey, error) {
+	query := `
+		SELECT c.conname, cl.relname, rf.relname,
+			array_agg(a.attname::text ORDER BY k.n), array_agg(af.attname::text ORDER BY k.n)
+		FROM pg_constraint c
+		JOIN pg_class cl ON cl.oid = c.conrelid
+		JOIN pg_namespace ns ON ns.oid = cl.relnamespace
+		JOIN pg_class rf ON rf.oid = c.confrelid
+		CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(col, refcol, n)
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.col
+		JOIN pg_attribute af ON af.attrelid = c.confrelid AND af.attnum = k.refcol
+		WHERE c.contype = 'f' AND ns.nspname = 'public'
+		GROUP BY c.conname, cl.relname, rf.relname
+		ORDER BY cl.relname, c.conname
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks []foreignKey
+	for rows.Next() {
+		var fk foreignKey
+		if err := rows.Scan(&fk.name, &fk.table, &fk.parent, pq.Array(&fk.columns), pq.Array(&fk.parentCols)); err != nil {
+			return nil, err
+		}
+		fks = append(fks, fk)
+	}
+	return fks, rows.Err()
+}
+
+// uniqueKeys returns the unique indexes on plain columns of the public
+// schema, primary keys and unique constraints included. Partial and
+// expression indexes are left out.
+func uniqueKeys(ctx context.Context, db *sql.DB) ([]uniqueKey, error) {
+	query := `
+		SELECT c.relname, i.relname, x.indisvalid, array_agg(a.attname::text ORDER BY k.n)
+		FROM pg_index x
+		JOIN pg_class c ON c.oid = x.indrelid
+		JOIN pg_class i ON i.oid = x.indexrelid
+		JOIN pg_namespace ns ON ns.oid = c.relnamespace
+		CROSS JOIN LATERAL unnest(x.indkey::int2[]) WITH ORDINALITY AS k(attnum, n)
+		JOIN pg_attribute a ON a.attrelid = x.indrelid AND a.attnum = k.attnum
+		WHERE x.indisunique AND x.indpred IS NULL AND NOT (0 = ANY (x.indkey::int2[]))
+			AND k.n <= x.indnkeyatts AND ns.nspname = 'public'
+		GROUP BY c.relname, i.relname, x.indisvalid
+		ORDER BY c.relname, i.relname
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []uniqueKey
+	for rows.Next() {
+		var key uniqueKey
+		if err := rows.Scan(&key.table, &key.name, &key.valid, pq.Array(&key.columns)); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// auditedForeignKeys returns the local foreign keys and those of the remote
+// database between tables that exist locally, e.g. dropped or deferred for
+// the load
+func (v *Verifier) auditedForeignKeys(ctx context.Context) ([]foreignKey, error) {
+	local, err := foreignKeys(ctx, v.localDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local foreign keys: %w", err)
+	}
+	remote, err := foreignKeys(ctx, v.remoteDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote foreign keys: %w", err)
+	}
+	localTables, err := v.getTables(ctx, v.localDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local tables: %w", err)
+	}
+	exists := make(map[string]bool, len(localTables))
+	for _, t := range localTables {
+		exists[t] = true
+	}
+
+	var fks []foreignKey
+	seen := make(map[string]bool)
+	for _, fk := range append(local, remote...) {
+		id := fk.table + "(" + strings.Join(fk.columns, ",") + ")" + fk.parent + "(" + strings.Join(fk.parentCols, ",") + ")"
+		if seen[id] || !exists[fk.table] || !exists[fk.parent] {
+			continue
+		}
+		seen[id] = true
+		fks = append(fks, fk)
+	}
+	return fks, nil
+}
+
+// unenforcedUniqueKeys returns the unique keys of the remote database that no
+// valid local unique index enforces, e.g. dropped or deferred for the load,
+// and the local unique indexes left invalid by a failed build
+func (v *Verifier) unenforcedUniqueKeys(ctx context.Context) ([]uniqueKey, error) {
+	remote, err := uniqueKeys(ctx, v.remoteDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote unique keys: %w", err)
+	}
+	local, err := uniqueKeys(ctx, v.localDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local unique keys: %w", err)
+	}
+	localTables, err := v.getTables(ctx, v.localDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local tables: %w", err)
+	}
+	exists := make(map[string]bool, len(localTables))
+	for _, t := range localTables {
+		exists[t] = true
+	}
+
+	enforced := make(map[string]bool)
+	for _, key := range local {
+		if key.valid {
+			enforced[key.table+"("+strings.Join(key.columns, ",")+")"] = true
+		}
+	}
+
+	var keys []uniqueKey
+	seen := make(map[string]bool)
+	for _, key := range append(local, remote...) {
+		id := key.table + "(" + strings.Join(key.columns, ",") + ")"
+		if enforced[id] || seen[id] || !exists[key.table] {
+			continue
+		}
+		seen[id] = true
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// findOrphans counts the local rows referencing a missing parent row. As with
+// MATCH SIMPLE, rows with a null in the key reference nothing.
+func (v *Verifier) findOrphans(ctx context.Context, fk foreignKey, samples int) (int64, []string, error) {
+	var notNull, join, key []string
+	for i, col := range fk.columns {
+		c := "c." + quoteIdent(col)
+		notNull = append(notNull, c+" IS NOT NULL")
+		join = append(join, fmt.Sprintf("p.%s = %s", quoteIdent(fk.parentCols[i]), c))
+		key = append(key, c)
+	}
+	from := fmt.Sprintf("FROM %s c WHERE %s AND NOT EXISTS (SELECT 1 FROM %s p WHERE %s)",
+		quoteIdent(fk.table), strings.Join(notNull, " AND "), quoteIdent(fk.parent), strings.Join(join, " AND "))
+
+	var count int64
+	if err := v.localDB.QueryRowContext(ctx, "SELECT count(*) "+from).Scan(&count); err != nil {
+		return 0, nil, fmt.Errorf("failed to count orphans: %w", err)
+	}
+	if count == 0 {
+		return 0, nil, nil
+	}
+
+	query := fmt.Sprintf("SELECT DISTINCT ROW(%s)::text %s LIMIT $1", strings.Join(key, ", "), from)
+	keys, err := v.sampleKeys(ctx, query, samples)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to sample orphans: %w", err)
+	}
+	return count, keys, nil
+}
+
+// findDuplicates counts the key values held by more than one local row.
+// Nulls are distinct, as in a unique index.
+func (v *Verifier) findDuplicates(ctx context.Context, key uniqueKey, samples int) (int64, []string, error) {
+	var notNull, cols []string
+	for _, col := range key.columns {
+		notNull = append(notNull, quoteIdent(col)+" IS NOT NULL")
+		cols = append(cols, quoteIdent(col))
+	}
+	groups := fmt.Sprintf("SELECT ROW(%s)::text AS key, count(*) AS n FROM %s WHERE %s GROUP BY %s HAVING count(*) > 1",
+		strings.Join(cols, ", "), quoteIdent(key.table), strings.Join(notNull, " AND "), strings.Join(cols, ", "))
+
+	var count int64
+	if err := v.localDB.QueryRowContext(ctx, "SELECT count(*) FROM ("+groups+") d").Scan(&count); err != nil {
+		return 0, nil, fmt.Errorf("failed to count duplicates: %w", err)
+	}
+	if count == 0 {
+		return 0, nil, nil
+	}
+
+	query := "SELECT key || ' x' || n FROM (" + groups + ") d ORDER BY n DESC LIMIT $1"
+	keys, err := v.sampleKeys(ctx, query, samples)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to sample duplicates: %w", err)
+	}
+	return count, keys, nil
+}
+
+// sampleKeys runs a query returning up to limit text values
+func (v *Verifier) sampleKeys(ctx context.Context, query string, limit int) ([]string, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	rows, err := v.localDB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// GenerateIntegrityReport generates a human-readable integrity audit report
+func (v *Verifier) GenerateIntegrityReport(results []ConstraintResult) string {
+	var report string
+	report += "\n========================================\n"
+	report += "      REFERENTIAL INTEGRITY REPORT       \n"
+	report += "========================================\n\n"
+
+	violated, errors := 0, 0
+	for _, r := range results {
+		target := fmt.Sprintf("%s.%s (%s)", r.Table, r.Constraint, strings.Join(r.Columns, ", "))
+		switch {
+		case r.Error != nil:
+			errors++
+			report += fmt.Sprintf("✗ %s - ERROR: %s\n", target, r.Error.Error())
+		case r.Violations == 0:
+			report += fmt.Sprintf("✓ %s\n", target)
+		case r.Kind == ConstraintForeignKey:
+			violated++
+			report += fmt.Sprintf("✗ %s - %d orphan rows, missing in %s\n", target, r.Violations, r.References)
+		default:
+			violated++
+			report += fmt.Sprintf("✗ %s - %d duplicated keys, not enforced locally\n", target, r.Violations)
+		}
+		for _, s := range r.Samples {
+			report += fmt.Sprintf("    %s\n", s)
+		}
+	}
+
+	report += "\n========================================\n"
+	report += fmt.Sprintf("Constraints:     %d\n", len(results))
+	report += fmt.Sprintf("Violated:        %d\n", violated)
+	report += fmt.Sprintf("Errors:          %d\n", errors)
+	report += "========================================\n"
+
+	return report
+}
@@ -0,0 +1,198 @@
+package verifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/thien/database-migration-tool/internal/logger"
+	"go.uber.org/zap"
+)
+
+// LeakResult reports a local column containing values of sensitive remote columns
+type LeakResult struct {
+	Table   string
+	Column  string
+	Matches int64
+	Sources []string // sensitive remote columns the leaked values came from
+}
+
+// valueHash is a truncated SHA-256 of a value; only hashes are kept in memory
+type valueHash [16]byte
+
+func hashValue(s string) valueHash {
+	sum := sha256.Sum256([]byte(s))
+	var h valueHash
+	copy(h[:], sum[:16])
+	return h
+}
+
+// CheckPIILeaks builds a hashed set of the values of every sensitive remote
+// column and reports local columns in which any of them appear. Values shorter
+// than minLength are ignored to avoid matching trivial values.
+func (v *Verifier) CheckPIILeaks(ctx context.Context, tables []string, isSensitive func(table, column string) bool, minLength int) ([]LeakResult, error) {
+	logger.Info("Checking local database for PII leaks", zap.Int("table_count", len(tables)))
+
+	sensitive := make(map[valueHash]string)
+	for _, table := range tables {
+		columns, err := v.getColumns(ctx, v.remoteDB, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get remote columns of %s: %w", table, err)
+		}
+		for _, col := range columns {
+			if !isSensitive(table, col) {
+				continue
+			}
+			if err := v.collectHashes(ctx, table, col, minLength, sensitive); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	logger.Info("Collected sensitive remote values", zap.Int("distinct_values", len(sensitive)))
+	if len(sensitive) == 0 {
+		return nil, nil
+	}
+
+	localTables, err := v.getTables(ctx, v.localDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local tables: %w", err)
+	}
+
+	var results []LeakResult
+	for _, table := range localTables {
+		columns, err := v.getColumns(ctx, v.localDB, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get local columns of %s: %w", table, err)
+		}
+		for _, col := range columns {
+			result, err := v.scanForLeaks(ctx, table, col, minLength, sensitive)
+			if err != nil {
+				return nil, err
+			}
+			if result.Matches > 0 {
+				logger.Warn("Sensitive values found in local database",
+					zap.String("table", table),
+					zap.String("column", col),
+					zap.Int64("matches", result.Matches),
+					zap.Strings("sources", result.Sources))
+				results = append(results, result)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// collectHashes adds the hashes of the distinct values of a remote column
+func (v *Verifier) collectHashes(ctx context.Context, table, column string, minLength int, into map[valueHash]string) error {
+	query := fmt.Sprintf(
+		"SELECT DISTINCT %s::text FROM %s WHERE %s IS NOT NULL AND length(%s::text) >= $1",
+		quoteIdent(column), quoteIdent(table), quoteIdent(column), quoteIdent(column),
+	)
+	rows, err := v.remoteDB.QueryContext(ctx, query, minLength)
+	if err != nil {
+		return fmt.Errorf("failed to read %s.%s: %w", table, column, err)
+	}
+	defer rows.Close()
+
+	source := table + "." + column
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return fmt.Errorf("failed to scan %s.%s: %w", table, column, err)
+		}
+		into[hashValue(value)] = source
+	}
+	return rows.Err()
+}
+
+// scanForLeaks counts the local values of a column that are in the sensitive set
+func (v *Verifier) scanForLeaks(ctx context.Context, table, column string, minLength int, sensitive map[valueHash]string) (LeakResult, error) {
+	result := LeakResult{Table: table, Column: column}
+
+	query := fmt.Sprintf(
+		"SELECT %s::text FROM %s WHERE %s IS NOT NULL AND length(%s::text) >= $1",
+		quoteIdent(column), quoteIdent(table), quoteIdent(column), quoteIdent(column),
+	)
+	rows, err := v.localDB.QueryContext(ctx, query, minLength)
+	if err != nil {
+		return result, fmt.Errorf("failed to read local %s.%s: %w", table, column, err)
+	}
+	defer rows.Close()
+
+	sources := make(map[string]bool)
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return result, fmt.Errorf("failed to scan local %s.%s: %w", table, column, err)
+		}
+		if source, ok := sensitive[hashValue(value)]; ok {
+			result.Matches++
+			sources[source] = true
+		}
+	}
+
+	for source := range sources {
+		result.Sources = append(result.Sources, source)
+	}
+	sort.Strings(result.Sources)
+
+	return result, rows.Err()
+}
+
+// getColumns returns the column names of a table
+func (v *Verifier) getColumns(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+	query := `
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = $1
+		ORDER BY ordinal_position
+	`
+
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+// GenerateLeakReport summarizes a PII leak check
+func (v *Verifier) GenerateLeakReport(results []LeakResult) string {
+	var report string
+	report += "\n========================================\n"
+	report += "          PII LEAK CHECK REPORT          \n"
+	report += "========================================\n\n"
+
+	if len(results) == 0 {
+		report += "✓ No sensitive remote values found in the local database\n"
+	}
+	for _, r := range results {
+		report += fmt.Sprintf("✗ %s.%s - %d values from %s\n", r.Table, r.Column, r.Matches, strings.Join(r.Sources, ", "))
+	}
+
+	report += "\n========================================\n"
+	report += fmt.Sprintf("Leaking Columns: %d\n", len(results))
+	report += "========================================\n"
+
+	return report
+}
+
+// quoteIdent quotes a SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
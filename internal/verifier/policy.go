@@ -0,0 +1,125 @@
+package verifier
+
+import (
+	"fmt"
+	"math"
+	"path"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+// Exit codes of the verify command. Errors share code 1 with every other
+// fatal error of the tool; only a policy failure exits with 2.
+const (
+	ExitOK       = 0
+	ExitError    = 1 // verification could not run for a table or the schema
+	ExitMismatch = 2 // differences beyond the configured tolerances
+)
+
+// Policy decides whether verification results pass
+type Policy struct {
+	config *config.VerifyConfig
+}
+
+// NewPolicy creates a policy from the verification settings
+func NewPolicy(cfg *config.VerifyConfig) *Policy {
+	return &Policy{config: cfg}
+}
+
+// Apply marks the mismatching tables whose differences are within tolerance.
+// Profile mismatches are already beyond the profile tolerances.
+func (p *Policy) Apply(results []VerificationResult) {
+	for i := range results {
+		r := &results[i]
+		if r.Error != nil || r.Match {
+			continue
+		}
+		profileMatch := r.Profile == nil || len(r.Profile.Mismatches) == 0
+		r.Tolerated = profileMatch && differingRows(r) <= p.tolerance(r.Table, r.RemoteRows)
+	}
+}
+
+// Evaluate returns the exit code for verification results and the reasons
+// the run fails. Apply must have been called on the results.
+func (p *Policy) Evaluate(results []VerificationResult, schemaDiffs []SchemaDifference, schemaErr error) (int, []string) {
+	var errors, mismatches []string
+
+	if schemaErr != nil {
+		errors = append(errors, fmt.Sprintf("schema: %v", schemaErr))
+	}
+	for _, d := range schemaDiffs {
+		if p.failsOnSchema(d.Severity) {
+			mismatches = append(mismatches, fmt.Sprintf("schema: %s %s %s", d.Category, d.Object, d.Kind))
+		}
+	}
+
+	for _, r := range results {
+		switch {
+		case r.Error != nil:
+			errors = append(errors, fmt.Sprintf("%s: %v", r.Table, r.Error))
+		case !r.Match && !r.Tolerated && r.Profile != nil && len(r.Profile.Mismatches) > 0:
+			mismatches = append(mismatches, fmt.Sprintf("%s: %d column statistics differ", r.Table, len(r.Profile.Mismatches)))
+		case !r.Match && !r.Tolerated:
+			mismatches = append(mismatches, fmt.Sprintf("%s: %d differing rows, %d tolerated",
+				r.Table, differingRows(&r), p.tolerance(r.Table, r.RemoteRows)))
+		}
+	}
+
+	reasons := append(errors, mismatches...)
+	switch {
+	case len(errors) > 0:
+		return ExitError, reasons
+	case len(mismatches) > 0:
+		return ExitMismatch, reasons
+	default:
+		return ExitOK, nil
+	}
+}
+
+// failsOnSchema reports whether a schema difference of a severity fails the run
+func (p *Policy) failsOnSchema(severity string) bool {
+	switch p.config.SchemaDrift {
+	case "never":
+		return false
+	case "warning":
+		return severity == SeverityError || severity == SeverityWarning
+	default:
+		return severity == SeverityError
+	}
+}
+
+// tolerance returns the number of differing rows allowed for a table: the
+// larger of the absolute and percentage tolerances, 0 for exact tables
+func (p *Policy) tolerance(table string, remoteRows int64) int64 {
+	for _, pattern := range p.config.Exact {
+		if ok, _ := path.Match(pattern, table); ok {
+			return 0
+		}
+	}
+
+	abs, pct := p.config.MaxRowDiff, p.config.MaxRowDiffPercent
+	for _, t := range p.config.Tolerances {
+		if ok, _ := path.Match(t.Table, table); ok {
+			abs, pct = t.MaxRowDiff, t.MaxRowDiffPercent
+			break
+		}
+	}
+
+	if byPercent := int64(math.Floor(float64(remoteRows) * pct / 100)); byPercent > abs {
+		return byPercent
+	}
+	return abs
+}
+
+// differingRows returns the number of rows that differ in a table: the row
+// count difference, or the rows found by checksums when larger
+func differingRows(r *VerificationResult) int64 {
+	n := r.RowDiff
+	if n < 0 {
+		n = -n
+	}
+	if r.RowsDiffering > n {
+		n = r.RowsDiffering
+	}
+	return n
+}
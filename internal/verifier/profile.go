@@ -0,0 +1,321 @@
+package verifier
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thien/database-migration-tool/internal/config"
+)
+
+// Profile statistics
+const (
+	StatNulls    = "nulls"
+	StatDistinct = "distinct"
+	StatMin      = "min"
+	StatMax      = "max"
+	StatMean     = "mean"
+)
+
+// numericTypes have a mean; orderedTypes also have a min and max
+var (
+	numericTypes = map[string]bool{
+		"smallint": true, "integer": true, "bigint": true,
+		"numeric": true, "real": true, "double precision": true,
+	}
+	orderedTypes = map[string]bool{
+		"text": true, "character varying": true, "character": true, "date": true,
+		"timestamp without time zone": true, "timestamp with time zone": true,
+		"time without time zone": true, "time with time zone": true,
+	}
+)
+
+// ColumnProfile holds the statistics of a column
+type ColumnProfile struct {
+	Column   string   `json:"column"`
+	Type     string   `json:"type"`
+	Nulls    int64    `json:"nulls"`
+	Distinct float64  `json:"distinct"` // planner estimate, -1 when the table was never analyzed
+	Min      *string  `json:"min,omitempty"`
+	Max      *string  `json:"max,omitempty"`
+	Mean     *float64 `json:"mean,omitempty"`
+}
+
+// TableProfile holds the statistics of a table
+type TableProfile struct {
+	Rows    int64           `json:"rows"`
+	Columns []ColumnProfile `json:"columns"`
+}
+
+// ProfileMismatch is a column statistic that differs beyond tolerance
+type ProfileMismatch struct {
+	Column string `json:"column"`
+	Stat   string `json:"stat"`
+	Remote string `json:"remote"`
+	Local  string `json:"local"`
+}
+
+// ProfileComparison holds both profiles of a table and how they differ
+type ProfileComparison struct {
+	Remote     TableProfile      `json:"remote"`
+	Local      TableProfile      `json:"local"`
+	Mismatches []ProfileMismatch `json:"mismatches,omitempty"`
+}
+
+// EnableProfiles makes verification compare column statistics instead of
+// checksums: one scan per table and side, cheap enough for large tables.
+// Columns for which exclude returns true (e.g. anonymized) are skipped.
+func (v *Verifier) EnableProfiles(cfg *config.ProfileConfig, exclude func(table, column string) bool) {
+	v.profile = cfg
+	v.exclude = exclude
+}
+
+// verifyProfile profiles a table on both sides and compares the profiles
+func (v *Verifier) verifyProfile(ctx context.Context, result *VerificationResult) error {
+	table := result.Table
+
+	columns, types, err := v.columnTypes(ctx, table)
+	if err != nil {
+		return fmt.Errorf("failed to get columns: %w", err)
+	}
+	var profiled, profiledTypes []string
+	for i, col := range columns {
+		if v.exclude != nil && v.exclude(table, col) {
+			result.Excluded = append(result.Excluded, col)
+			continue
+		}
+		profiled = append(profiled, col)
+		profiledTypes = append(profiledTypes, types[i])
+	}
+
+	// The local table was just written, refresh its planner statistics so
+	// the distinct estimates are comparable; the remote is left untouched
+	if _, err := v.localDB.ExecContext(ctx, "ANALYZE "+quoteIdent(table)); err != nil {
+		return fmt.Errorf("failed to analyze local table: %w", err)
+	}
+
+	remote, err := profileTable(ctx, v.remoteDB, table, profiled, profiledTypes)
+	if err != nil {
+		return fmt.Errorf("failed to profile remote table: %w", err)
+	}
+	local, err := profileTable(ctx, v.localDB, table, profiled, profiledTypes)
+	if err != nil {
+		return fmt.Errorf("failed to profile local table: %w", err)
+	}
+
+	result.Profile = &ProfileComparison{
+		Remote:     *remote,
+		Local:      *local,
+		Mismatches: compareProfiles(remote, local, v.profile),
+	}
+	return nil
+}
+
+// columnTypes returns the columns of a table with their data types
+func (v *Verifier) columnTypes(ctx context.Context, table string) ([]string, []string, error) {
+	query := `
+		SELECT column_name, data_type
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = $1
+		ORDER BY ordinal_position
+	`
+
+	rows, err := v.remoteDB.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var columns, types []string
+	for rows.Next() {
+		var column, dataType string
+		if err := rows.Scan(&column, &dataType); err != nil {
+			return nil, nil, err
+		}
+		columns = append(columns, column)
+		types = append(types, dataType)
+	}
+	return columns, types, rows.Err()
+}
+
+// profileTable computes the statistics of the given columns in one scan and
+// reads the distinct estimates from pg_stats
+func profileTable(ctx context.Context, db *sql.DB, table string, columns, types []string) (*TableProfile, error) {
+	selects := []string{"count(*)"}
+	for i, col := range columns {
+		c := quoteIdent(col)
+		selects = append(selects, fmt.Sprintf("count(%s)", c))
+		if numericTypes[types[i]] || orderedTypes[types[i]] {
+			selects = append(selects, fmt.Sprintf("min(%s)::text", c), fmt.Sprintf("max(%s)::text", c))
+		}
+		if numericTypes[types[i]] {
+			selects = append(selects, fmt.Sprintf("avg(%s)::float8", c))
+		}
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), quoteIdent(table))
+
+	profile := &TableProfile{Columns: make([]ColumnProfile, len(columns))}
+	counts := make([]int64, len(columns))
+	dest := []interface{}{&profile.Rows}
+	for i := range columns {
+		p := &profile.Columns[i]
+		p.Column, p.Type = columns[i], types[i]
+		dest = append(dest, &counts[i])
+		if numericTypes[types[i]] || orderedTypes[types[i]] {
+			dest = append(dest, &p.Min, &p.Max)
+		}
+		if numericTypes[types[i]] {
+			dest = append(dest, &p.Mean)
+		}
+	}
+	// min and max are compared as text, so they are read with the same
+	// pinned settings as the checksums
+	scan := func(rows *sql.Rows) error { return rows.Scan(dest...) }
+	if err := queryText(ctx, db, query, nil, scan); err != nil {
+		return nil, err
+	}
+
+	distinct, err := distinctEstimates(ctx, db, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read statistics: %w", err)
+	}
+	for i := range profile.Columns {
+		p := &profile.Columns[i]
+		p.Nulls = profile.Rows - counts[i]
+		p.Distinct = -1
+		if n, ok := distinct[p.Column]; ok {
+			if n < 0 {
+				// A negative n_distinct is a fraction of the rows
+				n = math.Round(-n * float64(profile.Rows))
+			}
+			p.Distinct = n
+		}
+	}
+	return profile, nil
+}
+
+// distinctEstimates returns the planner's distinct value estimates per column
+func distinctEstimates(ctx context.Context, db *sql.DB, table string) (map[string]float64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT attname, n_distinct
+		FROM pg_stats
+		WHERE schemaname = 'public' AND tablename = $1
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	estimates := make(map[string]float64)
+	for rows.Next() {
+		var column string
+		var n float64
+		if err := rows.Scan(&column, &n); err != nil {
+			return nil, err
+		}
+		estimates[column] = n
+	}
+	return estimates, rows.Err()
+}
+
+// compareProfiles lists the statistics that differ beyond the tolerances.
+// Null counts are compared in percent of the remote rows, distinct
+// estimates and means relative to the remote value; min and max must match.
+func compareProfiles(remote, local *TableProfile, tol *config.ProfileConfig) []ProfileMismatch {
+	localColumns := make(map[string]*ColumnProfile, len(local.Columns))
+	for i := range local.Columns {
+		localColumns[local.Columns[i].Column] = &local.Columns[i]
+	}
+
+	var mismatches []ProfileMismatch
+	for _, r := range remote.Columns {
+		l, ok := localColumns[r.Column]
+		if !ok {
+			continue
+		}
+		add := func(stat, remote, local string) {
+			mismatches = append(mismatches, ProfileMismatch{Column: r.Column, Stat: stat, Remote: remote, Local: local})
+		}
+
+		if exceeds(float64(r.Nulls), float64(l.Nulls), float64(remote.Rows), tol.NullTolerance) {
+			add(StatNulls, strconv.FormatInt(r.Nulls, 10), strconv.FormatInt(l.Nulls, 10))
+		}
+		if r.Distinct >= 0 && l.Distinct >= 0 && exceeds(r.Distinct, l.Distinct, r.Distinct, tol.DistinctTolerance) {
+			add(StatDistinct, formatStat(&r.Distinct), formatStat(&l.Distinct))
+		}
+		if !equalValues(r.Min, l.Min) {
+			add(StatMin, stringStat(r.Min), stringStat(l.Min))
+		}
+		if !equalValues(r.Max, l.Max) {
+			add(StatMax, stringStat(r.Max), stringStat(l.Max))
+		}
+		if (r.Mean == nil) != (l.Mean == nil) ||
+			r.Mean != nil && exceeds(*r.Mean, *l.Mean, math.Abs(*r.Mean), tol.MeanTolerance) {
+			add(StatMean, formatStat(r.Mean), formatStat(l.Mean))
+		}
+	}
+	return mismatches
+}
+
+// exceeds reports whether a and b differ by more than pct percent of base
+func exceeds(a, b, base, pct float64) bool {
+	return math.Abs(a-b) > base*pct/100
+}
+
+func stringStat(s *string) string {
+	if s == nil {
+		return "NULL"
+	}
+	return *s
+}
+
+func formatStat(f *float64) string {
+	if f == nil {
+		return "NULL"
+	}
+	return strconv.FormatFloat(*f, 'g', 10, 64)
+}
+
+// ProfileRun is the set of profiles of one verification run
+type ProfileRun struct {
+	GeneratedAt time.Time                     `json:"generated_at"`
+	Source      string                        `json:"source"`
+	Target      string                        `json:"target"`
+	Tables      map[string]*ProfileComparison `json:"tables"`
+}
+
+// SaveProfiles writes the profiles of the results to a timestamped JSON file
+// in dir, so statistics can be trended across runs, and returns its path
+func SaveProfiles(dir, source, target string, results []VerificationResult) (string, error) {
+	run := ProfileRun{
+		GeneratedAt: time.Now(),
+		Source:      source,
+		Target:      target,
+		Tables:      make(map[string]*ProfileComparison),
+	}
+	for _, r := range results {
+		if r.Profile != nil {
+			run.Tables[r.Table] = r.Profile
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, fmt.Sprintf("profile-%s.json", run.GeneratedAt.UTC().Format("20060102-150405")))
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write profiles: %w", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,112 @@
+package verifier
+
+import (
+	"time"
+
+	"github.com/thien/database-migration-tool/internal/report"
+)
+
+// BuildReport converts verification results into a structured report. The
+// schema check is left out when schemaErr and schemaDiffs are both nil.
+func (v *Verifier) BuildReport(schemaDiffs []SchemaDifference, schemaErr error, schemaTime time.Duration, results []VerificationResult) *report.Report {
+	r := &report.Report{
+		Title:       "Migration Verification Report",
+		GeneratedAt: time.Now(),
+		Tables:      []report.Table{},
+	}
+
+	if schemaDiffs != nil || schemaErr != nil {
+		r.Schema = &report.SchemaCheck{
+			Seconds:     schemaTime.Seconds(),
+			Differences: []report.SchemaDifference{},
+		}
+		if schemaErr != nil {
+			r.Schema.Error = schemaErr.Error()
+		}
+		for _, d := range schemaDiffs {
+			r.Schema.Differences = append(r.Schema.Differences, report.SchemaDifference{
+				Category: d.Category,
+				Object:   d.Object,
+				Kind:     d.Kind,
+				Severity: d.Severity,
+				Property: d.Property,
+				Source:   d.Remote,
+				Target:   d.Local,
+			})
+		}
+		r.Seconds += schemaTime.Seconds()
+	}
+
+	for _, res := range results {
+		t := report.Table{
+			Name:       res.Table,
+			Status:     report.StatusPassed,
+			SourceRows: res.RemoteRows,
+			TargetRows: res.LocalRows,
+			Estimated:  res.Estimated,
+			Seconds:    res.Duration.Seconds(),
+		}
+		switch {
+		case res.Error != nil:
+			t.Status = report.StatusError
+			t.Error = res.Error.Error()
+		case res.Tolerated:
+			t.Status = report.StatusTolerated
+		case !res.Match:
+			t.Status = report.StatusFailed
+		}
+
+		if v.chunkSize > 0 && !v.estimates && res.Error == nil {
+			t.Checksum = &report.Checksum{
+				Chunks:           res.Chunks,
+				MismatchedChunks: res.ChunkMismatches,
+				RowsDiffering:    res.RowsDiffering,
+				Excluded:         res.Excluded,
+				Sampled:          res.Sampled,
+				Confidence:       res.Confidence,
+			}
+			for _, d := range res.RowDiffs {
+				t.Checksum.Rows = append(t.Checksum.Rows, report.Row{Key: d.Key, Kind: d.Kind})
+			}
+		}
+
+		if res.Profile != nil {
+			t.Profile = &report.Profile{
+				Columns:  len(res.Profile.Remote.Columns),
+				Excluded: res.Excluded,
+			}
+			for _, m := range res.Profile.Mismatches {
+				t.Profile.Mismatches = append(t.Profile.Mismatches, report.ProfileMismatch{
+					Column: m.Column,
+					Stat:   m.Stat,
+					Source: m.Remote,
+					Target: m.Local,
+				})
+			}
+		}
+
+		r.Tables = append(r.Tables, t)
+		r.Seconds += t.Seconds
+	}
+
+	r.Summarize()
+	return r
+}
+
+// BaselineCheck converts a baseline comparison for the structured report
+func BaselineCheck(baseline *HistoryRun, alerts []DriftAlert) *report.BaselineCheck {
+	check := &report.BaselineCheck{
+		GeneratedAt: baseline.GeneratedAt,
+		Alerts:      []report.DriftAlert{},
+	}
+	for _, a := range alerts {
+		check.Alerts = append(check.Alerts, report.DriftAlert{
+			Table:    a.Table,
+			Kind:     a.Kind,
+			Baseline: a.Baseline,
+			Current:  a.Current,
+			Change:   a.Change,
+		})
+	}
+	return check
+}
@@ -0,0 +1,271 @@
+package verifier
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/thien/database-migration-tool/internal/logger"
+	"go.uber.org/zap"
+)
+
+// sampleBound is the fraction of differing chunks a sample's confidence
+// level refers to
+const sampleBound = 0.01
+
+// SetWorkers sets the number of tables verified concurrently
+func (v *Verifier) SetWorkers(n int) {
+	v.workers = n
+}
+
+// EnableSampling makes verification checksum a random set of chunks instead
+// of every chunk for tables with more than threshold estimated rows. Row
+// counts of sampled tables are planner estimates. Requires checksums.
+func (v *Verifier) EnableSampling(threshold int64, chunks int) {
+	if chunks <= 0 {
+		chunks = 300
+	}
+	v.sampleThreshold = threshold
+	v.sampleChunks = chunks
+}
+
+// EnableEstimates makes verification compare the planner's row estimates of
+// every table, a quick sanity check that reads no table data. Estimates may
+// differ by tolerance percent of the remote estimate.
+func (v *Verifier) EnableEstimates(tolerance float64) {
+	v.estimates = true
+	v.estimateTol = tolerance
+}
+
+// sampled reports whether a table is large enough to be sampled
+func (v *Verifier) sampled(ctx context.Context, table string) bool {
+	if v.sampleThreshold <= 0 || v.chunkSize <= 0 {
+		return false
+	}
+	rows, err := estimatedRows(ctx, v.remoteDB, table)
+	if err != nil {
+		logger.Debug("No row estimate, verifying in full", zap.String("table", table), zap.Error(err))
+		return false
+	}
+	return rows > v.sampleThreshold
+}
+
+// verifyEstimates compares the row estimates of a table
+func (v *Verifier) verifyEstimates(ctx context.Context, result *VerificationResult) error {
+	if err := v.compareEstimates(ctx, result); err != nil {
+		return err
+	}
+	result.Match = !exceeds(float64(result.RemoteRows), float64(result.LocalRows), float64(result.RemoteRows), v.estimateTol)
+	return nil
+}
+
+// compareEstimates fills the row counts of a result with the estimates of
+// both sides. A remote table that was never analyzed is counted instead, a
+// local one is analyzed.
+func (v *Verifier) compareEstimates(ctx context.Context, result *VerificationResult) error {
+	table := result.Table
+
+	remote, err := estimatedRows(ctx, v.remoteDB, table)
+	if err != nil {
+		return fmt.Errorf("failed to get remote estimate: %w", err)
+	}
+	if remote < 0 {
+		if remote, err = v.getRowCount(ctx, v.remoteDB, table); err != nil {
+			return fmt.Errorf("failed to get remote row count: %w", err)
+		}
+	}
+
+	local, err := estimatedRows(ctx, v.localDB, table)
+	if err != nil {
+		return fmt.Errorf("failed to get local estimate: %w", err)
+	}
+	if local < 0 {
+		if _, err := v.localDB.ExecContext(ctx, "ANALYZE "+quoteIdent(table)); err != nil {
+			return fmt.Errorf("failed to analyze local table: %w", err)
+		}
+		if local, err = estimatedRows(ctx, v.localDB, table); err != nil {
+			return fmt.Errorf("failed to get local estimate: %w", err)
+		}
+	}
+
+	result.Estimated = true
+	result.RemoteRows = remote
+	result.LocalRows = local
+	result.RowDiff = remote - local
+	return nil
+}
+
+// estimatedRows returns the planner's row estimate of a table from
+// pg_class, -1 when the table was never analyzed
+func estimatedRows(ctx context.Context, db *sql.DB, table string) (int64, error) {
+	query := `
+		SELECT reltuples::bigint
+		FROM pg_class
+		WHERE oid = to_regclass('public.' || quote_ident($1))
+	`
+	var rows int64
+	if err := db.QueryRowContext(ctx, query, table).Scan(&rows); err != nil {
+		return 0, err
+	}
+	return rows, nil
+}
+
+// verifySample compares the checksums of a random set of chunks of a table
+// and the estimates of its row counts
+func (v *Verifier) verifySample(ctx context.Context, result *VerificationResult) error {
+	table := result.Table
+	result.Sampled = true
+
+	if err := v.compareEstimates(ctx, result); err != nil {
+		return err
+	}
+
+	key, compared, excluded, err := v.comparedColumns(ctx, table)
+	if err != nil {
+		return err
+	}
+	result.Excluded = excluded
+
+	chunks, err := v.sampleChunkRanges(ctx, table, key[0], result.RemoteRows)
+	if err != nil {
+		return fmt.Errorf("failed to sample chunks: %w", err)
+	}
+	result.Chunks = len(chunks)
+
+	q := newChunkQueries(table, key, compared)
+	for _, c := range chunks {
+		remote, err := q.checksum(ctx, v.remoteDB, c)
+		if err != nil {
+			return fmt.Errorf("failed to checksum remote chunk: %w", err)
+		}
+		local, err := q.checksum(ctx, v.localDB, c)
+		if err != nil {
+			return fmt.Errorf("failed to checksum local chunk: %w", err)
+		}
+		if remote == local {
+			continue
+		}
+
+		result.ChunkMismatches++
+		diffs, err := v.diffChunk(ctx, q, c)
+		if err != nil {
+			return err
+		}
+		result.RowsDiffering += int64(len(diffs))
+		for _, d := range diffs {
+			if len(result.RowDiffs) < maxRowDiffs {
+				result.RowDiffs = append(result.RowDiffs, d)
+			}
+		}
+	}
+
+	result.Confidence = sampleConfidence(len(chunks), result.RemoteRows/int64(v.chunkSize)+1)
+	if result.ChunkMismatches > 0 {
+		result.Confidence = 0
+	}
+	result.Match = result.ChunkMismatches == 0 &&
+		!exceeds(float64(result.RemoteRows), float64(result.LocalRows), float64(result.RemoteRows), v.estimateTol)
+	return nil
+}
+
+// sampleConfidence returns the confidence that fewer than sampleBound of the
+// chunks differ when n of total chunks were compared and all matched
+func sampleConfidence(n int, total int64) float64 {
+	if int64(n) >= total {
+		return 1
+	}
+	return 1 - math.Pow(1-sampleBound, float64(n))
+}
+
+// sampleChunkRanges picks random chunks of about chunkSize rows starting at
+// keys drawn from TABLESAMPLE pages of the remote table. Chunks starting
+// inside the previous chunk are dropped.
+func (v *Verifier) sampleChunkRanges(ctx context.Context, table, column string, estimate int64) ([]chunk, error) {
+	// Draw pages for about ten times the keys needed, then pick at random
+	pct := 100.0
+	if estimate > 0 {
+		pct = math.Min(100, float64(v.sampleChunks)*10*100/float64(estimate))
+	}
+	query := fmt.Sprintf(`
+		SELECT k FROM (
+			SELECT k FROM (
+				SELECT DISTINCT %[1]s AS k FROM %[2]s TABLESAMPLE SYSTEM (%[3]g)
+			) p
+			ORDER BY random()
+			LIMIT $1
+		) s
+		ORDER BY k
+	`, quoteIdent(column), quoteIdent(table), pct)
+
+	rows, err := v.remoteDB.QueryContext(ctx, query, v.sampleChunks)
+	if err != nil {
+		return nil, err
+	}
+	var starts []interface{}
+	for rows.Next() {
+		var k interface{}
+		if err := rows.Scan(&k); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if raw, ok := k.([]byte); ok {
+			k = string(raw)
+		}
+		starts = append(starts, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	end := fmt.Sprintf("SELECT %[1]s FROM %[2]s WHERE %[1]s >= $1 ORDER BY %[1]s OFFSET $2 LIMIT 1",
+		quoteIdent(column), quoteIdent(table))
+	var chunks []chunk
+	for _, from := range starts {
+		if n := len(chunks); n > 0 {
+			prev := chunks[n-1].to
+			if prev == nil {
+				break
+			}
+			if less, ok := keyLess(from, prev); ok && less {
+				continue
+			}
+		}
+
+		var to interface{}
+		err := v.remoteDB.QueryRowContext(ctx, end, from, v.chunkSize).Scan(&to)
+		switch {
+		case err == sql.ErrNoRows:
+			to = nil
+		case err != nil:
+			return nil, err
+		}
+		if raw, ok := to.([]byte); ok {
+			to = string(raw)
+		}
+		chunks = append(chunks, chunk{from: from, to: to})
+	}
+	return chunks, nil
+}
+
+// keyLess orders two primary key values as scanned from the driver; ok is
+// false for values of other or mixed types
+func keyLess(a, b interface{}) (less, ok bool) {
+	switch x := a.(type) {
+	case int64:
+		y, ok := b.(int64)
+		return ok && x < y, ok
+	case float64:
+		y, ok := b.(float64)
+		return ok && x < y, ok
+	case string:
+		y, ok := b.(string)
+		return ok && x < y, ok
+	case time.Time:
+		y, ok := b.(time.Time)
+		return ok && x.Before(y), ok
+	}
+	return false, false
+}
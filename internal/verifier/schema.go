@@ -0,0 +1,342 @@
+package verifier
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thien/database-migration-tool/internal/introspect"
+)
+
+// Severity levels of schema differences
+const (
+	SeverityError   = "error"   // the local schema can't hold the remote data as is
+	SeverityWarning = "warning" // behaviour or performance may differ
+	SeverityInfo    = "info"    // extra local objects
+)
+
+// Categories of schema objects
+const (
+	CategoryTable      = "table"
+	CategoryColumn     = "column"
+	CategoryIndex      = "index"
+	CategoryConstraint = "constraint"
+	CategorySequence   = "sequence"
+	CategoryView       = "view"
+	CategoryFunction   = "function"
+	CategoryTrigger    = "trigger"
+	CategoryEnum       = "enum"
+	CategoryExtension  = "extension"
+)
+
+// Kinds of schema differences
+const (
+	DiffMissing = "missing" // in the remote schema only
+	DiffExtra   = "extra"   // in the local schema only
+	DiffChanged = "changed" // in both with a different definition
+)
+
+// SchemaDifference is one structural difference between the remote and local schemas
+type SchemaDifference struct {
+	Category string `json:"category"`
+	Object   string `json:"object"` // e.g. users.email for a column
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	Property string `json:"property,omitempty"` // changed property, e.g. type or nullable
+	Remote   string `json:"remote,omitempty"`
+	Local    string `json:"local,omitempty"`
+}
+
+// schemaObject is the comparable definition of an object: property name to value
+type schemaObject map[string]string
+
+// schemaSnapshot holds the objects of a schema by category and name
+type schemaSnapshot map[string]map[string]schemaObject
+
+// categories lists the compared properties of every category of objects
+var categories = []struct {
+	category   string
+	properties []string
+}{
+	{CategoryExtension, []string{"version"}},
+	{CategoryEnum, []string{"values"}},
+	{CategoryTable, []string{"kind"}},
+	{CategoryColumn, []string{"type", "nullable", "default"}},
+	{CategoryIndex, []string{"definition"}},
+	{CategoryConstraint, []string{"definition"}},
+	{CategoryTrigger, []string{"definition", "enabled"}},
+	{CategorySequence, []string{"type", "increment", "cycle"}},
+	{CategoryView, []string{"definition"}},
+	{CategoryFunction, []string{"result", "definition"}},
+}
+
+// CompareSchemas compares the remote and local schemas object by object.
+// Differences matching an ignore pattern are dropped; patterns are globs
+// matched against "category:object" or the object alone, e.g. "index:*_tmp"
+// or "audit_log.*".
+func (v *Verifier) CompareSchemas(ctx context.Context, ignore []string) ([]SchemaDifference, error) {
+	remote, err := loadSchema(ctx, v.remoteDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote schema: %w", err)
+	}
+	local, err := loadSchema(ctx, v.localDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local schema: %w", err)
+	}
+
+	var diffs []SchemaDifference
+	for _, c := range categories {
+		diffs = append(diffs, compareCategory(c.category, c.properties, remote[c.category], local[c.category])...)
+	}
+
+	// The columns, indexes and constraints of a missing or extra table are
+	// implied by the table difference
+	unmatched := make(map[string]bool)
+	for _, d := range diffs {
+		if d.Category == CategoryTable && d.Kind != DiffChanged {
+			unmatched[d.Object] = true
+		}
+	}
+
+	var kept []SchemaDifference
+	for _, d := range diffs {
+		if d.Category == CategoryColumn || d.Category == CategoryIndex || d.Category == CategoryConstraint || d.Category == CategoryTrigger {
+			if table, _, ok := strings.Cut(d.Object, "."); ok && unmatched[table] {
+				continue
+			}
+		}
+		if ignored(d, ignore) {
+			continue
+		}
+		kept = append(kept, d)
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		if rank(kept[i].Severity) != rank(kept[j].Severity) {
+			return rank(kept[i].Severity) < rank(kept[j].Severity)
+		}
+		if kept[i].Category != kept[j].Category {
+			return kept[i].Category < kept[j].Category
+		}
+		if kept[i].Object != kept[j].Object {
+			return kept[i].Object < kept[j].Object
+		}
+		return kept[i].Property < kept[j].Property
+	})
+	return kept, nil
+}
+
+// loadSchema reads a snapshot of the public schema of a database
+func loadSchema(ctx context.Context, db *sql.DB) (schemaSnapshot, error) {
+	schema, err := introspect.Inspect(ctx, db, "public")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(schemaSnapshot)
+	for _, c := range categories {
+		snapshot[c.category] = make(map[string]schemaObject)
+	}
+	yesNo := func(b bool) string {
+		if b {
+			return "YES"
+		}
+		return "NO"
+	}
+
+	for _, e := range schema.Extensions {
+		snapshot[CategoryExtension][e.Name] = schemaObject{"version": e.Version}
+	}
+	for _, e := range schema.Enums {
+		snapshot[CategoryEnum][e.Name] = schemaObject{"values": strings.Join(e.Values, ", ")}
+	}
+	for _, t := range schema.Tables {
+		kind := "table"
+		if t.Partitioned {
+			kind = "partitioned"
+		}
+		snapshot[CategoryTable][t.Name] = schemaObject{"kind": kind}
+
+		for _, c := range t.Columns {
+			def := c.Default
+			if c.Generated != "" {
+				def = "GENERATED ALWAYS AS (" + c.Generated + ") STORED"
+			}
+			snapshot[CategoryColumn][t.Name+"."+c.Name] = schemaObject{
+				"type":     c.Type,
+				"nullable": yesNo(!c.NotNull),
+				"default":  def,
+			}
+		}
+		for _, i := range t.Indexes {
+			snapshot[CategoryIndex][t.Name+"."+i.Name] = schemaObject{"definition": i.Definition}
+		}
+		for _, c := range t.Constraints {
+			snapshot[CategoryConstraint][t.Name+"."+c.Name] = schemaObject{"definition": c.Definition}
+		}
+		for _, tr := range t.Triggers {
+			snapshot[CategoryTrigger][t.Name+"."+tr.Name] = schemaObject{"definition": tr.Definition, "enabled": yesNo(tr.Enabled)}
+		}
+	}
+	for _, q := range schema.Sequences {
+		snapshot[CategorySequence][q.Name] = schemaObject{
+			"type":      q.Type,
+			"increment": strconv.FormatInt(q.Increment, 10),
+			"cycle":     yesNo(q.Cycle),
+		}
+	}
+	for _, v := range schema.Views {
+		snapshot[CategoryView][v.Name] = schemaObject{"definition": v.Definition}
+	}
+	for _, f := range schema.Functions {
+		snapshot[CategoryFunction][f.Signature()] = schemaObject{
+			"result":     f.Result,
+			"definition": fmt.Sprintf("%x", md5.Sum([]byte(f.Definition))),
+		}
+	}
+	return snapshot, nil
+}
+
+// compareCategory compares the objects of one category
+func compareCategory(category string, properties []string, remote, local map[string]schemaObject) []SchemaDifference {
+	var diffs []SchemaDifference
+
+	for name, r := range remote {
+		l, ok := local[name]
+		if !ok {
+			diffs = append(diffs, SchemaDifference{
+				Category: category,
+				Object:   name,
+				Kind:     DiffMissing,
+				Severity: missingSeverity(category),
+			})
+			continue
+		}
+		for _, p := range properties {
+			if r[p] == l[p] {
+				continue
+			}
+			diffs = append(diffs, SchemaDifference{
+				Category: category,
+				Object:   name,
+				Kind:     DiffChanged,
+				Severity: changedSeverity(category, p, r[p], l[p]),
+				Property: p,
+				Remote:   r[p],
+				Local:    l[p],
+			})
+		}
+	}
+
+	for name := range local {
+		if _, ok := remote[name]; !ok {
+			diffs = append(diffs, SchemaDifference{
+				Category: category,
+				Object:   name,
+				Kind:     DiffExtra,
+				Severity: SeverityInfo,
+			})
+		}
+	}
+	return diffs
+}
+
+// missingSeverity rates an object that exists remotely but not locally
+func missingSeverity(category string) string {
+	switch category {
+	case CategoryTable, CategoryColumn, CategoryConstraint:
+		return SeverityError
+	default:
+		return SeverityWarning
+	}
+}
+
+// changedSeverity rates a property that differs between the schemas
+func changedSeverity(category, property, remote, local string) string {
+	switch {
+	case category == CategoryColumn && property == "type":
+		return SeverityError
+	case category == CategoryColumn && property == "nullable":
+		// A local NOT NULL rejects remote NULLs, the other way round is harmless
+		if local == "NO" {
+			return SeverityError
+		}
+		return SeverityWarning
+	case category == CategoryConstraint:
+		return SeverityError
+	default:
+		return SeverityWarning
+	}
+}
+
+// ignored reports whether a difference matches an ignore pattern
+func ignored(d SchemaDifference, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, d.Category+":"+d.Object); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, d.Object); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// rank orders severities from the most to the least severe
+func rank(severity string) int {
+	switch severity {
+	case SeverityError:
+		return 0
+	case SeverityWarning:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// CountSeverity returns the number of differences of a severity
+func CountSeverity(diffs []SchemaDifference, severity string) int {
+	n := 0
+	for _, d := range diffs {
+		if d.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// GenerateSchemaReport summarizes schema differences
+func (v *Verifier) GenerateSchemaReport(diffs []SchemaDifference) string {
+	var report string
+	report += "\n========================================\n"
+	report += "      SCHEMA VERIFICATION REPORT         \n"
+	report += "========================================\n\n"
+
+	if len(diffs) == 0 {
+		report += "✓ Remote and local schemas are identical\n"
+	}
+	for _, d := range diffs {
+		mark := "✗"
+		if d.Severity != SeverityError {
+			mark = "!"
+		}
+		line := fmt.Sprintf("%s [%s] %s %s %s", mark, d.Severity, d.Category, d.Object, d.Kind)
+		if d.Kind == DiffChanged {
+			line += fmt.Sprintf(" %s: %q -> %q", d.Property, d.Remote, d.Local)
+		}
+		report += line + "\n"
+	}
+
+	report += "\n========================================\n"
+	report += fmt.Sprintf("Errors:          %d\n", CountSeverity(diffs, SeverityError))
+	report += fmt.Sprintf("Warnings:        %d\n", CountSeverity(diffs, SeverityWarning))
+	report += fmt.Sprintf("Info:            %d\n", CountSeverity(diffs, SeverityInfo))
+	report += "========================================\n"
+
+	return report
+}
@@ -4,15 +4,28 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/thien/database-migration-tool/internal/config"
 	"github.com/thien/database-migration-tool/internal/logger"
 	"go.uber.org/zap"
 )
 
 // Verifier handles data integrity verification
 type Verifier struct {
-	remoteDB *sql.DB
-	localDB  *sql.DB
+	remoteDB  *sql.DB
+	localDB   *sql.DB
+	chunkSize int                             // 0 compares row counts only
+	profile   *config.ProfileConfig           // compare column statistics when set
+	exclude   func(table, column string) bool // columns left out of checksums and profiles
+	workers   int                             // tables verified concurrently
+
+	sampleThreshold int64   // tables with more estimated rows are sampled, 0 never
+	sampleChunks    int     // chunks checksummed per sampled table
+	estimates       bool    // compare planner row estimates only
+	estimateTol     float64 // percent the estimates may differ by
 }
 
 // NewVerifier creates a new verifier
@@ -31,44 +44,108 @@ type VerificationResult struct {
 	Match      bool
 	RowDiff    int64
 	Error      error
+	Duration   time.Duration
+	Tolerated  bool // mismatching within the policy's tolerance
+	Estimated  bool // row counts are planner estimates
+
+	// Content comparison, when checksums are enabled
+	Chunks          int
+	ChunkMismatches int
+	RowsDiffering   int64
+	RowDiffs        []RowDiff // the first differing rows
+	Excluded        []string  // columns not compared
+	Checksum        string    // of the remote table contents, to follow them across runs
+	Sampled         bool      // only a random set of chunks was compared
+	Confidence      float64   // of fewer than 1% of chunks differing, when sampled
+
+	// Statistical comparison, when profiles are enabled
+	Profile *ProfileComparison
 }
 
-// VerifyAll verifies all tables
+// VerifyAll verifies all tables, up to the configured number at a time
 func (v *Verifier) VerifyAll(ctx context.Context, tables []string) ([]VerificationResult, error) {
-	logger.Info("Starting verification", zap.Int("table_count", len(tables)))
-
-	var results []VerificationResult
-
-	for _, table := range tables {
-		result := v.verifyTable(ctx, table)
-		results = append(results, result)
-
-		if result.Error != nil {
-			logger.Error("Verification error",
-				zap.String("table", table),
-				zap.Error(result.Error))
-		} else if !result.Match {
-			logger.Warn("Row count mismatch",
-				zap.String("table", table),
-				zap.Int64("remote", result.RemoteRows),
-				zap.Int64("local", result.LocalRows),
-				zap.Int64("diff", result.RowDiff))
-		} else {
-			logger.Info("Verification passed",
-				zap.String("table", table),
-				zap.Int64("rows", result.LocalRows))
-		}
+	logger.Info("Starting verification", zap.Int("table_count", len(tables)), zap.Int("workers", v.workerCount()))
+
+	results := make([]VerificationResult, len(tables))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < v.workerCount(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				start := time.Now()
+				results[i] = v.verifyTable(ctx, tables[i])
+				results[i].Duration = time.Since(start)
+				logResult(results[i])
+			}
+		}()
+	}
+	for i := range tables {
+		next <- i
 	}
+	close(next)
+	wg.Wait()
 
 	return results, nil
 }
 
+// workerCount returns the number of tables verified concurrently
+func (v *Verifier) workerCount() int {
+	if v.workers < 1 {
+		return 1
+	}
+	return v.workers
+}
+
+// logResult logs the outcome of a table verification
+func logResult(result VerificationResult) {
+	table := result.Table
+	if result.Error != nil {
+		logger.Error("Verification error",
+			zap.String("table", table),
+			zap.Error(result.Error))
+	} else if result.ChunkMismatches > 0 {
+		logger.Warn("Content mismatch",
+			zap.String("table", table),
+			zap.Int("chunks", result.ChunkMismatches),
+			zap.Int64("rows", result.RowsDiffering))
+	} else if result.Profile != nil && len(result.Profile.Mismatches) > 0 {
+		logger.Warn("Profile mismatch",
+			zap.String("table", table),
+			zap.Int("statistics", len(result.Profile.Mismatches)))
+	} else if !result.Match {
+		logger.Warn("Row count mismatch",
+			zap.String("table", table),
+			zap.Int64("remote", result.RemoteRows),
+			zap.Int64("local", result.LocalRows),
+			zap.Int64("diff", result.RowDiff))
+	} else {
+		logger.Info("Verification passed",
+			zap.String("table", table),
+			zap.Int64("rows", result.LocalRows))
+	}
+}
+
 // verifyTable verifies a single table
 func (v *Verifier) verifyTable(ctx context.Context, table string) VerificationResult {
 	result := VerificationResult{
 		Table: table,
 	}
 
+	if v.estimates {
+		if err := v.verifyEstimates(ctx, &result); err != nil {
+			result.Error = fmt.Errorf("failed to compare estimates: %w", err)
+		}
+		return result
+	}
+	if v.sampled(ctx, table) {
+		if err := v.verifySample(ctx, &result); err != nil {
+			result.Error = fmt.Errorf("failed to compare sample: %w", err)
+		}
+		return result
+	}
+
 	// Get remote row count
 	remoteCount, err := v.getRowCount(ctx, v.remoteDB, table)
 	if err != nil {
@@ -89,6 +166,20 @@ func (v *Verifier) verifyTable(ctx context.Context, table string) VerificationRe
 	result.RowDiff = result.RemoteRows - result.LocalRows
 	result.Match = (result.RowDiff == 0)
 
+	if v.chunkSize > 0 {
+		if err := v.verifyContent(ctx, &result); err != nil {
+			result.Error = fmt.Errorf("failed to compare contents: %w", err)
+			return result
+		}
+		result.Match = result.Match && result.ChunkMismatches == 0
+	} else if v.profile != nil {
+		if err := v.verifyProfile(ctx, &result); err != nil {
+			result.Error = fmt.Errorf("failed to compare profiles: %w", err)
+			return result
+		}
+		result.Match = result.Match && len(result.Profile.Mismatches) == 0
+	}
+
 	return result
 }
 
@@ -103,49 +194,29 @@ func (v *Verifier) getRowCount(ctx context.Context, db *sql.DB, table string) (i
 	return count, nil
 }
 
-// VerifySchema verifies that schema exists in both databases
+// VerifySchema compares the remote and local schemas and fails on any
+// error-level difference
 func (v *Verifier) VerifySchema(ctx context.Context) error {
 	logger.Info("Verifying schema consistency")
 
-	// Get tables from remote
-	remoteTables, err := v.getTables(ctx, v.remoteDB)
+	diffs, err := v.CompareSchemas(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to get remote tables: %w", err)
+		return err
 	}
 
-	// Get tables from local
-	localTables, err := v.getTables(ctx, v.localDB)
-	if err != nil {
-		return fmt.Errorf("failed to get local tables: %w", err)
-	}
-
-	// Convert to maps for comparison
-	remoteMap := make(map[string]bool)
-	for _, t := range remoteTables {
-		remoteMap[t] = true
-	}
-
-	localMap := make(map[string]bool)
-	for _, t := range localTables {
-		localMap[t] = true
-	}
-
-	// Find missing tables
-	var missingInLocal []string
-	for table := range remoteMap {
-		if !localMap[table] {
-			missingInLocal = append(missingInLocal, table)
+	if errors := CountSeverity(diffs, SeverityError); errors > 0 {
+		for _, d := range diffs {
+			if d.Severity == SeverityError {
+				logger.Warn("Schema difference",
+					zap.String("category", d.Category),
+					zap.String("object", d.Object),
+					zap.String("kind", d.Kind))
+			}
 		}
+		return fmt.Errorf("schema mismatch: %d differences", errors)
 	}
 
-	if len(missingInLocal) > 0 {
-		logger.Warn("Tables missing in local database", zap.Strings("tables", missingInLocal))
-		return fmt.Errorf("schema mismatch: %d tables missing in local database", len(missingInLocal))
-	}
-
-	logger.Info("Schema verification passed",
-		zap.Int("remote_tables", len(remoteTables)),
-		zap.Int("local_tables", len(localTables)))
+	logger.Info("Schema verification passed", zap.Int("differences", len(diffs)))
 
 	return nil
 }
@@ -199,11 +270,46 @@ func (v *Verifier) GenerateReport(results []VerificationResult) string {
 			totalRemoteRows += r.RemoteRows
 			totalLocalRows += r.LocalRows
 			report += fmt.Sprintf("✓ %s - %d rows\n", r.Table, r.LocalRows)
-		} else {
+		} else if r.Tolerated {
+			matchedTables++
+			totalRemoteRows += r.RemoteRows
+			totalLocalRows += r.LocalRows
+			report += fmt.Sprintf("~ %s - WITHIN TOLERANCE (Remote: %d, Local: %d, Differing: %d)\n",
+				r.Table, r.RemoteRows, r.LocalRows, differingRows(&r))
+		} else if r.RowDiff != 0 {
 			totalRemoteRows += r.RemoteRows
 			totalLocalRows += r.LocalRows
 			report += fmt.Sprintf("✗ %s - MISMATCH (Remote: %d, Local: %d, Diff: %d)\n",
 				r.Table, r.RemoteRows, r.LocalRows, r.RowDiff)
+		} else if r.Profile != nil {
+			totalRemoteRows += r.RemoteRows
+			totalLocalRows += r.LocalRows
+			report += fmt.Sprintf("✗ %s - PROFILE MISMATCH (%d statistics)\n", r.Table, len(r.Profile.Mismatches))
+		} else {
+			totalRemoteRows += r.RemoteRows
+			totalLocalRows += r.LocalRows
+			report += fmt.Sprintf("✗ %s - CONTENT MISMATCH (%d of %d chunks, %d rows)\n",
+				r.Table, r.ChunkMismatches, r.Chunks, r.RowsDiffering)
+		}
+		if r.Profile != nil {
+			for _, m := range r.Profile.Mismatches {
+				report += fmt.Sprintf("    %s.%s: %s (remote) / %s (local)\n", m.Column, m.Stat, m.Remote, m.Local)
+			}
+		}
+		for _, d := range r.RowDiffs {
+			report += fmt.Sprintf("    %-8s %s\n", d.Kind, d.Key)
+		}
+		if int64(len(r.RowDiffs)) < r.RowsDiffering {
+			report += fmt.Sprintf("    ... and %d more\n", r.RowsDiffering-int64(len(r.RowDiffs)))
+		}
+		if r.Sampled && r.Error == nil {
+			report += fmt.Sprintf("    (sampled %d chunks, estimated rows, %.0f%% confidence that fewer than 1%% of chunks differ)\n",
+				r.Chunks, r.Confidence*100)
+		} else if r.Estimated && r.Error == nil {
+			report += "    (estimated rows)\n"
+		}
+		if len(r.Excluded) > 0 && r.Error == nil {
+			report += fmt.Sprintf("    (not compared: %s)\n", strings.Join(r.Excluded, ", "))
 		}
 	}
 